@@ -17,6 +17,7 @@ package config
 import (
 	"fmt"
 	"os"
+	"runtime/debug"
 	"strconv"
 )
 
@@ -43,7 +44,31 @@ func SetIsFedramp() error {
 	return nil
 }
 
+// SetFedramp overrides the value of fedramp, e.g. when rendering a config offline
+func SetFedramp(fedramp bool) {
+	isFedramp = fedramp
+}
+
 // IsFedramp returns value of isFedramp var
 func IsFedramp() bool {
 	return isFedramp
 }
+
+// OperatorVersion identifies the running build by the VCS revision the Go toolchain embedded in it,
+// or the module version when there is none.
+func OperatorVersion() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "unknown"
+	}
+	version := info.Main.Version
+	for _, setting := range info.Settings {
+		if setting.Key == "vcs.revision" {
+			version = setting.Value
+			if len(version) > 12 {
+				version = version[:12]
+			}
+		}
+	}
+	return version
+}
@@ -0,0 +1,311 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	"gopkg.in/yaml.v3"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/openshift/configure-alertmanager-operator/config"
+	alertmanager "github.com/openshift/configure-alertmanager-operator/pkg/types"
+)
+
+const (
+	// operator configmap key selecting how credentials end up in alertmanager.yaml
+	cmKeyCredentialsMode = "credentials_mode"
+
+	// credentials are copied into alertmanager.yaml (default)
+	credentialsModeInline = "inline"
+
+	// the source secrets are mounted into Alertmanager and referenced with *_file fields
+	credentialsModeFiles = "files"
+
+	// configmap of the cluster monitoring operator, whose alertmanagerMain.secrets lists the secrets mounted into Alertmanager
+	cmNameClusterMonitoringConfig = "cluster-monitoring-config"
+
+	cmKeyClusterMonitoringConfig = "config.yaml"
+
+	// the StatefulSet the cluster monitoring operator mounts the secrets into
+	statefulSetNameAlertmanager = "alertmanager-main"
+
+	// where the cluster monitoring operator mounts the secrets, as <path>/<secret name>/<key>
+	alertmanagerSecretsPath = "/etc/alertmanager/secrets"
+
+	// the cluster monitoring operator names the volume of a mounted secret secret-<secret name>
+	alertmanagerSecretVolumePrefix = "secret-"
+
+	// how often to check whether the cluster monitoring operator mounted the secrets
+	credentialMountRequeueDelay = time.Minute
+)
+
+// credentialSecrets are the source secrets that can be mounted into Alertmanager, in the order they are listed.
+var credentialSecrets = []string{secretNamePD, secretNameGoalert, secretNameDMS}
+
+// credentialFile returns the path of a key of a mounted secret inside the Alertmanager pods.
+func credentialFile(secretName, key string) string {
+	return path.Join(alertmanagerSecretsPath, secretName, key)
+}
+
+// parseCredentialsMode returns the credentials mode from the operator configMap, defaulting to inline.
+func (r *SecretReconciler) parseCredentialsMode(reqLogger logr.Logger, cmList *corev1.ConfigMapList, cmNamespace string) string {
+	if !cmInList(reqLogger, cmNameOperatorConfig, cmList) {
+		return credentialsModeInline
+	}
+
+	mode := readCMKey(r, reqLogger, cmNameOperatorConfig, cmNamespace, cmKeyCredentialsMode)
+	switch mode {
+	case "", credentialsModeInline:
+		return credentialsModeInline
+	case credentialsModeFiles:
+		return credentialsModeFiles
+	default:
+		reqLogger.Info("WARNING: Unknown credentials mode; copying credentials into the config", "Mode", mode, "ConfigMap", fmt.Sprintf("%s/%s", cmNamespace, cmNameOperatorConfig))
+		return credentialsModeInline
+	}
+}
+
+// reader returns the client to read the objects the controller doesn't watch with.
+func (r *SecretReconciler) reader() client.Reader {
+	if r.APIReader != nil {
+		return r.APIReader
+	}
+	return r.Client
+}
+
+// mountedCredentialSecrets returns the source secrets mounted into the alertmanager-main StatefulSet.
+func (r *SecretReconciler) mountedCredentialSecrets(reqLogger logr.Logger) map[string]bool {
+	mounted := map[string]bool{}
+
+	sts := &appsv1.StatefulSet{}
+	if err := r.reader().Get(context.TODO(), client.ObjectKey{Namespace: config.OperatorNamespace, Name: statefulSetNameAlertmanager}, sts); err != nil {
+		if !errors.IsNotFound(err) {
+			reqLogger.Error(err, "Unable to get StatefulSet", "StatefulSet", statefulSetNameAlertmanager)
+		}
+		return mounted
+	}
+
+	for _, volume := range sts.Spec.Template.Spec.Volumes {
+		if volume.Secret == nil {
+			continue
+		}
+		for _, name := range credentialSecrets {
+			if volume.Name == alertmanagerSecretVolumePrefix+name && volume.Secret.SecretName == name {
+				mounted[name] = true
+			}
+		}
+	}
+	return mounted
+}
+
+// useCredentialFiles replaces the credentials of the receivers whose source secret is mounted
+// into Alertmanager by references to the mounted files.
+func useCredentialFiles(amconfig *alertmanager.Config, mounted map[string]bool) {
+	webhookFiles := map[string]string{}
+	if mounted[secretNameGoalert] {
+		webhookFiles[receiverGoAlertLow] = credentialFile(secretNameGoalert, secretKeyGoalertLow)
+		webhookFiles[receiverGoAlertHigh] = credentialFile(secretNameGoalert, secretKeyGoalertHigh)
+		webhookFiles[receiverGoAlertHeartbeat] = credentialFile(secretNameGoalert, secretKeyGoalertHeartbeat)
+	}
+	if mounted[secretNameDMS] {
+		webhookFiles[receiverWatchdog] = credentialFile(secretNameDMS, secretKeyDMS)
+	}
+
+	for _, receiver := range amconfig.Receivers {
+		if mounted[secretNamePD] {
+			for _, pdc := range receiver.PagerdutyConfigs {
+				pdc.RoutingKey = ""
+				pdc.RoutingKeyFile = credentialFile(secretNamePD, secretKeyPD)
+			}
+		}
+		file, ok := webhookFiles[receiver.Name]
+		if !ok && mounted[secretNameGoalert] && strings.HasPrefix(receiver.Name, "goalert-") {
+			// the receivers of the GoAlert tiers beyond low and high
+			file = credentialFile(secretNameGoalert, goalertTierKey(strings.TrimPrefix(receiver.Name, "goalert-")))
+			ok = true
+		}
+		if ok {
+			for _, whc := range receiver.WebhookConfigs {
+				whc.URL = ""
+				whc.URLFile = file
+			}
+		}
+	}
+}
+
+// syncAlertmanagerSecretMounts adds the existing source secrets to, or removes them from, the
+// alertmanagerMain.secrets list of the cluster monitoring config, depending on the credentials mode.
+// Other entries of the list, other keys and comments are left alone, and the ConfigMap is only
+// patched when the list changes; in files mode, a missing ConfigMap is created with the list.
+// It returns true while a secret that should be mounted isn't mounted into the StatefulSet yet.
+func (r *SecretReconciler) syncAlertmanagerSecretMounts(reqLogger logr.Logger, secretList *corev1.SecretList, mode string) (bool, error) {
+	wanted := map[string]bool{}
+	if mode == credentialsModeFiles {
+		for _, name := range credentialSecrets {
+			wanted[name] = secretInList(reqLogger, name, secretList)
+		}
+	}
+
+	cm := &corev1.ConfigMap{}
+	create := false
+	if err := r.Client.Get(context.TODO(), client.ObjectKey{Namespace: config.OperatorNamespace, Name: cmNameClusterMonitoringConfig}, cm); err != nil {
+		if !errors.IsNotFound(err) {
+			return false, err
+		}
+		if mode == credentialsModeInline {
+			return false, nil
+		}
+		// the cluster monitoring operator runs with its defaults, start from an empty config
+		cm = &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: cmNameClusterMonitoringConfig, Namespace: config.OperatorNamespace}}
+		create = true
+	}
+
+	// Edit the parsed document in place, so that comments and the keys owned by other components
+	// survive the update.
+	var root yaml.Node
+	if err := yaml.Unmarshal([]byte(cm.Data[cmKeyClusterMonitoringConfig]), &root); err != nil {
+		return false, fmt.Errorf("unable to parse %s: %w", cmNameClusterMonitoringConfig, err)
+	}
+	if root.Kind == 0 {
+		root = yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{{Kind: yaml.MappingNode, Tag: "!!map"}}}
+	}
+	monitoringConfig := root.Content[0]
+	if monitoringConfig.Kind != yaml.MappingNode {
+		return false, fmt.Errorf("unable to parse %s: expected a mapping", cmNameClusterMonitoringConfig)
+	}
+	alertmanagerMain := mappingValue(monitoringConfig, "alertmanagerMain")
+	secretsNode := mappingValue(alertmanagerMain, "secrets")
+
+	var secrets []string
+	if secretsNode != nil && secretsNode.Kind == yaml.SequenceNode {
+		for _, entry := range secretsNode.Content {
+			secrets = append(secrets, entry.Value)
+		}
+	}
+
+	// Drop our secrets that are no longer wanted, and append the missing ones.
+	updated := []string{}
+	present := map[string]bool{}
+	for _, name := range secrets {
+		managed := false
+		for _, credentialSecret := range credentialSecrets {
+			managed = managed || name == credentialSecret
+		}
+		if managed && !wanted[name] {
+			continue
+		}
+		present[name] = true
+		updated = append(updated, name)
+	}
+	for _, name := range credentialSecrets {
+		if wanted[name] && !present[name] {
+			updated = append(updated, name)
+		}
+	}
+
+	pending := false
+	if mode == credentialsModeFiles {
+		mounted := r.mountedCredentialSecrets(reqLogger)
+		for name, want := range wanted {
+			pending = pending || (want && !mounted[name])
+		}
+	}
+
+	if fmt.Sprint(updated) == fmt.Sprint(secrets) {
+		return pending, nil
+	}
+
+	reqLogger.Info("INFO: Updating the secrets mounted into Alertmanager", "ConfigMap", cmNameClusterMonitoringConfig, "Secrets", updated)
+	switch {
+	case len(updated) == 0:
+		removeMappingKey(alertmanagerMain, "secrets")
+	case secretsNode != nil && secretsNode.Kind == yaml.SequenceNode:
+		// keep the existing entries, and their comments, where we can
+		existing := map[string]*yaml.Node{}
+		for _, entry := range secretsNode.Content {
+			existing[entry.Value] = entry
+		}
+		secretsNode.Content = nil
+		for _, name := range updated {
+			entry, ok := existing[name]
+			if !ok {
+				entry = &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: name}
+			}
+			secretsNode.Content = append(secretsNode.Content, entry)
+		}
+	default:
+		if alertmanagerMain == nil || alertmanagerMain.Kind != yaml.MappingNode {
+			removeMappingKey(monitoringConfig, "alertmanagerMain")
+			alertmanagerMain = &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+			monitoringConfig.Content = append(monitoringConfig.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: "alertmanagerMain"}, alertmanagerMain)
+		}
+		removeMappingKey(alertmanagerMain, "secrets")
+		secretsNode = &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+		for _, name := range updated {
+			secretsNode.Content = append(secretsNode.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: name})
+		}
+		alertmanagerMain.Content = append(alertmanagerMain.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: "secrets"}, secretsNode)
+	}
+
+	raw := &strings.Builder{}
+	encoder := yaml.NewEncoder(raw)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(&root); err != nil {
+		return pending, err
+	}
+	if err := encoder.Close(); err != nil {
+		return pending, err
+	}
+
+	if create {
+		reqLogger.Info("INFO: Creating ConfigMap to mount the secrets into Alertmanager", "ConfigMap", cmNameClusterMonitoringConfig)
+		cm.Data = map[string]string{cmKeyClusterMonitoringConfig: raw.String()}
+		return pending, r.Client.Create(context.TODO(), cm)
+	}
+
+	// Only the config key is patched, and the patch fails on a conflicting write so the next
+	// reconcile works from the other writer's version instead of overwriting it.
+	original := cm.DeepCopy()
+	if cm.Data == nil {
+		cm.Data = map[string]string{}
+	}
+	cm.Data[cmKeyClusterMonitoringConfig] = raw.String()
+	return pending, r.Client.Patch(context.TODO(), cm, client.MergeFromWithOptions(original, client.MergeFromWithOptimisticLock{}))
+}
+
+// removeMappingKey removes key, and its value, from a mapping node.
+func removeMappingKey(node *yaml.Node, key string) {
+	if node == nil || node.Kind != yaml.MappingNode {
+		return
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			node.Content = append(node.Content[:i], node.Content[i+2:]...)
+			return
+		}
+	}
+}
@@ -0,0 +1,219 @@
+package controllers
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/openshift/configure-alertmanager-operator/config"
+	"github.com/openshift/configure-alertmanager-operator/pkg/readiness"
+	alertmanager "github.com/openshift/configure-alertmanager-operator/pkg/types"
+	"go.uber.org/mock/gomock"
+	yaml "gopkg.in/yaml.v2"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// createAlertmanagerStatefulSet creates the alertmanager-main StatefulSet with the given secrets mounted.
+func createAlertmanagerStatefulSet(reconciler *SecretReconciler, secretNames ...string) {
+	sts := &appsv1.StatefulSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      statefulSetNameAlertmanager,
+			Namespace: config.OperatorNamespace,
+		},
+	}
+	for _, name := range secretNames {
+		sts.Spec.Template.Spec.Volumes = append(sts.Spec.Template.Spec.Volumes, corev1.Volume{
+			Name:         alertmanagerSecretVolumePrefix + name,
+			VolumeSource: corev1.VolumeSource{Secret: &corev1.SecretVolumeSource{SecretName: name}},
+		})
+	}
+	if err := reconciler.Client.Create(context.TODO(), sts); err != nil {
+		panic(err)
+	}
+}
+
+// readMountedSecrets returns alertmanagerMain.secrets from the cluster monitoring config.
+func readMountedSecrets(t *testing.T, reconciler *SecretReconciler) []string {
+	cm := &corev1.ConfigMap{}
+	if err := reconciler.Client.Get(context.TODO(), client.ObjectKey{Namespace: config.OperatorNamespace, Name: cmNameClusterMonitoringConfig}, cm); err != nil {
+		t.Fatalf("Could not get %s: %v", cmNameClusterMonitoringConfig, err)
+	}
+	monitoringConfig := struct {
+		AlertmanagerMain struct {
+			Secrets []string `yaml:"secrets"`
+		} `yaml:"alertmanagerMain"`
+		PrometheusK8s map[string]interface{} `yaml:"prometheusK8s"`
+	}{}
+	if err := yaml.Unmarshal([]byte(cm.Data[cmKeyClusterMonitoringConfig]), &monitoringConfig); err != nil {
+		t.Fatalf("Could not parse %s: %v", cmNameClusterMonitoringConfig, err)
+	}
+	assertEquals(t, "15d", monitoringConfig.PrometheusK8s["retention"], "Other settings are kept")
+	return monitoringConfig.AlertmanagerMain.Secrets
+}
+
+func Test_useCredentialFiles(t *testing.T) {
+	amconfig := createAlertManagerConfig(reqLogger, "pdkey", "https://dummy-galow-url", "https://dummy-gahigh-url", "https://dummy-gaheartbeat-url", "http://theinterwebs", "https://dummy-oa-url", exampleClusterId, exampleProxy, defaultNamespaces, alertmanagerConfigOptions{})
+
+	// GoAlert isn't mounted and keeps its inline URLs
+	useCredentialFiles(amconfig, map[string]bool{secretNamePD: true, secretNameDMS: true})
+
+	for _, receiver := range amconfig.Receivers {
+		for _, pdc := range receiver.PagerdutyConfigs {
+			assertEquals(t, alertmanager.Secret(""), pdc.RoutingKey, receiver.Name+" RoutingKey")
+			assertEquals(t, "/etc/alertmanager/secrets/pd-secret/PAGERDUTY_KEY", pdc.RoutingKeyFile, receiver.Name+" RoutingKeyFile")
+		}
+		for _, whc := range receiver.WebhookConfigs {
+			switch receiver.Name {
+			case receiverWatchdog:
+				assertEquals(t, alertmanager.SecretURL(""), whc.URL, "Watchdog URL")
+				assertEquals(t, "/etc/alertmanager/secrets/dms-secret/SNITCH_URL", whc.URLFile, "Watchdog URLFile")
+			default:
+				assertTrue(t, whc.URL != "", receiver.Name+" URL")
+				assertEquals(t, "", whc.URLFile, receiver.Name+" URLFile")
+			}
+		}
+	}
+}
+
+func Test_syncAlertmanagerSecretMounts(t *testing.T) {
+	reconciler := createReconciler(t, nil)
+	createNamespace(reconciler, t)
+	createConfigMap(reconciler, cmNameClusterMonitoringConfig, cmKeyClusterMonitoringConfig, "prometheusK8s:\n  retention: 15d\nalertmanagerMain:\n  secrets:\n  - other-secret\n  - goalert-secret\n")
+	createSecret(reconciler, secretNamePD, secretKeyPD, "pdkey")
+	createSecret(reconciler, secretNameDMS, secretKeyDMS, "http://theinterwebs")
+
+	secretList := &corev1.SecretList{}
+	if err := reconciler.Client.List(context.TODO(), secretList); err != nil {
+		t.Fatalf("Could not list secrets: %v", err)
+	}
+
+	// goalert-secret doesn't exist, so it is unmounted; the others are mounted
+	pending, err := reconciler.syncAlertmanagerSecretMounts(reqLogger, secretList, credentialsModeFiles)
+	assertEquals(t, nil, err, "Unexpected err")
+	assertTrue(t, pending, "Mounts should be pending without a StatefulSet")
+	assertEquals(t, []string{"other-secret", secretNamePD, secretNameDMS}, readMountedSecrets(t, reconciler), "Mounted secrets")
+
+	createAlertmanagerStatefulSet(reconciler, secretNamePD, secretNameDMS)
+	pending, err = reconciler.syncAlertmanagerSecretMounts(reqLogger, secretList, credentialsModeFiles)
+	assertEquals(t, nil, err, "Unexpected err")
+	assertTrue(t, !pending, "Mounts should be done")
+
+	pending, err = reconciler.syncAlertmanagerSecretMounts(reqLogger, secretList, credentialsModeInline)
+	assertEquals(t, nil, err, "Unexpected err")
+	assertTrue(t, !pending, "Nothing to mount inline")
+	assertEquals(t, []string{"other-secret"}, readMountedSecrets(t, reconciler), "Mounted secrets")
+}
+
+func Test_syncAlertmanagerSecretMounts_CreatesConfigMap(t *testing.T) {
+	reconciler := createReconciler(t, nil)
+	createNamespace(reconciler, t)
+	createSecret(reconciler, secretNamePD, secretKeyPD, "pdkey")
+
+	secretList := &corev1.SecretList{}
+	if err := reconciler.Client.List(context.TODO(), secretList); err != nil {
+		t.Fatalf("Could not list secrets: %v", err)
+	}
+
+	// nothing to mount inline, so it isn't created
+	_, err := reconciler.syncAlertmanagerSecretMounts(reqLogger, secretList, credentialsModeInline)
+	assertEquals(t, nil, err, "Unexpected err")
+	cm := &corev1.ConfigMap{}
+	err = reconciler.Client.Get(context.TODO(), client.ObjectKey{Namespace: config.OperatorNamespace, Name: cmNameClusterMonitoringConfig}, cm)
+	assertTrue(t, errors.IsNotFound(err), "ConfigMap was created inline")
+
+	pending, err := reconciler.syncAlertmanagerSecretMounts(reqLogger, secretList, credentialsModeFiles)
+	assertEquals(t, nil, err, "Unexpected err")
+	assertTrue(t, pending, "Mounts should be pending without a StatefulSet")
+	err = reconciler.Client.Get(context.TODO(), client.ObjectKey{Namespace: config.OperatorNamespace, Name: cmNameClusterMonitoringConfig}, cm)
+	assertEquals(t, nil, err, "Unexpected err")
+	assertEquals(t, "alertmanagerMain:\n  secrets:\n    - pd-secret\n", cm.Data[cmKeyClusterMonitoringConfig], "Created config")
+}
+
+func Test_syncAlertmanagerSecretMounts_KeepsOtherContent(t *testing.T) {
+	reconciler := createReconciler(t, nil)
+	createNamespace(reconciler, t)
+	monitoringConfig := "# managed by the platform team\nprometheusK8s:\n  retention: 15d # two weeks\nalertmanagerMain:\n  enableUserAlertmanagerConfig: true\n  secrets:\n  - other-secret # keep me\n"
+	createConfigMap(reconciler, cmNameClusterMonitoringConfig, cmKeyClusterMonitoringConfig, monitoringConfig)
+	createSecret(reconciler, secretNamePD, secretKeyPD, "pdkey")
+
+	secretList := &corev1.SecretList{}
+	if err := reconciler.Client.List(context.TODO(), secretList); err != nil {
+		t.Fatalf("Could not list secrets: %v", err)
+	}
+
+	readConfigMap := func() *corev1.ConfigMap {
+		cm := &corev1.ConfigMap{}
+		if err := reconciler.Client.Get(context.TODO(), client.ObjectKey{Namespace: config.OperatorNamespace, Name: cmNameClusterMonitoringConfig}, cm); err != nil {
+			t.Fatalf("Could not get %s: %v", cmNameClusterMonitoringConfig, err)
+		}
+		return cm
+	}
+
+	// nothing to mount inline, so the ConfigMap isn't touched
+	before := readConfigMap()
+	_, err := reconciler.syncAlertmanagerSecretMounts(reqLogger, secretList, credentialsModeInline)
+	assertEquals(t, nil, err, "Unexpected err")
+	after := readConfigMap()
+	assertEquals(t, before.ResourceVersion, after.ResourceVersion, "ResourceVersion")
+	assertEquals(t, monitoringConfig, after.Data[cmKeyClusterMonitoringConfig], "Unchanged config")
+
+	_, err = reconciler.syncAlertmanagerSecretMounts(reqLogger, secretList, credentialsModeFiles)
+	assertEquals(t, nil, err, "Unexpected err")
+	assertEquals(t, []string{"other-secret", secretNamePD}, readMountedSecrets(t, reconciler), "Mounted secrets")
+	updated := readConfigMap().Data[cmKeyClusterMonitoringConfig]
+	for _, kept := range []string{"# managed by the platform team", "retention: 15d # two weeks", "enableUserAlertmanagerConfig: true", "- other-secret # keep me"} {
+		assertTrue(t, strings.Contains(updated, kept), "Kept "+kept)
+	}
+}
+
+func Test_Reconcile_CredentialFiles(t *testing.T) {
+	pdKey := "asdaidsgadfi9853"
+	wdURL := "http://theinterwebs/asdf"
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockReadiness := readiness.NewMockInterface(ctrl)
+	mockReadiness.EXPECT().IsReady().Times(2).Return(true, nil)
+	mockReadiness.EXPECT().Result().Times(2).Return(reconcile.Result{})
+	reconciler := createReconciler(t, mockReadiness)
+	createNamespace(reconciler, t)
+	createSecret(reconciler, secretNamePD, secretKeyPD, pdKey)
+	createSecret(reconciler, secretNameDMS, secretKeyDMS, wdURL)
+	createConfigMap(reconciler, cmNameOperatorConfig, cmKeyCredentialsMode, credentialsModeFiles)
+	createConfigMap(reconciler, cmNameClusterMonitoringConfig, cmKeyClusterMonitoringConfig, "prometheusK8s:\n  retention: 15d\n")
+	createClusterVersion(reconciler)
+	createClusterProxy(reconciler)
+
+	// The secrets aren't mounted yet: the credentials stay inline and we check again shortly.
+	req := createReconcileRequest(reconciler, secretNamePD)
+	ret, err := reconciler.Reconcile(context.TODO(), *req)
+	assertEquals(t, nil, err, "Unexpected err")
+	assertEquals(t, reconcile.Result{Requeue: true, RequeueAfter: credentialMountRequeueDelay}, ret, "Unexpected result")
+	assertEquals(t, []string{secretNamePD, secretNameDMS}, readMountedSecrets(t, reconciler), "Mounted secrets")
+	for _, receiver := range readAlertManagerConfig(reconciler, req).Receivers {
+		for _, pdc := range receiver.PagerdutyConfigs {
+			assertEquals(t, alertmanager.Secret(pdKey), pdc.RoutingKey, receiver.Name+" RoutingKey")
+		}
+	}
+
+	// Once mounted, the credentials are referenced by file.
+	createAlertmanagerStatefulSet(reconciler, secretNamePD, secretNameDMS)
+	ret, err = reconciler.Reconcile(context.TODO(), *req)
+	assertEquals(t, nil, err, "Unexpected err")
+	assertEquals(t, reconcile.Result{}, ret, "Unexpected result")
+
+	secret := &corev1.Secret{}
+	if err := reconciler.Client.Get(context.TODO(), client.ObjectKey{Namespace: config.OperatorNamespace, Name: secretNameAlertmanager}, secret); err != nil {
+		t.Fatalf("Could not get %s: %v", secretNameAlertmanager, err)
+	}
+	rendered := string(secret.Data["alertmanager.yaml"])
+	assertTrue(t, !strings.Contains(rendered, pdKey), "alertmanager.yaml contains the routing key")
+	assertTrue(t, !strings.Contains(rendered, wdURL), "alertmanager.yaml contains the DMS URL")
+	assertTrue(t, strings.Contains(rendered, "routing_key_file: /etc/alertmanager/secrets/pd-secret/PAGERDUTY_KEY"), "Missing routing_key_file")
+	assertTrue(t, strings.Contains(rendered, "url_file: /etc/alertmanager/secrets/dms-secret/SNITCH_URL"), "Missing url_file")
+}
@@ -0,0 +1,236 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	// number of unchanged lines shown around each change
+	diffContext = 3
+
+	// beyond this many edits the diff is reported as a full replacement
+	diffMaxEdits = 2000
+)
+
+type diffOp int
+
+const (
+	diffEqual diffOp = iota
+	diffDelete
+	diffInsert
+)
+
+type diffLine struct {
+	op   diffOp
+	text string
+}
+
+// unifiedDiff returns a unified diff of the lines of a and b, or "" if they are equal.
+func unifiedDiff(a, b, nameA, nameB string) string {
+	if a == b {
+		return ""
+	}
+	lines := diffLines(splitLines(a), splitLines(b))
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "--- %s\n+++ %s\n", nameA, nameB)
+
+	// line numbers in a and b of the current position in lines
+	lineA, lineB := 1, 1
+	for i := 0; i < len(lines); {
+		if lines[i].op == diffEqual {
+			lineA++
+			lineB++
+			i++
+			continue
+		}
+
+		// Found a change: extend the hunk until there are more than 2*diffContext unchanged lines.
+		start := i - diffContext
+		if start < 0 {
+			start = 0
+		}
+		end := i
+		for end < len(lines) {
+			if lines[end].op != diffEqual {
+				end++
+				continue
+			}
+			run := end
+			for run < len(lines) && lines[run].op == diffEqual {
+				run++
+			}
+			if run == len(lines) || run-end > 2*diffContext {
+				end += diffContext
+				if end > len(lines) {
+					end = len(lines)
+				}
+				break
+			}
+			end = run
+		}
+
+		startA, startB := lineA-(i-start), lineB-(i-start)
+		countA, countB := 0, 0
+		var hunk strings.Builder
+		for _, l := range lines[start:end] {
+			switch l.op {
+			case diffEqual:
+				countA++
+				countB++
+				hunk.WriteString(" " + l.text + "\n")
+			case diffDelete:
+				countA++
+				hunk.WriteString("-" + l.text + "\n")
+			case diffInsert:
+				countB++
+				hunk.WriteString("+" + l.text + "\n")
+			}
+		}
+		fmt.Fprintf(&sb, "@@ -%s +%s @@\n", hunkRange(startA, countA), hunkRange(startB, countB))
+		sb.WriteString(hunk.String())
+
+		lineA, lineB = startA+countA, startB+countB
+		i = end
+	}
+	return sb.String()
+}
+
+// hunkRange formats the range of a hunk header the way diff -u does.
+func hunkRange(start, count int) string {
+	if count == 0 {
+		start--
+	}
+	if count == 1 {
+		return fmt.Sprintf("%d", start)
+	}
+	return fmt.Sprintf("%d,%d", start, count)
+}
+
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
+
+// diffLines computes the shortest edit script turning a into b using Myers' algorithm.
+func diffLines(a, b []string) []diffLine {
+	// Strip the common prefix and suffix, they usually make up most of the input.
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	lines := []diffLine{}
+	for _, l := range a[:prefix] {
+		lines = append(lines, diffLine{diffEqual, l})
+	}
+	lines = append(lines, myers(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix])...)
+	for _, l := range a[len(a)-suffix:] {
+		lines = append(lines, diffLine{diffEqual, l})
+	}
+	return lines
+}
+
+func myers(a, b []string) []diffLine {
+	n, m := len(a), len(b)
+	max := n + m
+	if max == 0 {
+		return nil
+	}
+	offset := max + 1
+	v := make([]int, 2*max+3)
+	trace := [][]int{}
+
+	found := false
+	for d := 0; d <= max && d <= diffMaxEdits && !found; d++ {
+		trace = append(trace, append([]int(nil), v...))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				found = true
+				break
+			}
+		}
+	}
+
+	if !found {
+		// Too many changes to be worth a minimal diff: replace everything.
+		lines := make([]diffLine, 0, n+m)
+		for _, l := range a {
+			lines = append(lines, diffLine{diffDelete, l})
+		}
+		for _, l := range b {
+			lines = append(lines, diffLine{diffInsert, l})
+		}
+		return lines
+	}
+
+	// Walk the trace backwards to recover the edit script.
+	reversed := []diffLine{}
+	x, y := n, m
+	for d := len(trace) - 1; d >= 0; d-- {
+		v := trace[d]
+		k := x - y
+		var prevK int
+		if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := v[offset+prevK]
+		prevY := prevX - prevK
+		for x > prevX && y > prevY {
+			reversed = append(reversed, diffLine{diffEqual, a[x-1]})
+			x--
+			y--
+		}
+		if d > 0 {
+			if x == prevX {
+				reversed = append(reversed, diffLine{diffInsert, b[y-1]})
+			} else {
+				reversed = append(reversed, diffLine{diffDelete, a[x-1]})
+			}
+		}
+		x, y = prevX, prevY
+	}
+
+	lines := make([]diffLine, len(reversed))
+	for i, l := range reversed {
+		lines[len(reversed)-1-i] = l
+	}
+	return lines
+}
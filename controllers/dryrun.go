@@ -0,0 +1,199 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/go-logr/logr"
+	"gopkg.in/yaml.v2"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	"github.com/openshift/configure-alertmanager-operator/config"
+	alertmanager "github.com/openshift/configure-alertmanager-operator/pkg/types"
+)
+
+const (
+	// configmap holding the result of the last dry run
+	cmNameDryRun = config.OperatorName + "-dry-run"
+
+	// dry run configmap key for the diff against the live config
+	cmKeyDryRunDiff = "diff"
+
+	// dry run configmap key for the redacted desired config
+	cmKeyDryRunConfig = "alertmanager.yaml"
+
+	// reason of the Event recorded on alertmanager-main by a dry run
+	eventReasonDryRun = "DryRunDiff"
+
+	// Events messages are truncated by the API server beyond this length
+	maxEventMessageLength = 1024
+)
+
+// DiffOnce runs a single dry-run reconcile of alertmanager-main and writes the diff to out.
+// Nothing is written to the cluster.
+func (r *SecretReconciler) DiffOnce(ctx context.Context, out io.Writer) error {
+	r.DryRun = true
+	r.DiffWriter = out
+	request := ctrl.Request{NamespacedName: types.NamespacedName{Namespace: config.OperatorNamespace, Name: secretNameAlertmanager}}
+	_, err := r.Reconcile(ctx, request)
+	return err
+}
+
+// reportConfigDiff is used instead of applyAlertManagerConfig in dry-run mode.
+// It diffs the redacted config a reconcile would write, i.e. the desired config unless a rollback or a
+// revert applies, against the redacted live config and reports the result in the logs, an Event on the
+// alertmanager-main secret and the dry run configMap.
+func (r *SecretReconciler) reportConfigDiff(reqLogger logr.Logger, amconfig *alertmanager.Config, cmList *corev1.ConfigMapList) {
+	amconfigbyte, _, err := r.selectConfigToWrite(reqLogger, amconfig, cmList)
+	if err != nil {
+		reqLogger.Error(err, "ERROR: failed to marshal Alertmanager config")
+		return
+	}
+	// Round-trip through the config types, like the live config, to know where the credentials are.
+	toWrite := &alertmanager.Config{}
+	if err := yaml.Unmarshal(amconfigbyte, toWrite); err != nil {
+		reqLogger.Error(err, "ERROR: Unable to parse the Alertmanager config to write")
+		return
+	}
+	desired, err := marshalRedacted(toWrite)
+	if err != nil {
+		reqLogger.Error(err, "ERROR: failed to marshal Alertmanager config")
+		return
+	}
+
+	live, liveSecret, err := r.readLiveAlertManagerConfig(reqLogger)
+	if err != nil {
+		reqLogger.Error(err, "ERROR: Could not read secret alertmanager-main")
+		return
+	}
+
+	diff := unifiedDiff(live, desired, "live/"+secretNameAlertmanager, "desired/"+secretNameAlertmanager)
+	summary := "Dry run: no changes to secret alertmanager-main"
+	if diff != "" {
+		summary = fmt.Sprintf("Dry run: secret alertmanager-main would change (%s)", diffStat(diff))
+	}
+	reqLogger.Info("INFO: "+summary, "Diff", diff)
+
+	if r.DiffWriter != nil {
+		// one-shot mode: leave the cluster untouched
+		if diff == "" {
+			fmt.Fprintln(r.DiffWriter, summary)
+		} else {
+			fmt.Fprint(r.DiffWriter, diff)
+		}
+		return
+	}
+
+	if r.Recorder != nil && liveSecret != nil {
+		message := summary
+		if diff != "" {
+			message = truncate(summary+"\n"+diff, maxEventMessageLength,
+				fmt.Sprintf("\n... see ConfigMap %s/%s for the full diff", config.OperatorNamespace, cmNameDryRun))
+		}
+		r.Recorder.Event(liveSecret, corev1.EventTypeNormal, eventReasonDryRun, message)
+	}
+
+	if err := r.writeDryRunConfigMap(diff, desired); err != nil {
+		reqLogger.Error(err, "ERROR: Could not write dry run configMap", "ConfigMap", cmNameDryRun)
+	}
+}
+
+// readLiveAlertManagerConfig returns the redacted config of the alertmanager-main secret, and the secret
+// itself. Both are empty if the secret doesn't exist yet.
+func (r *SecretReconciler) readLiveAlertManagerConfig(reqLogger logr.Logger) (string, *corev1.Secret, error) {
+	secret := &corev1.Secret{}
+	objectKey := types.NamespacedName{Name: secretNameAlertmanager, Namespace: config.OperatorNamespace}
+	if err := r.Client.Get(context.TODO(), objectKey, secret); err != nil {
+		if errors.IsNotFound(err) {
+			return "", nil, nil
+		}
+		return "", nil, err
+	}
+
+	// Round-trip through the config types so the live and desired configs are rendered the same way,
+	// and so we know where the credentials are.
+	liveConfig := &alertmanager.Config{}
+	if err := yaml.Unmarshal(secret.Data["alertmanager.yaml"], liveConfig); err != nil {
+		reqLogger.Info("WARNING: Unable to parse the live Alertmanager config; diffing against an empty config", "Error", err.Error())
+		return "", secret, nil
+	}
+	live, err := marshalRedacted(liveConfig)
+	return live, secret, err
+}
+
+// writeDryRunConfigMap stores the diff and the redacted desired config in the dry run configMap.
+func (r *SecretReconciler) writeDryRunConfigMap(diff, desired string) error {
+	cm := &corev1.ConfigMap{}
+	objectKey := types.NamespacedName{Name: cmNameDryRun, Namespace: config.OperatorNamespace}
+	err := r.Client.Get(context.TODO(), objectKey, cm)
+	if err != nil && !errors.IsNotFound(err) {
+		return err
+	}
+	data := map[string]string{
+		cmKeyDryRunDiff:   diff,
+		cmKeyDryRunConfig: desired,
+	}
+	if errors.IsNotFound(err) {
+		cm = &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      cmNameDryRun,
+				Namespace: config.OperatorNamespace,
+			},
+			Data: data,
+		}
+		return r.Client.Create(context.TODO(), cm)
+	}
+	cm.Data = data
+	return r.Client.Update(context.TODO(), cm)
+}
+
+// marshalRedacted renders the config with all credentials replaced.
+func marshalRedacted(amconfig *alertmanager.Config) (string, error) {
+	raw, err := yaml.Marshal(amconfig.Redacted())
+	return string(raw), err
+}
+
+// diffStat summarizes a unified diff as "+added/-removed lines".
+func diffStat(diff string) string {
+	added, removed := 0, 0
+	for _, line := range strings.Split(diff, "\n") {
+		switch {
+		case strings.HasPrefix(line, "+++"), strings.HasPrefix(line, "---"):
+		case strings.HasPrefix(line, "+"):
+			added++
+		case strings.HasPrefix(line, "-"):
+			removed++
+		}
+	}
+	return fmt.Sprintf("+%d/-%d lines", added, removed)
+}
+
+// truncate shortens s to at most max bytes, ending it with suffix if it had to be shortened.
+func truncate(s string, max int, suffix string) string {
+	if len(s) <= max {
+		return s
+	}
+	return s[:max-len(suffix)] + suffix
+}
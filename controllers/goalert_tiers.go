@@ -0,0 +1,176 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/go-logr/logr"
+	"gopkg.in/yaml.v2"
+	corev1 "k8s.io/api/core/v1"
+
+	alertmanager "github.com/openshift/configure-alertmanager-operator/pkg/types"
+)
+
+const (
+	// goalert-secret key prefix of the URL of a GoAlert tier, e.g. GOALERT_URL_BUSINESS_HOURS for the business-hours tier
+	secretKeyGoalertTierPrefix = "GOALERT_URL_" // #nosec G101
+
+	// goalert-secret key for the rules routing alerts to the GoAlert tiers
+	secretKeyGoalertRouting = "GOALERT_ROUTING"
+
+	// The tiers of GOALERT_URL_LOW and GOALERT_URL_HIGH, which are always configured
+	goalertTierLow  = "low"
+	goalertTierHigh = "high"
+)
+
+var goalertTierNameRE = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+
+// goalertTier is a GoAlert service alerts can be routed to.
+type goalertTier struct {
+	name string
+	url  string
+}
+
+// goalertTierRoute routes the alerts of a severity from the namespaces matching a regular expression to
+// the receiver of a tier.
+type goalertTierRoute struct {
+	severity  string
+	namespace string
+	receiver  string
+}
+
+// goalertRouting is how alerts are routed to the GoAlert tiers.
+type goalertRouting struct {
+	// tiers are the tiers other than low and high, sorted by name.
+	tiers []goalertTier
+	// receiverClasses maps each receiver class to the receiver of a tier.
+	receiverClasses map[string]string
+	// namespaced are routed ahead of the receiver classes, in order.
+	namespaced []goalertTierRoute
+}
+
+// defaultGoalertRouting pages for critical and error alerts through the high tier, and sends the others to the low tier.
+func defaultGoalertRouting() *goalertRouting {
+	return &goalertRouting{
+		receiverClasses: map[string]string{
+			receiverClassCritical: receiverGoAlertHigh,
+			receiverClassError:    receiverGoAlertHigh,
+			receiverClassWarning:  receiverGoAlertLow,
+			receiverClassDefault:  receiverGoAlertLow,
+		},
+	}
+}
+
+// goalertTierReceiver returns the name of the receiver of a tier. The low and high tiers keep the names
+// their receivers had before tiers could be added.
+func goalertTierReceiver(tier string) string {
+	switch tier {
+	case goalertTierLow:
+		return receiverGoAlertLow
+	case goalertTierHigh:
+		return receiverGoAlertHigh
+	default:
+		return "goalert-" + tier
+	}
+}
+
+// goalertTierKey returns the goalert-secret key of the URL of a tier.
+func goalertTierKey(tier string) string {
+	return secretKeyGoalertTierPrefix + strings.ToUpper(strings.ReplaceAll(tier, "-", "_"))
+}
+
+// parseGoalertRouting reads the tiers beyond low and high from the GOALERT_URL_<TIER> keys of goalert-secret,
+// and the rules routing alerts to the tiers from its GOALERT_ROUTING key. Rules override the default routing
+// in order; invalid tiers and rules are logged and ignored.
+func parseGoalertRouting(reqLogger logr.Logger, secretList *corev1.SecretList) *goalertRouting {
+	routing := defaultGoalertRouting()
+
+	var data map[string][]byte
+	for _, secret := range secretList.Items {
+		if secret.Name == secretNameGoalert {
+			data = secret.Data
+		}
+	}
+
+	known := map[string]bool{goalertTierLow: true, goalertTierHigh: true}
+	for key, value := range data {
+		if !strings.HasPrefix(key, secretKeyGoalertTierPrefix) || key == secretKeyGoalertLow || key == secretKeyGoalertHigh {
+			continue
+		}
+		tier := strings.ToLower(strings.ReplaceAll(strings.TrimPrefix(key, secretKeyGoalertTierPrefix), "_", "-"))
+		if !goalertTierNameRE.MatchString(tier) || goalertTierReceiver(tier) == receiverGoAlertHeartbeat {
+			reqLogger.Info("WARNING: Ignoring GoAlert tier with an invalid name", "Key", key)
+			continue
+		}
+		if len(value) == 0 {
+			reqLogger.Info("WARNING: Ignoring GoAlert tier without a URL", "Tier", tier)
+			continue
+		}
+		routing.tiers = append(routing.tiers, goalertTier{name: tier, url: string(value)})
+		known[tier] = true
+	}
+	sort.Slice(routing.tiers, func(i, j int) bool { return routing.tiers[i].name < routing.tiers[j].name })
+
+	raw := data[secretKeyGoalertRouting]
+	if len(raw) == 0 {
+		return routing
+	}
+	var rules []alertmanager.GoAlertRoutingRule
+	if err := yaml.Unmarshal(raw, &rules); err != nil {
+		reqLogger.Info("WARNING: Unable to unmarshal the GoAlert routing; using the default routing", "Secret", secretNameGoalert, "Error", err.Error())
+		return routing
+	}
+	for _, rule := range rules {
+		if err := validateGoalertRoutingRule(rule, known); err != nil {
+			reqLogger.Info("WARNING: Ignoring invalid GoAlert routing rule", "Rule", fmt.Sprintf("%+v", rule), "Error", err.Error())
+			continue
+		}
+		receiver := goalertTierReceiver(rule.Tier)
+		if rule.Namespace == "" {
+			routing.receiverClasses[rule.Severity] = receiver
+		} else {
+			routing.namespaced = append(routing.namespaced, goalertTierRoute{severity: rule.Severity, namespace: rule.Namespace, receiver: receiver})
+		}
+	}
+	return routing
+}
+
+func validateGoalertRoutingRule(rule alertmanager.GoAlertRoutingRule, tiers map[string]bool) error {
+	switch rule.Severity {
+	case receiverClassCritical, receiverClassError, receiverClassWarning:
+	case receiverClassDefault:
+		// the namespace routes only route alerts by severity
+		if rule.Namespace != "" {
+			return fmt.Errorf("the %s severity cannot be restricted to namespaces", rule.Severity)
+		}
+	default:
+		return fmt.Errorf("unknown severity %q", rule.Severity)
+	}
+	if !tiers[rule.Tier] {
+		return fmt.Errorf("unknown tier %q", rule.Tier)
+	}
+	if rule.Namespace != "" {
+		if _, err := regexp.Compile(rule.Namespace); err != nil {
+			return fmt.Errorf("invalid namespace regex %q: %w", rule.Namespace, err)
+		}
+	}
+	return nil
+}
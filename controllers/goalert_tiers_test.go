@@ -0,0 +1,136 @@
+package controllers
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/openshift/configure-alertmanager-operator/config"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func goalertSecretList(data map[string]string) *corev1.SecretList {
+	secret := corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: secretNameGoalert, Namespace: config.OperatorNamespace},
+		Data:       map[string][]byte{},
+	}
+	for key, value := range data {
+		secret.Data[key] = []byte(value)
+	}
+	return &corev1.SecretList{Items: []corev1.Secret{secret}}
+}
+
+var exampleGoalertTiers = map[string]string{
+	secretKeyGoalertLow:          "https://dummy-galow-url",
+	secretKeyGoalertHigh:         "https://dummy-gahigh-url",
+	secretKeyGoalertHeartbeat:    "https://dummy-gaheartbeat-url",
+	"GOALERT_URL_CRITICAL":       "https://dummy-gacritical-url",
+	"GOALERT_URL_BUSINESS_HOURS": "https://dummy-gabusiness-url",
+	secretKeyGoalertRouting: `
+- severity: critical
+  tier: critical
+- severity: warning
+  namespace: ^openshift-logging$
+  tier: business-hours
+`,
+}
+
+func Test_parseGoalertRouting(t *testing.T) {
+	// no tiers or routing rules
+	routing := parseGoalertRouting(reqLogger, goalertSecretList(map[string]string{secretKeyGoalertLow: "https://dummy-galow-url", secretKeyGoalertHigh: "https://dummy-gahigh-url"}))
+	assertEquals(t, defaultGoalertRouting(), routing, "Routing without tiers")
+	routing = parseGoalertRouting(reqLogger, &corev1.SecretList{})
+	assertEquals(t, defaultGoalertRouting(), routing, "Routing without goalert-secret")
+
+	data := map[string]string{
+		"GOALERT_URL_HEARTBEAT": "https://dummy-reserved-url",
+		"GOALERT_URL_":          "https://dummy-unnamed-url",
+		"GOALERT_URL_EMPTY":     "",
+		secretKeyGoalertRouting: exampleGoalertTiers[secretKeyGoalertRouting] + `
+- severity: info
+  tier: low
+- severity: error
+  tier: unknown
+- severity: default
+  namespace: ^openshift-logging$
+  tier: low
+- severity: error
+  namespace: (invalid
+  tier: low
+`,
+	}
+	for key, value := range exampleGoalertTiers {
+		if _, ok := data[key]; !ok {
+			data[key] = value
+		}
+	}
+	routing = parseGoalertRouting(reqLogger, goalertSecretList(data))
+	assertEquals(t, []goalertTier{
+		{name: "business-hours", url: "https://dummy-gabusiness-url"},
+		{name: "critical", url: "https://dummy-gacritical-url"},
+	}, routing.tiers, "Tiers")
+	assertEquals(t, map[string]string{
+		receiverClassCritical: "goalert-critical",
+		receiverClassError:    receiverGoAlertHigh,
+		receiverClassWarning:  receiverGoAlertLow,
+		receiverClassDefault:  receiverGoAlertLow,
+	}, routing.receiverClasses, "Receiver classes")
+	assertEquals(t, []goalertTierRoute{
+		{severity: "warning", namespace: "^openshift-logging$", receiver: "goalert-business-hours"},
+	}, routing.namespaced, "Namespaced routes")
+}
+
+func Test_createSubroutes_GoAlertTiers(t *testing.T) {
+	routing := parseGoalertRouting(reqLogger, goalertSecretList(exampleGoalertTiers))
+	route := createSubroutes(defaultNamespaces, nil, nil, GoAlert, defaultRouteSettings(), routing)
+
+	tests := []struct {
+		namespace string
+		severity  string
+		expected  string
+	}{
+		{namespace: "openshift-monitoring", severity: "critical", expected: "goalert-critical"},
+		{namespace: "openshift-monitoring", severity: "error", expected: receiverGoAlertHigh},
+		{namespace: "openshift-monitoring", severity: "warning", expected: receiverGoAlertLow},
+		{namespace: "openshift-logging", severity: "warning", expected: "goalert-business-hours"},
+		{namespace: "openshift-logging", severity: "critical", expected: "goalert-critical"},
+		{namespace: "openshift-customer-monitoring", severity: "warning", expected: receiverNull},
+		{namespace: "customer", severity: "critical", expected: receiverNull},
+	}
+	for _, tt := range tests {
+		alert := map[string]string{"alertname": "KubePodCrashLooping", "namespace": tt.namespace, "severity": tt.severity, "prometheus": "openshift-monitoring/k8s"}
+		routedTo, _ := simulateRoute(t, route, alert, "", nil)
+		assertEquals(t, tt.expected, routedTo, fmt.Sprintf("Alert %v", alert))
+	}
+
+	// the namespaced rule is rendered once, not per entry of the namespace list
+	namespaced := 0
+	for _, subroute := range route.Routes {
+		if subroute.Receiver == "goalert-business-hours" {
+			namespaced++
+		}
+	}
+	assertEquals(t, 1, namespaced, "Namespaced routes")
+}
+
+func Test_createAlertManagerConfig_GoAlertTiers(t *testing.T) {
+	routing := parseGoalertRouting(reqLogger, goalertSecretList(exampleGoalertTiers))
+	amconfig := createAlertManagerConfig(reqLogger, "", "https://dummy-galow-url", "https://dummy-gahigh-url", "", "", "", exampleClusterId, exampleProxy, defaultNamespaces, alertmanagerConfigOptions{goalert: routing})
+
+	urls := map[string]string{}
+	for _, receiver := range amconfig.Receivers {
+		for _, whc := range receiver.WebhookConfigs {
+			urls[receiver.Name] = string(whc.URL)
+		}
+	}
+	assertEquals(t, "https://dummy-gacritical-url", urls["goalert-critical"], "Critical tier URL")
+	assertEquals(t, "https://dummy-gabusiness-url", urls["goalert-business-hours"], "Business hours tier URL")
+	assertEquals(t, "https://dummy-gahigh-url", urls[receiverGoAlertHigh], "High tier URL")
+
+	useCredentialFiles(amconfig, map[string]bool{secretNameGoalert: true})
+	for _, receiver := range amconfig.Receivers {
+		if receiver.Name == "goalert-business-hours" {
+			assertEquals(t, "/etc/alertmanager/secrets/goalert-secret/GOALERT_URL_BUSINESS_HOURS", receiver.WebhookConfigs[0].URLFile, "Business hours tier URLFile")
+		}
+	}
+}
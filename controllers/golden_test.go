@@ -0,0 +1,147 @@
+package controllers
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"gopkg.in/yaml.v2"
+
+	"github.com/openshift/configure-alertmanager-operator/config"
+	alertmanager "github.com/openshift/configure-alertmanager-operator/pkg/types"
+)
+
+// Regenerate the golden files with `go test ./controllers -run Test_createAlertManagerConfig_Golden -update`
+// and review the changes like any other code change.
+var updateGolden = flag.Bool("update", false, "update the golden files in testdata/golden")
+
+func Test_createAlertManagerConfig_Golden(t *testing.T) {
+	pdKey := "pd-routing-key"
+	wdURL := "https://nosnch.in/dms-token"
+	gaLowURL := "https://goalert.example.com/api/v2/generic/incoming?token=low"
+	gaHighURL := "https://goalert.example.com/api/v2/generic/incoming?token=high"
+	gaHeartbeatURL := "https://goalert.example.com/api/v2/heartbeat/heartbeat-token"
+	oaURL := "http://ocm-agent.openshift-ocm-agent-operator.svc.cluster.local:9999/alertmanager-receiver"
+	namespaces := []string{"^openshift-monitoring$", "^openshift-logging$", "^redhat-.*$"}
+
+	tests := []struct {
+		name           string
+		pagerdutyKey   string
+		goalertLow     string
+		goalertHigh    string
+		goalertHeart   string
+		watchdogURL    string
+		ocmAgentURL    string
+		proxy          string
+		fedramp        bool
+		namespaceList  []string
+		mountedSecrets map[string]bool
+		installPaging  []alertmanager.InstallPagingRule
+	}{
+		{
+			name:          "empty",
+			namespaceList: defaultNamespaces,
+		},
+		{
+			name:          "pagerduty-dms",
+			pagerdutyKey:  pdKey,
+			watchdogURL:   wdURL,
+			namespaceList: defaultNamespaces,
+		},
+		{
+			name:          "goalert-dms",
+			goalertLow:    gaLowURL,
+			goalertHigh:   gaHighURL,
+			goalertHeart:  gaHeartbeatURL,
+			watchdogURL:   wdURL,
+			namespaceList: defaultNamespaces,
+		},
+		{
+			name:          "all-integrations",
+			pagerdutyKey:  pdKey,
+			goalertLow:    gaLowURL,
+			goalertHigh:   gaHighURL,
+			goalertHeart:  gaHeartbeatURL,
+			watchdogURL:   wdURL,
+			ocmAgentURL:   oaURL,
+			namespaceList: namespaces,
+		},
+		{
+			name:          "all-integrations-proxy",
+			pagerdutyKey:  pdKey,
+			goalertLow:    gaLowURL,
+			goalertHigh:   gaHighURL,
+			goalertHeart:  gaHeartbeatURL,
+			watchdogURL:   wdURL,
+			ocmAgentURL:   oaURL,
+			proxy:         exampleProxy,
+			namespaceList: namespaces,
+		},
+		{
+			name:           "credential-files",
+			pagerdutyKey:   pdKey,
+			goalertLow:     gaLowURL,
+			goalertHigh:    gaHighURL,
+			goalertHeart:   gaHeartbeatURL,
+			watchdogURL:    wdURL,
+			ocmAgentURL:    oaURL,
+			namespaceList:  namespaces,
+			mountedSecrets: map[string]bool{secretNamePD: true, secretNameGoalert: true, secretNameDMS: true},
+		},
+		{
+			name:          "fedramp",
+			pagerdutyKey:  pdKey,
+			watchdogURL:   wdURL,
+			proxy:         exampleProxy,
+			fedramp:       true,
+			namespaceList: namespaces,
+		},
+		{
+			name:          "install-paging",
+			pagerdutyKey:  pdKey,
+			goalertLow:    gaLowURL,
+			goalertHigh:   gaHighURL,
+			watchdogURL:   wdURL,
+			namespaceList: namespaces,
+			installPaging: []alertmanager.InstallPagingRule{
+				{Matchers: []string{`alertname=~".*SRE"`, `severity="critical"`}},
+				{Matchers: []string{`alertname=~"etcd.*"`}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config.SetFedramp(tt.fedramp)
+			defer config.SetFedramp(false)
+
+			amconfig := createAlertManagerConfig(reqLogger, tt.pagerdutyKey, tt.goalertLow, tt.goalertHigh, tt.goalertHeart, tt.watchdogURL, tt.ocmAgentURL, exampleClusterId, tt.proxy, tt.namespaceList, alertmanagerConfigOptions{installPaging: tt.installPaging})
+			if tt.mountedSecrets != nil {
+				useCredentialFiles(amconfig, tt.mountedSecrets)
+			}
+			actual, err := yaml.Marshal(amconfig)
+			if err != nil {
+				t.Fatalf("Could not marshal config: %v", err)
+			}
+
+			goldenFile := filepath.Join("testdata", "golden", tt.name+".yaml")
+			if *updateGolden {
+				if err := os.MkdirAll(filepath.Dir(goldenFile), 0o755); err != nil {
+					t.Fatalf("Could not create golden file directory: %v", err)
+				}
+				if err := os.WriteFile(goldenFile, actual, 0o600); err != nil {
+					t.Fatalf("Could not update golden file: %v", err)
+				}
+			}
+
+			expected, err := os.ReadFile(goldenFile) // #nosec G304
+			if err != nil {
+				t.Fatalf("Could not read golden file, run with -update to create it: %v", err)
+			}
+			if diff := unifiedDiff(string(expected), string(actual), goldenFile, "generated"); diff != "" {
+				t.Errorf("Generated config does not match %s, run with -update if the change is intended:\n%s", goldenFile, diff)
+			}
+		})
+	}
+}
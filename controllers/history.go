@@ -0,0 +1,233 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/go-logr/logr"
+	"gopkg.in/yaml.v2"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	"github.com/openshift/configure-alertmanager-operator/config"
+	"github.com/openshift/configure-alertmanager-operator/pkg/metrics"
+)
+
+const (
+	// secret holding the last configs written into alertmanager-main. It is a Secret rather than
+	// a ConfigMap because the configs contain the routing keys and URLs.
+	secretNameHistory = config.OperatorName + "-history"
+
+	// history secret key for the index of the revisions
+	secretKeyHistoryIndex = "history.yaml"
+
+	// number of revisions kept in the history
+	historyLimit = 10
+
+	// RollbackAnnotation on the operator configMap pins alertmanager-main to a revision of the history
+	// until it is removed.
+	RollbackAnnotation = "configure-alertmanager-operator.managed.openshift.io/rollback-revision"
+
+	// Event reasons recorded on the operator configMap
+	eventReasonRollbackApplied = "ConfigRollbackApplied"
+	eventReasonRollbackEnded   = "ConfigRollbackEnded"
+	eventReasonRollbackInvalid = "ConfigRollbackInvalid"
+)
+
+// historyEntry describes a revision of the history.
+type historyEntry struct {
+	Revision        int    `yaml:"revision"`
+	Hash            string `yaml:"hash"`
+	Timestamp       string `yaml:"timestamp"`
+	OperatorVersion string `yaml:"operator_version"`
+	// Trigger is the object whose reconcile wrote the revision.
+	Trigger string `yaml:"trigger"`
+}
+
+// historyKey is the history secret key of a revision's config.
+func historyKey(revision int) string {
+	return fmt.Sprintf("revision-%d.yaml", revision)
+}
+
+func configHash(amconfigbyte []byte) string {
+	sum := sha256.Sum256(amconfigbyte)
+	return hex.EncodeToString(sum[:])
+}
+
+// readHistory returns the history secret and its index. The secret is nil if it doesn't exist yet.
+func (r *SecretReconciler) readHistory() (*corev1.Secret, []historyEntry, error) {
+	secret := &corev1.Secret{}
+	objectKey := types.NamespacedName{Name: secretNameHistory, Namespace: config.OperatorNamespace}
+	if err := r.Client.Get(context.TODO(), objectKey, secret); err != nil {
+		if errors.IsNotFound(err) {
+			return nil, nil, nil
+		}
+		return nil, nil, err
+	}
+	var entries []historyEntry
+	if err := yaml.Unmarshal(secret.Data[secretKeyHistoryIndex], &entries); err != nil {
+		return nil, nil, fmt.Errorf("invalid %s in secret %s: %w", secretKeyHistoryIndex, secretNameHistory, err)
+	}
+	return secret, entries, nil
+}
+
+// recordHistory adds the config written into alertmanager-main to the history, unless it is the
+// latest revision already, and drops the revisions beyond historyLimit.
+func (r *SecretReconciler) recordHistory(reqLogger logr.Logger, amconfigbyte []byte, trigger string) error {
+	secret, entries, err := r.readHistory()
+	if err != nil {
+		return err
+	}
+	hash := configHash(amconfigbyte)
+	if len(entries) > 0 && entries[len(entries)-1].Hash == hash {
+		return nil
+	}
+
+	revision := 1
+	if len(entries) > 0 {
+		revision = entries[len(entries)-1].Revision + 1
+	}
+	entries = append(entries, historyEntry{
+		Revision:        revision,
+		Hash:            hash,
+		Timestamp:       time.Now().UTC().Format(time.RFC3339),
+		OperatorVersion: config.OperatorVersion(),
+		Trigger:         trigger,
+	})
+	if len(entries) > historyLimit {
+		entries = entries[len(entries)-historyLimit:]
+	}
+	index, err := yaml.Marshal(entries)
+	if err != nil {
+		return err
+	}
+
+	data := map[string][]byte{secretKeyHistoryIndex: index}
+	for _, entry := range entries[:len(entries)-1] {
+		if secret != nil {
+			data[historyKey(entry.Revision)] = secret.Data[historyKey(entry.Revision)]
+		}
+	}
+	data[historyKey(revision)] = amconfigbyte
+
+	if secret == nil {
+		secret = &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      secretNameHistory,
+				Namespace: config.OperatorNamespace,
+			},
+			Data: data,
+		}
+		err = r.Client.Create(context.TODO(), secret)
+	} else {
+		secret.Data = data
+		err = r.Client.Update(context.TODO(), secret)
+	}
+	if err != nil {
+		return err
+	}
+	metrics.UpdateConfigHistoryRevision(revision)
+	reqLogger.Info("INFO: Recorded config history revision", "Revision", revision, "Hash", hash, "Trigger", trigger)
+	return nil
+}
+
+// historyRevision returns the config of a revision of the history.
+func (r *SecretReconciler) historyRevision(revision int) ([]byte, error) {
+	secret, entries, err := r.readHistory()
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		if entry.Revision != revision {
+			continue
+		}
+		amconfigbyte := secret.Data[historyKey(revision)]
+		if configHash(amconfigbyte) != entry.Hash {
+			return nil, fmt.Errorf("config of revision %d does not match its hash", revision)
+		}
+		return amconfigbyte, nil
+	}
+	return nil, fmt.Errorf("revision %d is not in the history", revision)
+}
+
+// rollbackConfig returns the config of the revision the operator configMap pins alertmanager-main to.
+// It returns nil if there is no rollback, or the revision can't be used. Events are recorded on the
+// operator configMap when the rollback starts, ends or is invalid.
+func (r *SecretReconciler) rollbackConfig(reqLogger logr.Logger, cmList *corev1.ConfigMapList) []byte {
+	var cm *corev1.ConfigMap
+	for i := range cmList.Items {
+		if cmList.Items[i].Name == cmNameOperatorConfig {
+			cm = &cmList.Items[i]
+		}
+	}
+
+	raw := ""
+	if cm != nil {
+		raw = cm.Annotations[RollbackAnnotation]
+	}
+	if raw == "" {
+		if r.rollbackState != "" {
+			reqLogger.Info("INFO: Config rollback ended; writing the desired config", "Revision", r.rollbackState)
+			r.recordRollbackEvent(cm, corev1.EventTypeNormal, eventReasonRollbackEnded,
+				fmt.Sprintf("Rollback to config revision %s ended", r.rollbackState))
+			r.rollbackState = ""
+		}
+		metrics.UpdateConfigRollbackRevision(0)
+		return nil
+	}
+
+	changed := raw != r.rollbackState
+	r.rollbackState = raw
+	revision, err := strconv.Atoi(raw)
+	var amconfigbyte []byte
+	if err == nil {
+		amconfigbyte, err = r.historyRevision(revision)
+	}
+	if err != nil {
+		reqLogger.Error(err, "ERROR: Invalid config rollback; writing the desired config", "Revision", raw)
+		if changed {
+			r.recordRollbackEvent(cm, corev1.EventTypeWarning, eventReasonRollbackInvalid,
+				fmt.Sprintf("Cannot roll back to config revision %q: %v", raw, err))
+		}
+		metrics.UpdateConfigRollbackRevision(0)
+		return nil
+	}
+
+	reqLogger.Info("WARNING: alertmanager-main is pinned to a config revision; remove the annotation to resume", "Revision", revision, "Annotation", RollbackAnnotation)
+	if changed {
+		r.recordRollbackEvent(cm, corev1.EventTypeWarning, eventReasonRollbackApplied,
+			fmt.Sprintf("Rolled back alertmanager-main to config revision %d until %s is removed", revision, RollbackAnnotation))
+	}
+	metrics.UpdateConfigRollbackRevision(revision)
+	return amconfigbyte
+}
+
+func (r *SecretReconciler) recordRollbackEvent(cm *corev1.ConfigMap, eventType, reason, message string) {
+	// nothing is rolled back in dry-run mode, the diff shows what would be
+	if r.Recorder == nil || cm == nil || r.DryRun {
+		return
+	}
+	r.Recorder.Event(cm, eventType, reason, message)
+}
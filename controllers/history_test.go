@@ -0,0 +1,140 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/openshift/configure-alertmanager-operator/config"
+	"github.com/openshift/configure-alertmanager-operator/pkg/readiness"
+	"go.uber.org/mock/gomock"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func updatePDSecret(t *testing.T, reconciler *SecretReconciler, key string) {
+	secret := &corev1.Secret{}
+	objectKey := types.NamespacedName{Name: secretNamePD, Namespace: config.OperatorNamespace}
+	if err := reconciler.Client.Get(context.TODO(), objectKey, secret); err != nil {
+		createSecret(reconciler, secretNamePD, secretKeyPD, key)
+		return
+	}
+	secret.Data[secretKeyPD] = []byte(key)
+	if err := reconciler.Client.Update(context.TODO(), secret); err != nil {
+		t.Fatalf("Could not update secret: %v", err)
+	}
+}
+
+func setRollbackAnnotation(t *testing.T, reconciler *SecretReconciler, revision string) {
+	cm := &corev1.ConfigMap{}
+	objectKey := types.NamespacedName{Name: cmNameOperatorConfig, Namespace: config.OperatorNamespace}
+	if err := reconciler.Client.Get(context.TODO(), objectKey, cm); err != nil {
+		t.Fatalf("Could not read configMap: %v", err)
+	}
+	cm.Annotations = map[string]string{}
+	if revision != "" {
+		cm.Annotations[RollbackAnnotation] = revision
+	}
+	if err := reconciler.Client.Update(context.TODO(), cm); err != nil {
+		t.Fatalf("Could not update configMap: %v", err)
+	}
+}
+
+func Test_Reconcile_History(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockReadiness := readiness.NewMockInterface(ctrl)
+	mockReadiness.EXPECT().IsReady().AnyTimes().Return(true, nil)
+	mockReadiness.EXPECT().Result().AnyTimes().Return(reconcile.Result{})
+	reconciler := createReconciler(t, mockReadiness)
+	createNamespace(reconciler, t)
+	createClusterVersion(reconciler)
+	createClusterProxy(reconciler)
+	req := createReconcileRequest(reconciler, secretNamePD)
+
+	for i := 1; i <= historyLimit+2; i++ {
+		updatePDSecret(t, reconciler, fmt.Sprintf("pd-key-%d", i))
+		_, err := reconciler.Reconcile(context.TODO(), *req)
+		assertEquals(t, nil, err, "Unexpected err")
+	}
+	// writing the same config again doesn't add a revision
+	_, err := reconciler.Reconcile(context.TODO(), *req)
+	assertEquals(t, nil, err, "Unexpected err")
+
+	secret, entries, err := reconciler.readHistory()
+	assertEquals(t, nil, err, "Unexpected err")
+	assertEquals(t, historyLimit, len(entries), "Number of revisions")
+	assertEquals(t, 3, entries[0].Revision, "Oldest revision")
+	assertEquals(t, historyLimit+2, entries[len(entries)-1].Revision, "Latest revision")
+	assertEquals(t, req.NamespacedName.String(), entries[0].Trigger, "Trigger")
+	assertEquals(t, historyLimit+1, len(secret.Data), "Number of history keys")
+	_, ok := secret.Data[historyKey(2)]
+	assertFalse(t, ok, "Dropped revision is still in the history")
+
+	latest, err := reconciler.historyRevision(historyLimit + 2)
+	assertEquals(t, nil, err, "Unexpected err")
+	assertEquals(t, readAlertManagerSecret(t, reconciler), string(latest), "Latest revision")
+	_, err = reconciler.historyRevision(2)
+	assertTrue(t, err != nil, "Dropped revision is still available")
+}
+
+func Test_Reconcile_Rollback(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockReadiness := readiness.NewMockInterface(ctrl)
+	mockReadiness.EXPECT().IsReady().AnyTimes().Return(true, nil)
+	mockReadiness.EXPECT().Result().AnyTimes().Return(reconcile.Result{})
+	reconciler := createReconciler(t, mockReadiness)
+	recorder := record.NewFakeRecorder(10)
+	reconciler.Recorder = recorder
+	createNamespace(reconciler, t)
+	createClusterVersion(reconciler)
+	createClusterProxy(reconciler)
+	createConfigMap(reconciler, cmNameOperatorConfig, cmKeyRouteSettings, "")
+	req := createReconcileRequest(reconciler, secretNamePD)
+
+	updatePDSecret(t, reconciler, "pd-key-1")
+	_, err := reconciler.Reconcile(context.TODO(), *req)
+	assertEquals(t, nil, err, "Unexpected err")
+	first := readAlertManagerSecret(t, reconciler)
+	updatePDSecret(t, reconciler, "pd-key-2")
+	_, err = reconciler.Reconcile(context.TODO(), *req)
+	assertEquals(t, nil, err, "Unexpected err")
+	second := readAlertManagerSecret(t, reconciler)
+
+	// pinned to the first revision, even when the inputs change
+	setRollbackAnnotation(t, reconciler, "1")
+	for _, key := range []string{"pd-key-2", "pd-key-3"} {
+		updatePDSecret(t, reconciler, key)
+		_, err = reconciler.Reconcile(context.TODO(), *req)
+		assertEquals(t, nil, err, "Unexpected err")
+		assertEquals(t, first, readAlertManagerSecret(t, reconciler), "Rolled back config")
+	}
+	_, entries, _ := reconciler.readHistory()
+	assertEquals(t, 2, len(entries), "A rollback was recorded in the history")
+
+	// an unknown revision is ignored
+	setRollbackAnnotation(t, reconciler, "42")
+	updatePDSecret(t, reconciler, "pd-key-2")
+	_, err = reconciler.Reconcile(context.TODO(), *req)
+	assertEquals(t, nil, err, "Unexpected err")
+	assertEquals(t, second, readAlertManagerSecret(t, reconciler), "Desired config with an invalid rollback")
+
+	// clearing the annotation resumes
+	setRollbackAnnotation(t, reconciler, "1")
+	_, err = reconciler.Reconcile(context.TODO(), *req)
+	assertEquals(t, nil, err, "Unexpected err")
+	setRollbackAnnotation(t, reconciler, "")
+	_, err = reconciler.Reconcile(context.TODO(), *req)
+	assertEquals(t, nil, err, "Unexpected err")
+	assertEquals(t, second, readAlertManagerSecret(t, reconciler), "Desired config after the rollback")
+
+	var reasons []string
+	for len(recorder.Events) > 0 {
+		reasons = append(reasons, strings.Fields(<-recorder.Events)[1])
+	}
+	assertEquals(t, []string{eventReasonRollbackApplied, eventReasonRollbackInvalid, eventReasonRollbackApplied, eventReasonRollbackEnded}, reasons, "Events")
+}
@@ -0,0 +1,222 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"fmt"
+
+	"github.com/go-logr/logr"
+	"gopkg.in/yaml.v2"
+	corev1 "k8s.io/api/core/v1"
+
+	alertmanager "github.com/openshift/configure-alertmanager-operator/pkg/types"
+)
+
+// inhibitRuleDefinition is an entry in the inhibit rule catalog.
+type inhibitRuleDefinition struct {
+	// ID uniquely identifies the rule so it can be referenced from the override ConfigMap.
+	ID          string
+	Description string
+	Ticket      string
+	// Enabled indicates whether the rule is rendered unless overridden.
+	Enabled bool
+
+	SourceMatchers []string
+	TargetMatchers []string
+	Equal          []string
+}
+
+// inhibitRuleCatalog lists the inhibit rules configured by the operator, in the order they are rendered.
+// Work request: https://issues.redhat.com/browse/OSD-4623
+// Reference: https://github.com/openshift/cluster-monitoring-operator/blob/6a02b14773169330d7a31ede73dce5adb1c66bb4/assets/alertmanager/secret.yaml
+var inhibitRuleCatalog = []inhibitRuleDefinition{
+	{
+		ID:             "critical-inhibits-warning",
+		Description:    "Critical alert shouldn't also alert for warning/info",
+		Ticket:         "https://issues.redhat.com/browse/OSD-4623",
+		Enabled:        true,
+		SourceMatchers: []string{`severity="critical"`},
+		TargetMatchers: []string{`severity=~"warning|info"`},
+		Equal:          []string{"namespace", "alertname"},
+	},
+	{
+		ID:             "warning-inhibits-info",
+		Description:    "Warning alerts shouldn't also alert for info",
+		Ticket:         "https://issues.redhat.com/browse/OSD-4623",
+		Enabled:        true,
+		SourceMatchers: []string{`severity="warning"`},
+		TargetMatchers: []string{`severity="info"`},
+		Equal:          []string{"namespace", "alertname"},
+	},
+	{
+		ID:             "cluster-operator-degraded-inhibits-down",
+		Description:    "If a cluster operator is degraded, don't also fire ClusterOperatorDown. The degraded alert is critical, and usually has more details",
+		Ticket:         "https://issues.redhat.com/browse/OSD-4623",
+		Enabled:        true,
+		SourceMatchers: []string{`alertname="ClusterOperatorDegraded"`, `severity="critical"`},
+		TargetMatchers: []string{`alertname="ClusterOperatorDown"`},
+		Equal:          []string{"namespace", "name"},
+	},
+	{
+		ID:             "node-not-ready-inhibits-unreachable",
+		Description:    "If a node is not ready, we already know it's Unreachable",
+		Ticket:         "https://issues.redhat.com/browse/OSD-4623",
+		Enabled:        true,
+		SourceMatchers: []string{`alertname="KubeNodeNotReady"`},
+		TargetMatchers: []string{`alertname="KubeNodeUnreachable"`},
+		Equal:          []string{"node", "instance"},
+	},
+	{
+		ID:             "node-unreachable-inhibits-unavailable-pods",
+		Description:    "Node being Unreachable may also trigger certain pods being unavailable",
+		Ticket:         "https://issues.redhat.com/browse/OSD-4623",
+		Enabled:        true,
+		SourceMatchers: []string{`alertname="KubeNodeUnreachable"`},
+		TargetMatchers: []string{`alertname=~"SDNPodNotReady|TargetDown"`},
+	},
+	{
+		ID:             "node-not-ready-inhibits-workloads",
+		Description:    "If a node is NotReady, then we also know that there will be pods that aren't running",
+		Ticket:         "https://issues.redhat.com/browse/OSD-4623",
+		Enabled:        true,
+		SourceMatchers: []string{`alertname="KubeNodeNotReady"`},
+		TargetMatchers: []string{`alertname=~"KubeDaemonSetRolloutStuck|KubeDaemonSetMisScheduled|KubeDeploymentReplicasMismatch|KubeStatefulSetReplicasMismatch|KubePodNotReady"`},
+		Equal:          []string{"instance"},
+	},
+	{
+		ID:             "deployment-replicas-mismatch-inhibits-pods",
+		Description:    "If a deployment has its replicas mismatched, then we don't need to fire for the pod not being ready",
+		Ticket:         "https://issues.redhat.com/browse/OSD-4623",
+		Enabled:        true,
+		SourceMatchers: []string{`alertname="KubeDeploymentReplicasMismatch"`},
+		TargetMatchers: []string{`alertname=~"KubePodNotReady|KubePodCrashLooping"`},
+		Equal:          []string{"namespace"},
+	},
+	{
+		ID:             "elasticsearch-csv-inhibits-cluster-health",
+		Description:    "If the Elasticsearch operator CSV is failing, the Elasticsearch cluster health alert adds nothing",
+		Ticket:         "https://issues.redhat.com/browse/OSD-4623",
+		Enabled:        true,
+		SourceMatchers: []string{`alertname="ElasticsearchOperatorCSVNotSuccessful"`},
+		TargetMatchers: []string{`alertname="ElasticsearchClusterNotHealthy"`},
+	},
+	{
+		ID:             "kube-api-error-budget-burn-inhibits-sre",
+		Description:    "KubeAPIErrorBudgetBurn supersedes the SRE api-ErrorBudgetBurn alert of the same severity",
+		Ticket:         "https://issues.redhat.com/browse/OSD-13721",
+		Enabled:        true,
+		SourceMatchers: []string{`alertname="KubeAPIErrorBudgetBurn"`},
+		TargetMatchers: []string{`alertname="api-ErrorBudgetBurn"`},
+		Equal:          []string{"severity"},
+	},
+}
+
+// createInhibitRules merges the overrides into the inhibit rule catalog and renders the enabled rules.
+// Overrides referencing a catalog ID can only enable or disable that rule, overrides with a new ID
+// add a rule. Invalid overrides, and overrides repeating the id of an earlier one, are logged and ignored.
+func createInhibitRules(reqLogger logr.Logger, overrides []alertmanager.InhibitRuleOverride) []*alertmanager.InhibitRule {
+	definitions := make([]inhibitRuleDefinition, len(inhibitRuleCatalog))
+	copy(definitions, inhibitRuleCatalog)
+
+	index := map[string]int{}
+	for i, def := range definitions {
+		index[def.ID] = i
+	}
+
+	seen := map[string]bool{}
+	for _, override := range overrides {
+		if override.ID == "" {
+			reqLogger.Info("WARNING: Ignoring inhibit rule override without an id")
+			continue
+		}
+		if seen[override.ID] {
+			reqLogger.Info("WARNING: Ignoring inhibit rule override with a duplicate id", "ID", override.ID)
+			continue
+		}
+		seen[override.ID] = true
+		if i, ok := index[override.ID]; ok {
+			if len(override.SourceMatchers) > 0 || len(override.TargetMatchers) > 0 || len(override.Equal) > 0 {
+				reqLogger.Info("WARNING: Catalog inhibit rules can only be enabled or disabled; ignoring matchers", "ID", override.ID)
+			}
+			if override.Enabled != nil {
+				definitions[i].Enabled = *override.Enabled
+			}
+			continue
+		}
+		if err := validateInhibitRuleOverride(override); err != nil {
+			reqLogger.Info("WARNING: Ignoring invalid inhibit rule override", "ID", override.ID, "Error", err.Error())
+			continue
+		}
+		def := inhibitRuleDefinition{
+			ID:             override.ID,
+			Description:    override.Description,
+			Ticket:         override.Ticket,
+			Enabled:        override.Enabled == nil || *override.Enabled,
+			SourceMatchers: override.SourceMatchers,
+			TargetMatchers: override.TargetMatchers,
+			Equal:          override.Equal,
+		}
+		index[def.ID] = len(definitions)
+		definitions = append(definitions, def)
+	}
+
+	rules := []*alertmanager.InhibitRule{}
+	for _, def := range definitions {
+		if !def.Enabled {
+			reqLogger.Info("DEBUG: Inhibit rule disabled", "ID", def.ID)
+			continue
+		}
+		rules = append(rules, &alertmanager.InhibitRule{
+			SourceMatchers: def.SourceMatchers,
+			TargetMatchers: def.TargetMatchers,
+			Equal:          def.Equal,
+		})
+	}
+	return rules
+}
+
+// validateInhibitRuleOverride checks that an override adding a rule has valid source and target matchers.
+func validateInhibitRuleOverride(override alertmanager.InhibitRuleOverride) error {
+	if len(override.SourceMatchers) == 0 || len(override.TargetMatchers) == 0 {
+		return fmt.Errorf("both source_matchers and target_matchers are required")
+	}
+	for _, m := range append(append([]string{}, override.SourceMatchers...), override.TargetMatchers...) {
+		if _, _, _, err := alertmanager.ParseMatcher(m); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// parseInhibitRuleOverrides returns the inhibit rule overrides from the operator configMap, if present.
+func (r *SecretReconciler) parseInhibitRuleOverrides(reqLogger logr.Logger, cmList *corev1.ConfigMapList, cmNamespace string) []alertmanager.InhibitRuleOverride {
+	if !cmInList(reqLogger, cmNameOperatorConfig, cmList) {
+		return nil
+	}
+
+	rawOverrides := readCMKey(r, reqLogger, cmNameOperatorConfig, cmNamespace, cmKeyInhibitRules)
+	if rawOverrides == "" {
+		return nil
+	}
+
+	var overrides alertmanager.InhibitRuleOverrides
+	if err := yaml.Unmarshal([]byte(rawOverrides), &overrides); err != nil {
+		reqLogger.Info("WARNING: Unable to unmarshal inhibit rule overrides; using the catalog defaults", "ConfigMap", fmt.Sprintf("%s/%s", cmNamespace, cmNameOperatorConfig), "Error", err.Error())
+		return nil
+	}
+	return overrides.Rules
+}
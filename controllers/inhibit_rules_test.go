@@ -0,0 +1,174 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/openshift/configure-alertmanager-operator/pkg/readiness"
+	alertmanager "github.com/openshift/configure-alertmanager-operator/pkg/types"
+	"go.uber.org/mock/gomock"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func boolPtr(b bool) *bool {
+	return &b
+}
+
+func Test_createInhibitRules_Defaults(t *testing.T) {
+	rules := createInhibitRules(reqLogger, nil)
+
+	assertEquals(t, len(inhibitRuleCatalog), len(rules), "Number of InhibitRules")
+	verifyInhibitRules(t, rules)
+}
+
+func Test_createInhibitRules_Overrides(t *testing.T) {
+	tests := []struct {
+		name           string
+		overrides      []alertmanager.InhibitRuleOverride
+		expectedCount  int
+		expectedSource []string
+	}{
+		{
+			name:          "Disable a catalog rule",
+			overrides:     []alertmanager.InhibitRuleOverride{{ID: "elasticsearch-csv-inhibits-cluster-health", Enabled: boolPtr(false)}},
+			expectedCount: len(inhibitRuleCatalog) - 1,
+		},
+		{
+			name:          "Re-enabling a catalog rule is a no-op",
+			overrides:     []alertmanager.InhibitRuleOverride{{ID: "warning-inhibits-info", Enabled: boolPtr(true)}},
+			expectedCount: len(inhibitRuleCatalog),
+		},
+		{
+			name: "Catalog matchers cannot be replaced",
+			overrides: []alertmanager.InhibitRuleOverride{{
+				ID:             "warning-inhibits-info",
+				SourceMatchers: []string{`alertname="Replaced"`},
+				TargetMatchers: []string{`alertname="Replaced"`},
+			}},
+			expectedCount: len(inhibitRuleCatalog),
+		},
+		{
+			name: "Add a rule",
+			overrides: []alertmanager.InhibitRuleOverride{{
+				ID:             "custom",
+				SourceMatchers: []string{`alertname="Source"`},
+				TargetMatchers: []string{`alertname=~"TargetA|TargetB"`},
+				Equal:          []string{"namespace"},
+			}},
+			expectedCount:  len(inhibitRuleCatalog) + 1,
+			expectedSource: []string{`alertname="Source"`},
+		},
+		{
+			name: "Add a disabled rule",
+			overrides: []alertmanager.InhibitRuleOverride{{
+				ID:             "custom",
+				Enabled:        boolPtr(false),
+				SourceMatchers: []string{`alertname="Source"`},
+				TargetMatchers: []string{`alertname="Target"`},
+			}},
+			expectedCount: len(inhibitRuleCatalog),
+		},
+		{
+			name: "Rules without target matchers are ignored",
+			overrides: []alertmanager.InhibitRuleOverride{{
+				ID:             "custom",
+				SourceMatchers: []string{`alertname="Source"`},
+			}},
+			expectedCount: len(inhibitRuleCatalog),
+		},
+		{
+			name: "Rules with invalid matchers are ignored",
+			overrides: []alertmanager.InhibitRuleOverride{{
+				ID:             "custom",
+				SourceMatchers: []string{`alertname="Source"`},
+				TargetMatchers: []string{`alertname=~"(unclosed"`},
+			}},
+			expectedCount: len(inhibitRuleCatalog),
+		},
+		{
+			name: "Duplicate ids are ignored",
+			overrides: []alertmanager.InhibitRuleOverride{
+				{
+					ID:             "custom",
+					SourceMatchers: []string{`alertname="Source"`},
+					TargetMatchers: []string{`alertname="Target"`},
+				},
+				{
+					ID:             "custom",
+					Enabled:        boolPtr(false),
+					SourceMatchers: []string{`alertname="Duplicate"`},
+					TargetMatchers: []string{`alertname="Target"`},
+				},
+				{ID: "warning-inhibits-info", Enabled: boolPtr(false)},
+				{ID: "warning-inhibits-info", Enabled: boolPtr(true)},
+			},
+			expectedCount:  len(inhibitRuleCatalog),
+			expectedSource: []string{`alertname="Source"`},
+		},
+		{
+			name:          "Overrides without an id are ignored",
+			overrides:     []alertmanager.InhibitRuleOverride{{Enabled: boolPtr(false)}},
+			expectedCount: len(inhibitRuleCatalog),
+		},
+	}
+
+	for _, tt := range tests {
+		rules := createInhibitRules(reqLogger, tt.overrides)
+		assertEquals(t, tt.expectedCount, len(rules), tt.name)
+		if tt.expectedSource != nil {
+			assertEquals(t, tt.expectedSource, rules[len(rules)-1].SourceMatchers, tt.name)
+		}
+	}
+
+	// the catalog itself must not be modified by overrides
+	createInhibitRules(reqLogger, []alertmanager.InhibitRuleOverride{{ID: "warning-inhibits-info", Enabled: boolPtr(false)}})
+	verifyInhibitRules(t, createInhibitRules(reqLogger, nil))
+}
+
+func Test_parseInhibitRuleOverrides(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	tests := []struct {
+		name          string
+		data          *string
+		expectedCount int
+	}{
+		{
+			name:          "Missing configMap",
+			expectedCount: 0,
+		},
+		{
+			name:          "Invalid configMap",
+			data:          stringPtr("This is not an inhibit rule document"),
+			expectedCount: 0,
+		},
+		{
+			name:          "Valid configMap",
+			data:          stringPtr("rules:\n- id: warning-inhibits-info\n  enabled: false\n- id: custom\n  source_matchers: ['alertname=\"A\"']\n  target_matchers: ['alertname=\"B\"']\n"),
+			expectedCount: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		reconciler := createReconciler(t, readiness.NewMockInterface(ctrl))
+		createNamespace(reconciler, t)
+		if tt.data != nil {
+			createConfigMap(reconciler, cmNameOperatorConfig, cmKeyInhibitRules, *tt.data)
+		}
+
+		cmList := &corev1.ConfigMapList{}
+		if err := reconciler.Client.List(context.TODO(), cmList, &client.ListOptions{}); err != nil {
+			t.Fatalf("Could not list ConfigMaps: %v", err)
+		}
+
+		overrides := reconciler.parseInhibitRuleOverrides(reqLogger, cmList, createReconcileRequest(reconciler, cmNameOperatorConfig).Namespace)
+		assertEquals(t, tt.expectedCount, len(overrides), fmt.Sprintf("%s: number of overrides", tt.name))
+	}
+}
+
+func stringPtr(s string) *string {
+	return &s
+}
@@ -0,0 +1,120 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"fmt"
+
+	"github.com/go-logr/logr"
+	"gopkg.in/yaml.v2"
+	corev1 "k8s.io/api/core/v1"
+
+	alertmanager "github.com/openshift/configure-alertmanager-operator/pkg/types"
+)
+
+const (
+	// operator configmap key for the alerts that page before the cluster is ready
+	cmKeyInstallPaging = "install_paging.yaml"
+)
+
+// parseInstallPaging returns the install paging allowlist from the operator configMap, if present.
+// Rules with invalid matchers are logged and ignored.
+func (r *SecretReconciler) parseInstallPaging(reqLogger logr.Logger, cmList *corev1.ConfigMapList, cmNamespace string) []alertmanager.InstallPagingRule {
+	if !cmInList(reqLogger, cmNameOperatorConfig, cmList) {
+		return nil
+	}
+
+	rawInstallPaging := readCMKey(r, reqLogger, cmNameOperatorConfig, cmNamespace, cmKeyInstallPaging)
+	if rawInstallPaging == "" {
+		return nil
+	}
+
+	var installPaging alertmanager.InstallPaging
+	if err := yaml.Unmarshal([]byte(rawInstallPaging), &installPaging); err != nil {
+		reqLogger.Info("WARNING: Unable to unmarshal install paging rules; nothing will page until the cluster is ready", "ConfigMap", fmt.Sprintf("%s/%s", cmNamespace, cmNameOperatorConfig), "Error", err.Error())
+		return nil
+	}
+
+	rules := []alertmanager.InstallPagingRule{}
+	for i, rule := range installPaging.Rules {
+		if err := validateInstallPagingRule(rule); err != nil {
+			reqLogger.Info("WARNING: Ignoring invalid install paging rule", "Index", i, "Description", rule.Description, "Error", err.Error())
+			continue
+		}
+		rules = append(rules, rule)
+	}
+	return rules
+}
+
+// validateInstallPagingRule checks that a rule has at least one matcher and that all of them are valid.
+func validateInstallPagingRule(rule alertmanager.InstallPagingRule) error {
+	if len(rule.Matchers) == 0 {
+		return fmt.Errorf("at least one matcher is required")
+	}
+	for _, m := range rule.Matchers {
+		if _, _, _, err := alertmanager.ParseMatcher(m); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// createInstallPagingRoute creates the route used for an integration before the cluster is ready.
+// Only the alerts matching one of the rules are sent, to the integration's critical receiver, which for
+// GoAlert is the tier of critical alerts in the goalert routing; its namespaced rules don't apply, since
+// every alert that pages during install is critical. Everything else, including the alerts from the
+// excluded namespaces, goes to the "null" receiver.
+func createInstallPagingRoute(rules []alertmanager.InstallPagingRule, namespaceExclusions []string, receiver receiverType, settings *alertmanager.RouteSettings, goalert *goalertRouting) *alertmanager.Route {
+	var receiverCritical string
+
+	switch receiver {
+	case GoAlert:
+		if goalert == nil {
+			goalert = defaultGoalertRouting()
+		}
+		receiverCritical = goalert.receiverClasses[receiverClassCritical]
+	case Pagerduty:
+		receiverCritical = receiverMakeItCritical
+	default:
+		return nil
+	}
+
+	subroute := []*alertmanager.Route{
+		{Receiver: receiverNull, Matchers: []string{alertmanager.NewMatcher("namespace", alertmanager.MatchRegexp, excludedNamespaces(namespaceExclusions))}},
+		{Receiver: receiverNull, Matchers: []string{alertmanager.NewMatcher("exported_namespace", alertmanager.MatchRegexp, excludedExportedNamespaces(namespaceExclusions))}},
+	}
+	for _, rule := range rules {
+		subroute = append(subroute, &alertmanager.Route{
+			Receiver: receiverCritical,
+			Matchers: rule.Matchers,
+		})
+	}
+
+	applyReceiverClassTiming(subroute, map[string]string{
+		receiverClassCritical: receiverCritical,
+	}, settings)
+
+	return &alertmanager.Route{
+		Receiver: receiverNull,
+		GroupByStr: []string{
+			"alertname",
+			"severity",
+		},
+		Continue: true,
+		Routes:   subroute,
+	}
+}
@@ -0,0 +1,144 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	"github.com/openshift/configure-alertmanager-operator/pkg/readiness"
+	alertmanager "github.com/openshift/configure-alertmanager-operator/pkg/types"
+	"go.uber.org/mock/gomock"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+const exampleInstallPaging = `rules:
+- description: SRE alerts that are critical
+  matchers: ['alertname=~".*SRE"', 'severity="critical"']
+- description: etcd
+  matchers: ['alertname=~"etcd.*"']
+- description: invalid
+  matchers: ['alertname=~"("']
+- description: no matchers
+`
+
+func Test_parseInstallPaging(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	tests := []struct {
+		name          string
+		data          *string
+		expectedRules []alertmanager.InstallPagingRule
+	}{
+		{
+			name: "Missing configMap",
+		},
+		{
+			name: "Invalid configMap",
+			data: stringPtr("- this is not an install paging document"),
+		},
+		{
+			name: "Valid configMap",
+			data: stringPtr(exampleInstallPaging),
+			expectedRules: []alertmanager.InstallPagingRule{
+				{Description: "SRE alerts that are critical", Matchers: []string{`alertname=~".*SRE"`, `severity="critical"`}},
+				{Description: "etcd", Matchers: []string{`alertname=~"etcd.*"`}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		reconciler := createReconciler(t, readiness.NewMockInterface(ctrl))
+		createNamespace(reconciler, t)
+		if tt.data != nil {
+			createConfigMap(reconciler, cmNameOperatorConfig, cmKeyInstallPaging, *tt.data)
+		}
+
+		cmList := &corev1.ConfigMapList{}
+		if err := reconciler.Client.List(context.TODO(), cmList, &client.ListOptions{}); err != nil {
+			t.Fatalf("Could not list ConfigMaps: %v", err)
+		}
+
+		rules := reconciler.parseInstallPaging(reqLogger, cmList, createReconcileRequest(reconciler, cmNameOperatorConfig).Namespace)
+		assertEquals(t, tt.expectedRules, rules, tt.name)
+	}
+}
+
+func Test_createAlertManagerConfig_WithInstallPaging(t *testing.T) {
+	rules := []alertmanager.InstallPagingRule{
+		{Matchers: []string{`alertname=~"etcd.*"`}},
+	}
+	config := createAlertManagerConfig(reqLogger, "pdkey", "https://dummy-galow-url", "https://dummy-gahigh-url", "", "http://theinterwebs", "", exampleClusterId, exampleProxy, exampleManagedNamespaces, alertmanagerConfigOptions{installPaging: rules})
+
+	// watchdog, PagerDuty and GoAlert
+	assertEquals(t, 3, len(config.Route.Routes), "Number of Routes")
+	verifyWatchdogRoute(t, config.Route.Routes[0])
+	for i, receiver := range []string{receiverMakeItCritical, receiverGoAlertHigh} {
+		route := config.Route.Routes[i+1]
+		assertEquals(t, receiverNull, route.Receiver, "Default receiver")
+		assertTrue(t, route.Continue, "Continue")
+		// the exclusions, then the rule
+		assertEquals(t, 3, len(route.Routes), "Number of subroutes")
+		assertEquals(t, receiver, route.Routes[2].Receiver, "Subroute receiver")
+		assertEquals(t, rules[0].Matchers, route.Routes[2].Matchers, "Subroute matchers")
+
+		routedTo, _ := simulateRoute(t, route, map[string]string{"alertname": "etcdNoLeader", "namespace": "openshift-etcd"}, "", nil)
+		assertEquals(t, receiver, routedTo, "Alert matching the rule")
+		routedTo, _ = simulateRoute(t, route, map[string]string{"alertname": "etcdNoLeader", "namespace": "openshift-storage"}, "", nil)
+		assertEquals(t, receiverNull, routedTo, "Alert from an excluded namespace")
+	}
+	verifyPagerdutyReceivers(t, "pdkey", exampleProxy, config.Receivers)
+}
+
+func Test_createInstallPagingRoute_GoAlertTiers(t *testing.T) {
+	rules := []alertmanager.InstallPagingRule{
+		{Matchers: []string{`alertname=~"etcd.*"`}},
+	}
+	routing := parseGoalertRouting(reqLogger, goalertSecretList(exampleGoalertTiers))
+	route := createInstallPagingRoute(rules, nil, GoAlert, defaultRouteSettings(), routing)
+
+	// the tier of critical alerts, even for a namespace with its own rule
+	for _, namespace := range []string{"openshift-etcd", "openshift-logging"} {
+		routedTo, _ := simulateRoute(t, route, map[string]string{"alertname": "etcdNoLeader", "namespace": namespace, "severity": "warning"}, "", nil)
+		assertEquals(t, "goalert-critical", routedTo, "Alert from "+namespace)
+	}
+}
+
+func Test_Reconcile_InstallPaging(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockReadiness := readiness.NewMockInterface(ctrl)
+	mockReadiness.EXPECT().IsReady().Times(2).Return(false, nil)
+	mockReadiness.EXPECT().Result().Times(2).Return(reconcile.Result{})
+	reconciler := createReconciler(t, mockReadiness)
+	createNamespace(reconciler, t)
+	createSecret(reconciler, secretNamePD, secretKeyPD, "pdkey")
+	createGoAlertSecret(reconciler, secretNameGoalert, secretKeyGoalertLow, secretKeyGoalertHigh, secretKeyGoalertHeartbeat, "https://dummy-galow-url", "https://dummy-gahigh-url", "https://dummy-gaheartbeat-url")
+	createClusterVersion(reconciler)
+	createClusterProxy(reconciler)
+
+	// Without an allowlist nothing pages before the cluster is ready.
+	req := createReconcileRequest(reconciler, secretNamePD)
+	_, err := reconciler.Reconcile(context.TODO(), *req)
+	assertEquals(t, nil, err, "Unexpected err")
+	assertEquals(t, 0, len(readAlertManagerConfig(reconciler, req).Route.Routes), "Number of Routes")
+
+	createConfigMap(reconciler, cmNameOperatorConfig, cmKeyInstallPaging, exampleInstallPaging)
+	_, err = reconciler.Reconcile(context.TODO(), *req)
+	assertEquals(t, nil, err, "Unexpected err")
+
+	amconfig := readAlertManagerConfig(reconciler, req)
+	// PagerDuty and GoAlert, but not the GoAlert heartbeat
+	assertEquals(t, 2, len(amconfig.Route.Routes), "Number of Routes")
+	for _, route := range amconfig.Route.Routes {
+		assertEquals(t, 4, len(route.Routes), "Number of install paging subroutes")
+	}
+	receivers := map[string]bool{}
+	for _, receiver := range amconfig.Receivers {
+		receivers[receiver.Name] = true
+	}
+	assertTrue(t, receivers[receiverMakeItCritical], "Missing PagerDuty receiver")
+	assertTrue(t, receivers[receiverGoAlertHigh], "Missing GoAlert receiver")
+	assertTrue(t, !receivers[receiverGoAlertHeartbeat], "Unexpected GoAlert heartbeat receiver")
+}
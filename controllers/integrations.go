@@ -0,0 +1,107 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/openshift/configure-alertmanager-operator/pkg/metrics"
+	alertmanager "github.com/openshift/configure-alertmanager-operator/pkg/types"
+)
+
+// integration is a notification service the controller configures receivers for.
+type integration struct {
+	// name identifies the integration in the metrics.
+	name string
+	// The Secret or ConfigMap configuring the integration; only one is set.
+	secretName    string
+	configMapName string
+	// receivers are the receivers created for the integration, starting with its main one.
+	receivers []string
+	// pages indicates the integration pages SRE, so it is only configured once the cluster is ready
+	// or alerts are allowed to page during install.
+	pages bool
+}
+
+// integrations lists the integrations the controller knows. Add new integrations here so they
+// are covered by the integration metrics and the mismatch alert.
+var integrations = []integration{
+	{
+		name:       "pagerduty",
+		secretName: secretNamePD,
+		receivers:  []string{receiverPagerduty, receiverMakeItWarning, receiverMakeItError, receiverMakeItCritical},
+		pages:      true,
+	},
+	{
+		name:       "goalert",
+		secretName: secretNameGoalert,
+		receivers:  []string{receiverGoAlertLow, receiverGoAlertHigh, receiverGoAlertHeartbeat},
+		pages:      true,
+	},
+	{
+		name:       "dms",
+		secretName: secretNameDMS,
+		receivers:  []string{receiverWatchdog},
+	},
+	{
+		name:          "ocm-agent",
+		configMapName: cmNameOcmAgent,
+		receivers:     []string{receiverOCMAgent},
+	},
+}
+
+// integrationStates reports, for every known integration, whether its source exists, whether it should
+// be configured and which of its receivers are in the config. An integration is expected if its source
+// exists and isn't marked for removal, or if it is pendingRemoval, i.e. its missing source is held until the
+// removal grace period ends. An integration that pages is not expected unless pagingEnabled, i.e. the
+// cluster is ready or alerts may page during install. Nothing is configured unless the config was applied.
+func integrationStates(reqLogger logr.Logger, secretList *corev1.SecretList, cmList *corev1.ConfigMapList, pendingRemoval map[string]bool, amconfig *alertmanager.Config, applied bool, pagingEnabled bool) []metrics.IntegrationState {
+	configured := map[string]bool{}
+	if applied && amconfig != nil {
+		for _, receiver := range amconfig.Receivers {
+			configured[receiver.Name] = true
+		}
+	}
+
+	states := []metrics.IntegrationState{}
+	for _, i := range integrations {
+		state := metrics.IntegrationState{
+			Integration: i.name,
+			Configured:  configured[i.receivers[0]],
+			Receivers:   map[string]bool{},
+		}
+		markedForRemoval := false
+		if i.secretName != "" {
+			state.SourceExists = secretInList(reqLogger, i.secretName, secretList)
+			for _, secret := range secretList.Items {
+				markedForRemoval = markedForRemoval || (secret.Name == i.secretName && secret.Annotations[RemovalAnnotation] == "true")
+			}
+		} else {
+			state.SourceExists = cmInList(reqLogger, i.configMapName, cmList)
+			for _, cm := range cmList.Items {
+				markedForRemoval = markedForRemoval || (cm.Name == i.configMapName && cm.Annotations[RemovalAnnotation] == "true")
+			}
+		}
+		state.Expected = ((state.SourceExists && !markedForRemoval) || pendingRemoval[i.name]) && (!i.pages || pagingEnabled)
+		for _, receiver := range i.receivers {
+			state.Receivers[receiver] = configured[receiver]
+		}
+		states = append(states, state)
+	}
+	return states
+}
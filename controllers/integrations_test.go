@@ -0,0 +1,87 @@
+package controllers
+
+import (
+	"testing"
+
+	"github.com/openshift/configure-alertmanager-operator/pkg/metrics"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func Test_integrationStates(t *testing.T) {
+	secretList := &corev1.SecretList{Items: []corev1.Secret{
+		{ObjectMeta: metav1.ObjectMeta{Name: secretNamePD}},
+		{ObjectMeta: metav1.ObjectMeta{Name: secretNameGoalert}},
+	}}
+	cmList := &corev1.ConfigMapList{Items: []corev1.ConfigMap{
+		{ObjectMeta: metav1.ObjectMeta{Name: cmNameOcmAgent}},
+	}}
+	// GoAlert without the heartbeat, OCM Agent and DMS
+	amconfig := createAlertManagerConfig(reqLogger, "pdkey", "https://dummy-galow-url", "https://dummy-gahigh-url", "", "https://dummy-dms-url", "https://dummy-oa-url", exampleClusterId, exampleProxy, defaultNamespaces, alertmanagerConfigOptions{})
+
+	states := map[string]metrics.IntegrationState{}
+	for _, state := range integrationStates(reqLogger, secretList, cmList, nil, amconfig, true, true) {
+		states[state.Integration] = state
+	}
+	assertEquals(t, len(integrations), len(states), "Number of integrations")
+
+	assertEquals(t, metrics.IntegrationState{
+		Integration:  "pagerduty",
+		SourceExists: true,
+		Expected:     true,
+		Configured:   true,
+		Receivers:    map[string]bool{receiverPagerduty: true, receiverMakeItWarning: true, receiverMakeItError: true, receiverMakeItCritical: true},
+	}, states["pagerduty"], "PagerDuty")
+	assertEquals(t, metrics.IntegrationState{
+		Integration:  "goalert",
+		SourceExists: true,
+		Expected:     true,
+		Configured:   true,
+		Receivers:    map[string]bool{receiverGoAlertLow: true, receiverGoAlertHigh: true, receiverGoAlertHeartbeat: false},
+	}, states["goalert"], "GoAlert")
+	assertEquals(t, metrics.IntegrationState{
+		Integration: "dms",
+		Configured:  true,
+		Receivers:   map[string]bool{receiverWatchdog: true},
+	}, states["dms"], "DMS")
+	assertEquals(t, metrics.IntegrationState{
+		Integration:  "ocm-agent",
+		SourceExists: true,
+		Expected:     true,
+		Configured:   true,
+		Receivers:    map[string]bool{receiverOCMAgent: true},
+	}, states["ocm-agent"], "OCM Agent")
+
+	// Nothing is configured if the config couldn't be applied.
+	for _, state := range integrationStates(reqLogger, secretList, cmList, nil, amconfig, false, true) {
+		assertFalse(t, state.Configured, state.Integration+" configured")
+	}
+
+	// Before the cluster is ready the paging integrations aren't expected, even though their secrets exist.
+	amconfig = createAlertManagerConfig(reqLogger, "", "", "", "", "", "https://dummy-oa-url", exampleClusterId, exampleProxy, defaultNamespaces, alertmanagerConfigOptions{})
+	for _, state := range integrationStates(reqLogger, secretList, cmList, nil, amconfig, true, false) {
+		switch state.Integration {
+		case "ocm-agent":
+			assertTrue(t, state.Expected, "OCM Agent expected")
+		default:
+			assertFalse(t, state.Expected, state.Integration+" expected")
+		}
+		assertEquals(t, state.Expected, state.Configured, state.Integration+" mismatch")
+	}
+
+	// During the removal grace period the missing PagerDuty secret is still expected, and a secret marked
+	// for removal isn't.
+	secretList = &corev1.SecretList{Items: []corev1.Secret{
+		{ObjectMeta: metav1.ObjectMeta{Name: secretNameGoalert, Annotations: map[string]string{RemovalAnnotation: "true"}}},
+	}}
+	amconfig = createAlertManagerConfig(reqLogger, "pdkey", "", "", "", "", "", exampleClusterId, exampleProxy, defaultNamespaces, alertmanagerConfigOptions{})
+	states = map[string]metrics.IntegrationState{}
+	for _, state := range integrationStates(reqLogger, secretList, cmList, map[string]bool{"pagerduty": true}, amconfig, true, true) {
+		states[state.Integration] = state
+	}
+	assertFalse(t, states["pagerduty"].SourceExists, "PagerDuty source exists")
+	assertTrue(t, states["pagerduty"].Expected, "PagerDuty expected")
+	assertTrue(t, states["pagerduty"].Configured, "PagerDuty configured")
+	assertTrue(t, states["goalert"].SourceExists, "GoAlert source exists")
+	assertFalse(t, states["goalert"].Expected, "GoAlert expected")
+}
@@ -0,0 +1,205 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/go-logr/logr"
+	"github.com/openshift/configure-alertmanager-operator/pkg/metrics"
+	alertmanager "github.com/openshift/configure-alertmanager-operator/pkg/types"
+	"gopkg.in/yaml.v3"
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	// operator configmap key choosing what to route when a namespace configMap is invalid
+	cmKeyNamespaceParseFailurePolicy = "namespace_parse_failure_policy"
+
+	// namespaceParsePolicyDefaults routes the default namespaces when a namespace configMap is invalid
+	namespaceParsePolicyDefaults = "defaults"
+	// namespaceParsePolicyLastGood keeps routing the namespaces last read from a valid namespace configMap
+	namespaceParsePolicyLastGood = "last-good"
+
+	eventReasonNamespaceConfigInvalid = "NamespaceConfigInvalid"
+)
+
+// namespaceConfig is the routing read from a namespace configMap.
+type namespaceConfig struct {
+	namespaces []string
+	exclusions []string
+	overrides  []namespaceOverride
+}
+
+// decodeNamespaceConfig strictly decodes a namespace configMap document, rejecting unknown fields, and
+// validates its entries. It returns every problem found, prefixed with its line where it has one; the
+// document is only valid if there are none.
+func decodeNamespaceConfig(raw string) (namespaceConfig, []string) {
+	var parsed namespaceConfig
+	var doc alertmanager.NamespaceConfig
+	decoder := yaml.NewDecoder(strings.NewReader(raw))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&doc); err != nil {
+		var typeErr *yaml.TypeError
+		switch {
+		case errors.Is(err, io.EOF):
+			return parsed, []string{"the document is empty"}
+		case errors.As(err, &typeErr):
+			return parsed, typeErr.Errors
+		default:
+			return parsed, []string{strings.TrimPrefix(err.Error(), "yaml: ")}
+		}
+	}
+
+	// the document decoded, so it parses into nodes as well
+	var root yaml.Node
+	_ = yaml.Unmarshal([]byte(raw), &root)
+	resources := mappingValue(&root, "Resources")
+
+	var problems []string
+	excludeLines := sequenceLines(mappingValue(resources, "Exclude"))
+	for i, ns := range doc.Resources.Exclude {
+		regex, err := namespaceRegex(ns)
+		if err == nil && (ns.ReceiverClass != "" || len(ns.Alertnames) > 0) {
+			err = fmt.Errorf("exclusions cannot set receiver_class or alertnames: %+v", ns)
+		}
+		if err != nil {
+			problems = append(problems, lineProblem(excludeLines, i, err))
+			continue
+		}
+		parsed.exclusions = append(parsed.exclusions, regex)
+	}
+
+	namespaceLines := sequenceLines(mappingValue(resources, "Namespace"))
+	for i, ns := range doc.Resources.Namespaces {
+		regex, err := namespaceRegex(ns)
+		if err != nil {
+			problems = append(problems, lineProblem(namespaceLines, i, err))
+			continue
+		}
+		parsed.namespaces = append(parsed.namespaces, regex)
+
+		override, err := parseNamespaceOverride(ns, regex)
+		if err != nil {
+			problems = append(problems, lineProblem(namespaceLines, i, err))
+			continue
+		}
+		if override != nil {
+			parsed.overrides = append(parsed.overrides, *override)
+		}
+	}
+	if len(doc.Resources.Namespaces) == 0 {
+		problems = append(problems, "no namespaces are listed under Resources.Namespace")
+	}
+	return parsed, problems
+}
+
+// mappingValue returns the value of a key of a mapping node, or of the mapping of a document node.
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	if node != nil && node.Kind == yaml.DocumentNode && len(node.Content) > 0 {
+		node = node.Content[0]
+	}
+	if node == nil || node.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// sequenceLines returns the line of each item of a sequence node.
+func sequenceLines(node *yaml.Node) []int {
+	if node == nil || node.Kind != yaml.SequenceNode {
+		return nil
+	}
+	lines := make([]int, 0, len(node.Content))
+	for _, item := range node.Content {
+		lines = append(lines, item.Line)
+	}
+	return lines
+}
+
+func lineProblem(lines []int, i int, err error) string {
+	if i < len(lines) {
+		return fmt.Sprintf("line %d: %v", lines[i], err)
+	}
+	return err.Error()
+}
+
+// parseNamespaceParseFailurePolicy returns what to route when a namespace configMap is invalid.
+func (r *SecretReconciler) parseNamespaceParseFailurePolicy(reqLogger logr.Logger, cmList *corev1.ConfigMapList, cmNamespace string) string {
+	if !cmInList(reqLogger, cmNameOperatorConfig, cmList) {
+		return namespaceParsePolicyDefaults
+	}
+
+	policy := readCMKey(r, reqLogger, cmNameOperatorConfig, cmNamespace, cmKeyNamespaceParseFailurePolicy)
+	switch policy {
+	case "", namespaceParsePolicyDefaults:
+		return namespaceParsePolicyDefaults
+	case namespaceParsePolicyLastGood:
+		return namespaceParsePolicyLastGood
+	default:
+		reqLogger.Info("WARNING: Unknown namespace parse failure policy; falling back to the default namespaces", "Policy", policy, "ConfigMap", fmt.Sprintf("%s/%s", cmNamespace, cmNameOperatorConfig))
+		return namespaceParsePolicyDefaults
+	}
+}
+
+// invalidNamespaceConfig reports the problems of a namespace configMap and returns what to route instead,
+// according to the parse failure policy: nothing, so the default namespaces are routed, or the routing
+// last read from the configMap while it was valid.
+func (r *SecretReconciler) invalidNamespaceConfig(reqLogger logr.Logger, cmName string, cmNamespace string, cmList *corev1.ConfigMapList, problems []string) namespaceConfig {
+	reqLogger.Info("ERROR: Invalid namespace configMap", "ConfigMap", fmt.Sprintf("%s/%s", cmNamespace, cmName), "Problems", problems)
+	metrics.UpdateNamespaceConfigProblems(cmName, len(problems))
+	r.recordNamespaceConfigEvent(cmName, cmList, problems)
+
+	if r.parseNamespaceParseFailurePolicy(reqLogger, cmList, cmNamespace) == namespaceParsePolicyLastGood {
+		if lastGood, ok := r.namespaceConfigLastGood[cmName]; ok {
+			reqLogger.Info("WARNING: Using the namespaces last read from the configMap", "ConfigMap", fmt.Sprintf("%s/%s", cmNamespace, cmName))
+			return lastGood
+		}
+		reqLogger.Info("WARNING: The configMap has not been valid since the operator started; falling back to the default namespaces", "ConfigMap", fmt.Sprintf("%s/%s", cmNamespace, cmName))
+	}
+	return namespaceConfig{namespaces: []string{}}
+}
+
+func (r *SecretReconciler) recordNamespaceConfigEvent(cmName string, cmList *corev1.ConfigMapList, problems []string) {
+	if r.Recorder == nil || cmList == nil {
+		return
+	}
+	for i := range cmList.Items {
+		if cmList.Items[i].Name == cmName {
+			r.Recorder.Eventf(&cmList.Items[i], corev1.EventTypeWarning, eventReasonNamespaceConfigInvalid,
+				"Invalid %s: %s", cmName, strings.Join(problems, "; "))
+			return
+		}
+	}
+}
+
+// validNamespaceConfig remembers the routing read from a valid namespace configMap.
+func (r *SecretReconciler) validNamespaceConfig(cmName string, parsed namespaceConfig) {
+	if r.namespaceConfigLastGood == nil {
+		r.namespaceConfigLastGood = map[string]namespaceConfig{}
+	}
+	r.namespaceConfigLastGood[cmName] = parsed
+	metrics.UpdateNamespaceConfigProblems(cmName, 0)
+}
@@ -0,0 +1,158 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	"github.com/openshift/configure-alertmanager-operator/config"
+	"github.com/openshift/configure-alertmanager-operator/pkg/readiness"
+	"go.uber.org/mock/gomock"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func Test_decodeNamespaceConfig(t *testing.T) {
+	tests := []struct {
+		name     string
+		raw      string
+		expected namespaceConfig
+		problems []string
+	}{
+		{
+			name: "valid",
+			raw: `Resources:
+  Namespace:
+  - name: openshift-monitoring
+  - glob: redhat-*
+    receiver_class: warning
+  Exclude:
+  - name: openshift-customer-monitoring
+`,
+			expected: namespaceConfig{
+				namespaces: []string{"^openshift-monitoring$", "^redhat-.*$"},
+				exclusions: []string{"^openshift-customer-monitoring$"},
+				overrides:  []namespaceOverride{{regex: "^redhat-.*$", receiverClass: receiverClassWarning}},
+			},
+		},
+		{
+			name: "unknown field",
+			raw: `Resources:
+  Namespace:
+  - name: openshift-monitoring
+  - nmae: openshift-logging
+`,
+			problems: []string{"line 4: field nmae not found in type alertmanagerconfig.Namespace"},
+		},
+		{
+			name: "syntax error",
+			raw: `Resources:
+  Namespace:
+  - name: openshift-monitoring
+ - name: openshift-logging
+`,
+			problems: []string{"line 3: did not find expected key"},
+		},
+		{
+			name:     "empty",
+			raw:      "",
+			problems: []string{"the document is empty"},
+		},
+		{
+			name: "invalid entries",
+			raw: `Resources:
+  Namespace:
+  - name: openshift-monitoring
+  - regex: (invalid
+  - name: openshift-logging
+    receiver_class: unknown
+  Exclude:
+  - name: openshift-customer-monitoring
+    glob: openshift-*
+`,
+			problems: []string{
+				"line 8: exactly one of name, regex and glob must be set: {Name:openshift-customer-monitoring Regex: Glob:openshift-* ReceiverClass: Alertnames:[]}",
+				"line 4: invalid namespace regex \"(invalid\": error parsing regexp: missing closing ): `(invalid`",
+				"line 5: unknown receiver_class \"unknown\"",
+			},
+		},
+		{
+			name: "no namespaces",
+			raw: `Resources:
+  Exclude:
+  - name: openshift-customer-monitoring
+`,
+			problems: []string{"no namespaces are listed under Resources.Namespace"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parsed, problems := decodeNamespaceConfig(tt.raw)
+			assertEquals(t, tt.problems, problems, "Problems")
+			if tt.problems == nil {
+				assertEquals(t, tt.expected, parsed, "Parsed")
+			}
+		})
+	}
+}
+
+func Test_parseConfigMaps_InvalidNamespaceConfig(t *testing.T) {
+	valid := `Resources:
+  Namespace:
+  - name: openshift-monitoring
+`
+	invalid := `Resources:
+  Namespace:
+  - nmae: openshift-monitoring
+`
+	tests := []struct {
+		name     string
+		policy   string
+		seen     bool
+		expected []string
+	}{
+		{name: "defaults", policy: "", seen: true, expected: defaultNamespaces},
+		{name: "last good", policy: namespaceParsePolicyLastGood, seen: true, expected: []string{"^openshift-monitoring$", "^openshift-monitoring$"}},
+		{name: "last good never seen", policy: namespaceParsePolicyLastGood, expected: defaultNamespaces},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			reconciler := createReconciler(t, readiness.NewMockInterface(ctrl))
+			recorder := record.NewFakeRecorder(10)
+			reconciler.Recorder = recorder
+			createNamespace(reconciler, t)
+			createConfigMap(reconciler, cmNameManagedNamespaces, cmKeyManagedNamespaces, valid)
+			createConfigMap(reconciler, cmNameOCPNamespaces, cmKeyOCPNamespaces, valid)
+			if tt.policy != "" {
+				createConfigMap(reconciler, cmNameOperatorConfig, cmKeyNamespaceParseFailurePolicy, tt.policy)
+			}
+
+			cmList := &corev1.ConfigMapList{}
+			if tt.seen {
+				if err := reconciler.Client.List(context.TODO(), cmList, &client.ListOptions{}); err != nil {
+					t.Fatalf("Could not list ConfigMaps: %v", err)
+				}
+				reconciler.parseConfigMaps(reqLogger, cmList, config.OperatorNamespace)
+			}
+
+			cm := &corev1.ConfigMap{}
+			if err := reconciler.Client.Get(context.TODO(), client.ObjectKey{Namespace: config.OperatorNamespace, Name: cmNameOCPNamespaces}, cm); err != nil {
+				t.Fatalf("Could not get ConfigMap: %v", err)
+			}
+			cm.Data[cmKeyOCPNamespaces] = invalid
+			if err := reconciler.Client.Update(context.TODO(), cm); err != nil {
+				t.Fatalf("Could not update ConfigMap: %v", err)
+			}
+			if err := reconciler.Client.List(context.TODO(), cmList, &client.ListOptions{}); err != nil {
+				t.Fatalf("Could not list ConfigMaps: %v", err)
+			}
+
+			namespaces, _, _ := reconciler.parseConfigMaps(reqLogger, cmList, config.OperatorNamespace)
+			assertEquals(t, tt.expected, namespaces, "Namespaces")
+			assertEquals(t, 1, len(recorder.Events), "Events recorded")
+			assertEquals(t, "Warning NamespaceConfigInvalid Invalid ocp-namespaces: line 3: field nmae not found in type alertmanagerconfig.Namespace", <-recorder.Events, "Event")
+		})
+	}
+}
@@ -0,0 +1,366 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	alertmanager "github.com/openshift/configure-alertmanager-operator/pkg/types"
+)
+
+// namespaceAlternationMaxLength caps the length of a regular expression matching several namespaces.
+// Longer lists are split into several routes.
+var namespaceAlternationMaxLength = 1024
+
+// exactNamespaceRE matches the namespace list entries that match a single namespace, e.g. ^openshift-monitoring$
+var exactNamespaceRE = regexp.MustCompile(`^\^([a-z0-9]([-a-z0-9]*[a-z0-9])?)\$$`)
+
+// defaultNamespaceExclusions are never routed as managed namespaces, whatever the namespace lists say.
+var defaultNamespaceExclusions = []string{
+	// https://issues.redhat.com/browse/OSD-2611
+	"^openshift-customer-monitoring$",
+	// https://issues.redhat.com/browse/OSD-3569
+	// https://issues.redhat.com/browse/OSD-6505
+	"^openshift-operators$",
+	// https://issues.redhat.com/browse/OSD-8337
+	// https://issues.redhat.com/browse/OSD-8349
+	"^openshift-storage$",
+	// https://issues.redhat.com/browse/OSD-8702
+	"^openshift-compliance$",
+	// https://issues.redhat.com/browse/OSD-7653
+	"^openshift-operators-redhat$",
+}
+
+// defaultExportedNamespaceExclusions are the default exclusions that also silence the alerts exported
+// from them, e.g. by a ServiceMonitor of an operator running elsewhere.
+var defaultExportedNamespaceExclusions = []string{
+	// https://issues.redhat.com/browse/OSD-8337
+	"^openshift-storage$",
+	// https://issues.redhat.com/browse/OSD-6505
+	"^openshift-operators$",
+}
+
+// namespaceRegex returns the regular expression matching a namespace entry of a namespace configMap.
+func namespaceRegex(ns alertmanager.Namespace) (string, error) {
+	set := 0
+	for _, value := range []string{ns.Name, ns.Regex, ns.Glob} {
+		if value != "" {
+			set++
+		}
+	}
+	if set != 1 {
+		return "", fmt.Errorf("exactly one of name, regex and glob must be set: %+v", ns)
+	}
+
+	switch {
+	case ns.Name != "":
+		return "^" + ns.Name + "$", nil
+	case ns.Regex != "":
+		if _, err := regexp.Compile(ns.Regex); err != nil {
+			return "", fmt.Errorf("invalid namespace regex %q: %w", ns.Regex, err)
+		}
+		return ns.Regex, nil
+	default:
+		return globRegex(ns.Glob), nil
+	}
+}
+
+// globRegex converts a glob, where * matches any characters and ? a single one, to a regular expression.
+func globRegex(glob string) string {
+	var b strings.Builder
+	b.WriteString("^")
+	for _, c := range glob {
+		switch c {
+		case '*':
+			b.WriteString(".*")
+		case '?':
+			b.WriteString(".")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	b.WriteString("$")
+	return b.String()
+}
+
+// excludedNamespaces returns the regular expression matching the default and the configured namespace
+// exclusions.
+func excludedNamespaces(namespaceExclusions []string) string {
+	return namespaceListRegex(append(append([]string{}, defaultNamespaceExclusions...), namespaceExclusions...))
+}
+
+// excludedExportedNamespaces returns the regular expression matching the exported_namespace of the
+// alerts silenced as exported from an excluded namespace: the default exported namespace exclusions
+// and the configured namespace exclusions.
+func excludedExportedNamespaces(namespaceExclusions []string) string {
+	return namespaceListRegex(append(append([]string{}, defaultExportedNamespaceExclusions...), namespaceExclusions...))
+}
+
+// namespaceListRegex combines namespace list entries, such as the exclusions, into a single regular
+// expression, factoring the exact names into an alternation.
+func namespaceListRegex(entries []string) string {
+	var names, parts []string
+	for _, entry := range entries {
+		if m := exactNamespaceRE.FindStringSubmatch(entry); m != nil {
+			names = append(names, m[1])
+		} else {
+			parts = append(parts, entry)
+		}
+	}
+	if len(names) > 0 {
+		parts = append([]string{alternation(sortedUnique(names))}, parts...)
+	}
+	if len(parts) == 1 {
+		return parts[0]
+	}
+	return "(?:" + strings.Join(parts, ")|(?:") + ")"
+}
+
+// namespaceMatch is a regular expression matching one or more entries of the namespace list.
+type namespaceMatch struct {
+	regex string
+	// collapsed indicates regex matches several namespaces, so its routes must group by namespace
+	// to notify the same as one route per namespace.
+	collapsed bool
+}
+
+// collapseNamespaces combines consecutive namespace list entries that match a single namespace into
+// alternations, so there are routes per alternation rather than per namespace. Other entries, such as
+// ^redhat-.*$, are kept in place: Alertmanager routes an alert with the first matching route, and an
+// alert is only matched by the routes of several entries if one of them is a pattern.
+func collapseNamespaces(namespaceList []string) []namespaceMatch {
+	var matches []namespaceMatch
+	var run []string
+	flush := func() {
+		matches = append(matches, alternations(run)...)
+		run = nil
+	}
+	for _, entry := range namespaceList {
+		if m := exactNamespaceRE.FindStringSubmatch(entry); m != nil {
+			run = append(run, m[1])
+			continue
+		}
+		flush()
+		matches = append(matches, namespaceMatch{regex: entry})
+	}
+	flush()
+	return matches
+}
+
+// alternations returns the regular expressions matching exactly the given namespaces, each at most
+// namespaceAlternationMaxLength long unless it matches a single namespace.
+func alternations(namespaces []string) []namespaceMatch {
+	var matches []namespaceMatch
+	var chunk []string
+	for _, ns := range sortedUnique(namespaces) {
+		if len(chunk) > 0 && len(alternation(append(chunk, ns))) > namespaceAlternationMaxLength {
+			matches = append(matches, newNamespaceMatch(chunk))
+			chunk = nil
+		}
+		chunk = append(chunk, ns)
+	}
+	if len(chunk) > 0 {
+		matches = append(matches, newNamespaceMatch(chunk))
+	}
+	return matches
+}
+
+func sortedUnique(names []string) []string {
+	unique := map[string]bool{}
+	for _, name := range names {
+		unique[name] = true
+	}
+	sorted := make([]string, 0, len(unique))
+	for name := range unique {
+		sorted = append(sorted, name)
+	}
+	sort.Strings(sorted)
+	return sorted
+}
+
+func newNamespaceMatch(namespaces []string) namespaceMatch {
+	if len(namespaces) == 1 {
+		return namespaceMatch{regex: "^" + namespaces[0] + "$"}
+	}
+	return namespaceMatch{regex: alternation(namespaces), collapsed: true}
+}
+
+// alternation builds an anchored alternation of sorted namespace names, factoring out the prefixes
+// up to the first dash, e.g. ^(?:kube-system|openshift-(?:logging|monitoring))$.
+func alternation(namespaces []string) string {
+	var parts []string
+	for i := 0; i < len(namespaces); {
+		prefix := namespacePrefix(namespaces[i])
+		j := i + 1
+		for prefix != "" && j < len(namespaces) && namespacePrefix(namespaces[j]) == prefix {
+			j++
+		}
+		if j-i == 1 {
+			parts = append(parts, namespaces[i])
+		} else {
+			var suffixes []string
+			for _, ns := range namespaces[i:j] {
+				suffixes = append(suffixes, strings.TrimPrefix(ns, prefix))
+			}
+			parts = append(parts, prefix+"(?:"+strings.Join(suffixes, "|")+")")
+		}
+		i = j
+	}
+	return "^(?:" + strings.Join(parts, "|") + ")$"
+}
+
+// namespacePrefix returns the name up to and including the first dash, or "" if there is none.
+func namespacePrefix(namespace string) string {
+	if i := strings.Index(namespace, "-"); i >= 0 {
+		return namespace[:i+1]
+	}
+	return ""
+}
+
+// receiverClassNull is the namespace override receiver class that silences the alerts.
+const receiverClassNull = "null"
+
+// namespaceOverride routes the alerts of the matching namespaces, or only the named alerts, to the
+// receiver of a class rather than by their severity.
+type namespaceOverride struct {
+	regex         string
+	receiverClass string
+	alertnames    []string
+}
+
+// builtinNamespaceOverrides are rendered in place of the per-alert rules they replaced, before the
+// namespace routes. A namespace configMap entry with an override for the same regex replaces one, see
+// activeBuiltinOverrides.
+var builtinNamespaceOverrides = []namespaceOverride{
+	// https://issues.redhat.com/browse/OSD-11273 - silence all elasticsearch alerts so we can handle only the ones that have extended logging support
+	// https://issues.redhat.com/browse/OSD-17372 - silence all loki/vector alerts for none of them is in the support scope of extended logging support
+	// For detail explanation, please check https://issues.redhat.com/browse/OSD-17371
+	// the list of alerts is pulled via
+	// ```
+	//  yq '.spec.groups[].rules[].alert | select( . != null) ' ../managed-cluster-config/resources/prometheusrules/fluentd_openshift-logging_collector.PrometheusRule.yaml | sort -u | awk '{print "\"" $1 "\","}'
+	// # for elasticsearch
+	// yq '.spec.groups[].rules[].alert | select( . != null) ' ../managed-cluster-config/resources/prometheusrules/elasticsearch_openshift-logging_elasticsearch-prometheus-rules.PrometheusRule.yaml | sort -u | awk '{print "\"" $1 "\","}'
+	// ```
+	{
+		regex:         "^openshift-logging$",
+		receiverClass: receiverClassNull,
+		alertnames: []string{
+			// fluentd alerts
+			"FluentDHighErrorRate",
+			"FluentDVeryHighErrorRate",
+			"FluentdNodeDown",
+			"FluentdQueueLengthIncreasing", //https://issues.redhat.com/browse/OSD-8403, https://issues.redhat.com/browse/OSD-8576
+			// elasticsearch alerts
+			"AggregatedLoggingSystemCPUHigh",
+			"ElasticsearchClusterNotHealthy",
+			"ElasticsearchDiskSpaceRunningLow",
+			"ElasticsearchHighFileDescriptorUsage",
+			"ElasticsearchJVMHeapUseHigh",
+			"ElasticsearchNodeDiskWatermarkReached",
+			"ElasticsearchOperatorCSVNotSuccessful",
+			"ElasticsearchProcessCPUHigh",
+			"ElasticsearchWriteRequestsRejectionJumps",
+			// vector alerts
+			"CollectorNodeDown",
+			"CollectorHighErrorRate",
+			"CollectorVeryHighErrorRate",
+			// loki alerts
+			"LokiRequestErrors",
+			"LokiStackWriteRequestErrors",
+			"LokiStackReadRequestErrors",
+			"LokiRequestPanics",
+			"LokiRequestLatency",
+			"LokiTenantRateLimit",
+			"LokiStorageSlowWrite",
+			"LokiStorageSlowRead",
+			"LokiWritePathHighLoad",
+			"LokiReadPathHighLoad",
+		},
+	},
+}
+
+// activeBuiltinOverrides returns the built-in overrides that no configured override replaces, i.e. has
+// the same namespace regex as, e.g. an entry for openshift-logging with its own receiver_class and alertnames.
+func activeBuiltinOverrides(namespaceOverrides []namespaceOverride) []namespaceOverride {
+	var active []namespaceOverride
+	for _, builtin := range builtinNamespaceOverrides {
+		replaced := false
+		for _, override := range namespaceOverrides {
+			if override.regex == builtin.regex {
+				replaced = true
+				break
+			}
+		}
+		if !replaced {
+			active = append(active, builtin)
+		}
+	}
+	return active
+}
+
+// parseNamespaceOverride returns the override of a namespace configMap entry, or nil if it has none.
+func parseNamespaceOverride(ns alertmanager.Namespace, regex string) (*namespaceOverride, error) {
+	if ns.ReceiverClass == "" {
+		if len(ns.Alertnames) > 0 {
+			return nil, fmt.Errorf("alertnames require a receiver_class: %+v", ns)
+		}
+		return nil, nil
+	}
+	switch ns.ReceiverClass {
+	case receiverClassCritical, receiverClassError, receiverClassWarning, receiverClassDefault, receiverClassNull:
+	default:
+		return nil, fmt.Errorf("unknown receiver_class %q", ns.ReceiverClass)
+	}
+	for _, name := range ns.Alertnames {
+		if name == "" {
+			return nil, fmt.Errorf("empty alertname: %+v", ns)
+		}
+	}
+	return &namespaceOverride{regex: regex, receiverClass: ns.ReceiverClass, alertnames: ns.Alertnames}, nil
+}
+
+// namespaceOverrideRoutes returns a route per override, sending the alerts of its namespaces to the
+// receiver of its class. Every route also carries the given matchers and label matches.
+func namespaceOverrideRoutes(overrides []namespaceOverride, receiverClasses map[string]string, matchers []string, match map[string]string) []*alertmanager.Route {
+	var routes []*alertmanager.Route
+	for _, override := range overrides {
+		receiver := receiverNull
+		if override.receiverClass != receiverClassNull {
+			receiver = receiverClasses[override.receiverClass]
+		}
+		matchRE := map[string]string{"namespace": override.regex}
+		if len(override.alertnames) > 0 {
+			var names []string
+			for _, name := range override.alertnames {
+				names = append(names, regexp.QuoteMeta(name))
+			}
+			matchRE["alertname"] = "^(?:" + strings.Join(names, "|") + ")$"
+		}
+		var routeMatch map[string]string
+		if len(match) > 0 {
+			routeMatch = map[string]string{}
+			for name, value := range match {
+				routeMatch[name] = value
+			}
+		}
+		routes = append(routes, &alertmanager.Route{Receiver: receiver, Matchers: matchers, MatchRE: matchRE, Match: routeMatch})
+	}
+	return routes
+}
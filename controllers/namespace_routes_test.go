@@ -0,0 +1,387 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/openshift/configure-alertmanager-operator/config"
+	"github.com/openshift/configure-alertmanager-operator/pkg/readiness"
+	alertmanager "github.com/openshift/configure-alertmanager-operator/pkg/types"
+	"go.uber.org/mock/gomock"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func Test_alternation(t *testing.T) {
+	assertEquals(t, "^(?:default|kube-(?:public|system)|openshift-monitoring)$",
+		alternation([]string{"default", "kube-public", "kube-system", "openshift-monitoring"}), "Alternation")
+}
+
+func Test_collapseNamespaces(t *testing.T) {
+	matches := collapseNamespaces([]string{"^openshift-monitoring$", "^openshift-logging$", "^redhat-.*$", "^kube-system$", "^openshift-logging$"})
+	assertEquals(t, []namespaceMatch{
+		{regex: "^(?:openshift-(?:logging|monitoring))$", collapsed: true},
+		{regex: "^redhat-.*$"},
+		{regex: "^(?:kube-system|openshift-logging)$", collapsed: true},
+	}, matches, "Collapsed namespaces")
+
+	defer func(length int) { namespaceAlternationMaxLength = length }(namespaceAlternationMaxLength)
+	namespaceAlternationMaxLength = 40
+	var namespaces []string
+	for i := 0; i < 10; i++ {
+		namespaces = append(namespaces, fmt.Sprintf("^openshift-ns%d$", i))
+	}
+	for _, match := range collapseNamespaces(namespaces) {
+		assertTrue(t, len(match.regex) <= namespaceAlternationMaxLength, "Alternation too long: "+match.regex)
+	}
+}
+
+// simulateRoute returns the receiver of the route an alert is routed to, and the key of the
+// aggregation group Alertmanager puts it in. Routes are assumed not to continue.
+func simulateRoute(t *testing.T, route *alertmanager.Route, labels map[string]string, path string, groupBy []string) (string, string) {
+	if len(route.GroupByStr) > 0 {
+		groupBy = route.GroupByStr
+	}
+	for i, child := range route.Routes {
+		if routeMatches(t, child, labels) {
+			return simulateRoute(t, child, labels, fmt.Sprintf("%s/%d", path, i), groupBy)
+		}
+	}
+	var key []string
+	for _, label := range groupBy {
+		key = append(key, label+"="+labels[label])
+	}
+	return route.Receiver, path + "{" + strings.Join(key, ",") + "}"
+}
+
+func routeMatches(t *testing.T, route *alertmanager.Route, labels map[string]string) bool {
+	for name, value := range route.Match {
+		if labels[name] != value {
+			return false
+		}
+	}
+	for name, value := range route.MatchRE {
+		if !regexp.MustCompile("^(?:" + value + ")$").MatchString(labels[name]) {
+			return false
+		}
+	}
+	for _, matcher := range route.Matchers {
+		name, op, value, err := alertmanager.ParseMatcher(matcher)
+		if err != nil {
+			t.Fatalf("Invalid matcher: %v", err)
+		}
+		re := regexp.MustCompile("^(?:" + value + ")$")
+		matches := map[string]bool{
+			alertmanager.MatchEqual:     labels[name] == value,
+			alertmanager.MatchNotEqual:  labels[name] != value,
+			alertmanager.MatchRegexp:    re.MatchString(labels[name]),
+			alertmanager.MatchNotRegexp: !re.MatchString(labels[name]),
+		}
+		if !matches[op] {
+			return false
+		}
+	}
+	return true
+}
+
+// Test_createSubroutes_CollapsedEquivalent checks that collapsing the namespace routes doesn't change
+// the receiver of any alert, nor which alerts are notified together.
+func Test_createSubroutes_CollapsedEquivalent(t *testing.T) {
+	namespaceList := []string{"^openshift-monitoring$", "^openshift-logging$", "^redhat-.*$", "^redhat-rhoam-operator$", "^kube-system$", "^openshift-ingress$"}
+	for i := 0; i < 150; i++ {
+		namespaceList = append(namespaceList, fmt.Sprintf("^openshift-managed-%d$", i))
+	}
+
+	var alerts []map[string]string
+	for _, ns := range append([]string{"openshift-monitoring", "openshift-logging", "redhat-rhoam-operator", "redhat-other", "kube-system", "openshift-managed-7", "openshift-managed-149", "customer", "openshift-storage"}, "") {
+		for _, severity := range []string{"critical", "error", "warning", "info"} {
+			for _, alertname := range []string{"KubePodCrashLooping", "TargetDown"} {
+				alerts = append(alerts,
+					map[string]string{"alertname": alertname, "severity": severity, "namespace": ns, "prometheus": "openshift-monitoring/k8s"},
+					map[string]string{"alertname": alertname, "severity": severity, "namespace": "openshift-monitoring", "exported_namespace": ns, "prometheus": "openshift-monitoring/k8s"},
+					map[string]string{"alertname": alertname, "severity": severity, "namespace": ns, "prometheus": "openshift-user-workload-monitoring/user-workload"},
+				)
+			}
+		}
+	}
+
+	for _, receiver := range []receiverType{Pagerduty, GoAlert} {
+		collapsed := createSubroutes(namespaceList, nil, nil, receiver, defaultRouteSettings(), nil)
+
+		length := namespaceAlternationMaxLength
+		namespaceAlternationMaxLength = 0
+		expanded := createSubroutes(namespaceList, nil, nil, receiver, defaultRouteSettings(), nil)
+		namespaceAlternationMaxLength = length
+
+		assertTrue(t, len(collapsed.Routes) < len(expanded.Routes)-len(namespaceList), "Routes were not collapsed")
+
+		// alerts in the same group in one tree must be in the same group in the other
+		collapsedGroups := map[string]string{}
+		expandedGroups := map[string]string{}
+		for _, alert := range alerts {
+			collapsedReceiver, collapsedKey := simulateRoute(t, collapsed, alert, "", nil)
+			expandedReceiver, expandedKey := simulateRoute(t, expanded, alert, "", nil)
+			assertEquals(t, expandedReceiver, collapsedReceiver, fmt.Sprintf("Receiver of %v", alert))
+
+			if previous, ok := collapsedGroups[collapsedKey]; ok {
+				assertEquals(t, previous, expandedKey, fmt.Sprintf("Group of %v", alert))
+			}
+			if previous, ok := expandedGroups[expandedKey]; ok {
+				assertEquals(t, previous, collapsedKey, fmt.Sprintf("Group of %v", alert))
+			}
+			collapsedGroups[collapsedKey] = expandedKey
+			expandedGroups[expandedKey] = collapsedKey
+		}
+
+	}
+}
+
+func Test_namespaceRegex(t *testing.T) {
+	tests := []struct {
+		namespace alertmanager.Namespace
+		expected  string
+		valid     bool
+	}{
+		{namespace: alertmanager.Namespace{Name: "openshift-monitoring"}, expected: "^openshift-monitoring$", valid: true},
+		{namespace: alertmanager.Namespace{Regex: "^openshift-.*"}, expected: "^openshift-.*", valid: true},
+		{namespace: alertmanager.Namespace{Glob: "redhat-*-operator?"}, expected: "^redhat-.*-operator.$", valid: true},
+		{namespace: alertmanager.Namespace{Glob: "a.b"}, expected: `^a\.b$`, valid: true},
+		{namespace: alertmanager.Namespace{Regex: "(unclosed"}},
+		{namespace: alertmanager.Namespace{Name: "a", Glob: "b*"}},
+		{namespace: alertmanager.Namespace{}},
+	}
+	for _, tt := range tests {
+		regex, err := namespaceRegex(tt.namespace)
+		assertEquals(t, tt.valid, err == nil, fmt.Sprintf("Validity of %+v", tt.namespace))
+		assertEquals(t, tt.expected, regex, fmt.Sprintf("Regex of %+v", tt.namespace))
+	}
+}
+
+func Test_createSubroutes_Exclusions(t *testing.T) {
+	namespaceList := []string{"^openshift-.*", "^openshift-monitoring$", "^redhat-.*$"}
+	exclusions := []string{globRegex("openshift-customer-*"), "^redhat-excluded$"}
+
+	tests := []struct {
+		labels  map[string]string
+		routed  bool
+		comment string
+		// GoAlert doesn't route alerts by exported_namespace
+		pagerdutyOnly bool
+	}{
+		{labels: map[string]string{"namespace": "openshift-monitoring"}, routed: true},
+		{labels: map[string]string{"namespace": "openshift-ingress"}, routed: true},
+		{labels: map[string]string{"namespace": "openshift-monitoring", "exported_namespace": "openshift-ingress"}, routed: true, pagerdutyOnly: true},
+		{labels: map[string]string{"namespace": "openshift-storage"}, comment: "default exclusion"},
+		{labels: map[string]string{"namespace": "openshift-operators-redhat"}, comment: "default exclusion"},
+		{labels: map[string]string{"namespace": "openshift-monitoring", "exported_namespace": "openshift-operators"}, comment: "exported from a default exclusion"},
+		{labels: map[string]string{"namespace": "openshift-monitoring", "exported_namespace": "openshift-compliance"}, routed: true, pagerdutyOnly: true, comment: "only namespace is silenced for this default exclusion"},
+		{labels: map[string]string{"namespace": "openshift-monitoring", "exported_namespace": "redhat-excluded"}, comment: "exported from a configured exclusion"},
+		{labels: map[string]string{"namespace": "openshift-customer-apps"}, comment: "glob exclusion"},
+		{labels: map[string]string{"namespace": "redhat-excluded"}, comment: "name exclusion"},
+		{labels: map[string]string{"namespace": "customer"}, comment: "not listed"},
+	}
+
+	for _, receiver := range []receiverType{Pagerduty, GoAlert} {
+		route := createSubroutes(namespaceList, exclusions, nil, receiver, defaultRouteSettings(), nil)
+		for _, tt := range tests {
+			if tt.pagerdutyOnly && receiver != Pagerduty {
+				continue
+			}
+			alert := map[string]string{"alertname": "KubePodCrashLooping", "severity": "critical", "prometheus": "openshift-monitoring/k8s"}
+			for name, value := range tt.labels {
+				alert[name] = value
+			}
+			routedTo, _ := simulateRoute(t, route, alert, "", nil)
+			assertEquals(t, tt.routed, routedTo != receiverNull, fmt.Sprintf("Alert %v (%s) routed to %s", tt.labels, tt.comment, routedTo))
+		}
+	}
+}
+
+func Test_createSubroutes_ExclusionsBeforeGlobalRules(t *testing.T) {
+	exclusions := []string{"^redhat-excluded$"}
+	// each of these matches a global rule routing to a receiver other than null
+	alerts := []map[string]string{
+		{"alertname": "FluentdQueueLengthIncreasing", "severity": "warning", "job": "fluentd", "prometheus": "openshift-monitoring/k8s"},
+		{"alertname": "ElasticsearchClusterNotHealthy", "severity": "critical", "cluster": "elasticsearch", "prometheus": "openshift-monitoring/k8s"},
+		{"alertname": "NodeClockNotSynchronising", "severity": "warning", "prometheus": "openshift-monitoring/k8s"},
+		{"alertname": "KubeAPILatencyHigh", "severity": "critical"},
+	}
+
+	for _, receiver := range []receiverType{Pagerduty, GoAlert} {
+		route := createSubroutes(defaultNamespaces, exclusions, nil, receiver, defaultRouteSettings(), nil)
+		for _, alert := range alerts {
+			routedTo, _ := simulateRoute(t, route, alert, "", nil)
+			assertTrue(t, routedTo != receiverNull, fmt.Sprintf("Alert %v routed to %s", alert, routedTo))
+
+			for _, namespace := range []map[string]string{
+				{"namespace": "openshift-storage"},
+				{"namespace": "openshift-customer-monitoring"},
+				{"namespace": "openshift-monitoring", "exported_namespace": "openshift-operators"},
+				{"namespace": "redhat-excluded"},
+			} {
+				labels := map[string]string{}
+				for name, value := range alert {
+					labels[name] = value
+				}
+				for name, value := range namespace {
+					labels[name] = value
+				}
+				routedTo, _ := simulateRoute(t, route, labels, "", nil)
+				assertEquals(t, receiverNull, routedTo, fmt.Sprintf("Alert %v", labels))
+			}
+		}
+	}
+}
+
+func Test_parseConfigMaps_PatternsAndExclusions(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	reconciler := createReconciler(t, readiness.NewMockInterface(ctrl))
+	createNamespace(reconciler, t)
+	createConfigMap(reconciler, cmNameManagedNamespaces, cmKeyManagedNamespaces, `Resources:
+  Namespace:
+  - name: openshift-monitoring
+  - regex: ^openshift-.*
+  Exclude:
+  - name: openshift-customer-monitoring
+  - glob: openshift-*-sandbox
+`)
+	createConfigMap(reconciler, cmNameOCPNamespaces, cmKeyOCPNamespaces, `Resources:
+  Namespace:
+  - glob: redhat-*
+  - name: openshift-sandbox
+    receiver_class: warning
+  - glob: openshift-noisy-*
+    receiver_class: "null"
+    alertnames: [KubePodCrashLooping]
+`)
+
+	cmList := &corev1.ConfigMapList{}
+	if err := reconciler.Client.List(context.TODO(), cmList, &client.ListOptions{}); err != nil {
+		t.Fatalf("Could not list ConfigMaps: %v", err)
+	}
+	namespaces, exclusions, overrides := reconciler.parseConfigMaps(reqLogger, cmList, config.OperatorNamespace)
+	assertEquals(t, []string{"^openshift-monitoring$", "^openshift-.*", "^redhat-.*$", "^openshift-sandbox$", "^openshift-noisy-.*$"}, namespaces, "Namespaces")
+	assertEquals(t, []string{"^openshift-customer-monitoring$", "^openshift-.*-sandbox$"}, exclusions, "Exclusions")
+	assertEquals(t, []namespaceOverride{
+		{regex: "^openshift-sandbox$", receiverClass: receiverClassWarning},
+		{regex: "^openshift-noisy-.*$", receiverClass: receiverClassNull, alertnames: []string{"KubePodCrashLooping"}},
+	}, overrides, "Overrides")
+}
+
+func Test_parseNamespaceOverride(t *testing.T) {
+	tests := []struct {
+		name     string
+		ns       alertmanager.Namespace
+		expected *namespaceOverride
+		err      bool
+	}{
+		{name: "no override", ns: alertmanager.Namespace{Name: "openshift-monitoring"}},
+		{name: "receiver class", ns: alertmanager.Namespace{Name: "openshift-monitoring", ReceiverClass: "warning"}, expected: &namespaceOverride{regex: "^openshift-monitoring$", receiverClass: "warning"}},
+		{name: "alertnames", ns: alertmanager.Namespace{Name: "openshift-monitoring", ReceiverClass: "null", Alertnames: []string{"A"}}, expected: &namespaceOverride{regex: "^openshift-monitoring$", receiverClass: "null", alertnames: []string{"A"}}},
+		{name: "alertnames without receiver class", ns: alertmanager.Namespace{Name: "openshift-monitoring", Alertnames: []string{"A"}}, err: true},
+		{name: "unknown receiver class", ns: alertmanager.Namespace{Name: "openshift-monitoring", ReceiverClass: "pagerduty"}, err: true},
+		{name: "empty alertname", ns: alertmanager.Namespace{Name: "openshift-monitoring", ReceiverClass: "null", Alertnames: []string{""}}, err: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			override, err := parseNamespaceOverride(tt.ns, "^openshift-monitoring$")
+			assertEquals(t, tt.err, err != nil, fmt.Sprintf("Error %v", err))
+			assertEquals(t, tt.expected, override, "Override")
+		})
+	}
+}
+
+func Test_createSubroutes_Overrides(t *testing.T) {
+	namespaceList := []string{"^openshift-.*", "^redhat-.*$"}
+	overrides := []namespaceOverride{
+		{regex: "^openshift-sandbox$", receiverClass: receiverClassWarning},
+		{regex: "^redhat-.*$", receiverClass: receiverClassNull, alertnames: []string{"KubePodCrashLooping", "KubeJob.Failed"}},
+		{regex: "^openshift-storage$", receiverClass: receiverClassCritical},
+	}
+
+	tests := []struct {
+		labels   map[string]string
+		expected map[receiverType]string
+		comment  string
+	}{
+		{
+			labels:   map[string]string{"alertname": "KubePodCrashLooping", "namespace": "openshift-sandbox", "severity": "critical"},
+			expected: map[receiverType]string{Pagerduty: receiverMakeItWarning, GoAlert: receiverGoAlertLow},
+			comment:  "severity remapped to warning",
+		},
+		{
+			labels:   map[string]string{"alertname": "KubePodCrashLooping", "namespace": "openshift-sandbox", "severity": "critical", "prometheus": "openshift-user-workload-monitoring/user-workload"},
+			expected: map[receiverType]string{Pagerduty: receiverNull, GoAlert: receiverNull},
+			comment:  "only platform alerts are overridden",
+		},
+		{
+			labels:   map[string]string{"alertname": "KubePodCrashLooping", "namespace": "openshift-sandbox", "exported_namespace": "openshift-ingress", "severity": "critical"},
+			expected: map[receiverType]string{Pagerduty: receiverPagerduty, GoAlert: receiverNull},
+			comment:  "exported alerts follow the exported_namespace route",
+		},
+		{
+			labels:   map[string]string{"alertname": "KubePodCrashLooping", "namespace": "redhat-app", "severity": "critical"},
+			expected: map[receiverType]string{Pagerduty: receiverNull, GoAlert: receiverNull},
+			comment:  "silenced alertname",
+		},
+		{
+			labels:   map[string]string{"alertname": "KubeJobXFailed", "namespace": "redhat-app", "severity": "critical"},
+			expected: map[receiverType]string{Pagerduty: receiverPagerduty, GoAlert: receiverGoAlertHigh},
+			comment:  "alertnames are matched literally",
+		},
+		{
+			labels:   map[string]string{"alertname": "KubeDeploymentReplicasMismatch", "namespace": "redhat-app", "severity": "critical"},
+			expected: map[receiverType]string{Pagerduty: receiverPagerduty, GoAlert: receiverGoAlertHigh},
+			comment:  "other alertnames follow the namespace routes",
+		},
+		{
+			labels:   map[string]string{"alertname": "KubePodCrashLooping", "namespace": "openshift-storage", "severity": "critical"},
+			expected: map[receiverType]string{Pagerduty: receiverNull, GoAlert: receiverNull},
+			comment:  "exclusions take precedence",
+		},
+		{
+			labels:   map[string]string{"alertname": "LokiRequestErrors", "namespace": "openshift-logging", "severity": "critical"},
+			expected: map[receiverType]string{Pagerduty: receiverNull, GoAlert: receiverNull},
+			comment:  "built-in override",
+		},
+		{
+			labels:   map[string]string{"alertname": "LokiRequestErrorsSRE", "namespace": "openshift-logging", "severity": "critical"},
+			expected: map[receiverType]string{Pagerduty: receiverPagerduty, GoAlert: receiverGoAlertLow},
+			comment:  "SRE alerts are not silenced",
+		},
+	}
+
+	for _, receiver := range []receiverType{Pagerduty, GoAlert} {
+		route := createSubroutes(namespaceList, nil, overrides, receiver, defaultRouteSettings(), nil)
+		for _, tt := range tests {
+			alert := map[string]string{"prometheus": "openshift-monitoring/k8s"}
+			for name, value := range tt.labels {
+				alert[name] = value
+			}
+			routedTo, _ := simulateRoute(t, route, alert, "", nil)
+			assertEquals(t, tt.expected[receiver], routedTo, fmt.Sprintf("Alert %v (%s)", tt.labels, tt.comment))
+		}
+	}
+}
+
+func Test_createSubroutes_BuiltinOverrideReplaced(t *testing.T) {
+	// only silence one of the built-in openshift-logging alerts
+	overrides := []namespaceOverride{
+		{regex: "^openshift-logging$", receiverClass: receiverClassNull, alertnames: []string{"LokiRequestLatency"}},
+	}
+
+	for _, receiver := range []receiverType{Pagerduty, GoAlert} {
+		route := createSubroutes(defaultNamespaces, nil, overrides, receiver, defaultRouteSettings(), nil)
+		alert := map[string]string{"alertname": "LokiRequestErrors", "namespace": "openshift-logging", "severity": "critical", "prometheus": "openshift-monitoring/k8s"}
+		routedTo, _ := simulateRoute(t, route, alert, "", nil)
+		assertTrue(t, routedTo != receiverNull, fmt.Sprintf("Alert %v routed to %s", alert, routedTo))
+
+		alert["alertname"] = "LokiRequestLatency"
+		routedTo, _ = simulateRoute(t, route, alert, "", nil)
+		assertEquals(t, receiverNull, routedTo, fmt.Sprintf("Alert %v", alert))
+	}
+}
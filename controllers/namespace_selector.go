@@ -0,0 +1,133 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	"github.com/openshift/configure-alertmanager-operator/config"
+)
+
+// operator configmap key holding a label selector for the namespaces to page for
+const cmKeyNamespaceSelector = "namespace_selector"
+
+// parseNamespaceSelector returns the label selector configured in the operator configMap, or nil if
+// there is none or it is invalid.
+func (r *SecretReconciler) parseNamespaceSelector(reqLogger logr.Logger, cmList *corev1.ConfigMapList, cmNamespace string) labels.Selector {
+	if !cmInList(reqLogger, cmNameOperatorConfig, cmList) {
+		return nil
+	}
+	raw := strings.TrimSpace(readCMKey(r, reqLogger, cmNameOperatorConfig, cmNamespace, cmKeyNamespaceSelector))
+	if raw == "" {
+		return nil
+	}
+	selector, err := labels.Parse(raw)
+	if err != nil {
+		reqLogger.Info("WARNING: Invalid namespace selector; using the namespace configMaps", "Selector", raw, "Error", err.Error(),
+			"ConfigMap", fmt.Sprintf("%s/%s", cmNamespace, cmNameOperatorConfig))
+		return nil
+	}
+	if selector.Empty() {
+		reqLogger.Info("WARNING: Namespace selector selects every namespace; using the namespace configMaps", "Selector", raw)
+		return nil
+	}
+	return selector
+}
+
+// selectedNamespaces returns the namespaces matching the selector as a list of regular expressions.
+func (r *SecretReconciler) selectedNamespaces(reqLogger logr.Logger, selector labels.Selector) []string {
+	nsList := &corev1.NamespaceList{}
+	if err := r.Client.List(context.TODO(), nsList, client.MatchingLabelsSelector{Selector: selector}); err != nil {
+		reqLogger.Error(err, "Unable to list namespaces", "Selector", selector.String())
+		return []string{}
+	}
+
+	namespaces := []string{}
+	for _, ns := range nsList.Items {
+		if ns.DeletionTimestamp != nil {
+			continue
+		}
+		namespaces = append(namespaces, "^"+ns.Name+"$")
+	}
+	// the list order decides the route order, keep it stable
+	sort.Strings(namespaces)
+	return namespaces
+}
+
+// namespaceToOperatorConfig maps a Namespace event to a reconcile of the operator configMap, which
+// holds the namespace selector. Reconcile ignores objects in other namespaces.
+func namespaceToOperatorConfig(client.Object) []reconcile.Request {
+	return []reconcile.Request{
+		{NamespacedName: types.NamespacedName{Namespace: config.OperatorNamespace, Name: cmNameOperatorConfig}},
+	}
+}
+
+// useNamespaceSelector records the namespace selector in effect, nil if there is none, for the Namespace
+// watch. The watch, and the cache of all Namespaces behind it, is only started once a selector is configured.
+func (r *SecretReconciler) useNamespaceSelector(reqLogger logr.Logger, selector labels.Selector) {
+	r.namespaceSelectorLock.Lock()
+	r.namespaceSelector = selector
+	r.namespaceSelectorLock.Unlock()
+
+	if selector == nil || r.namespaceWatchStarted || r.controller == nil {
+		return
+	}
+	err := r.controller.Watch(source.NewKindWithCache(&corev1.Namespace{}, r.cache),
+		handler.EnqueueRequestsFromMapFunc(namespaceToOperatorConfig), r.namespaceEventFilter())
+	if err != nil {
+		reqLogger.Error(err, "ERROR: Unable to watch namespaces; namespaces matching the namespace selector are only picked up on the next reconcile")
+		return
+	}
+	r.namespaceWatchStarted = true
+}
+
+// namespaceSelected reports whether the namespace matches the namespace selector in effect.
+func (r *SecretReconciler) namespaceSelected(obj client.Object) bool {
+	r.namespaceSelectorLock.Lock()
+	defer r.namespaceSelectorLock.Unlock()
+	return r.namespaceSelector != nil && r.namespaceSelector.Matches(labels.Set(obj.GetLabels()))
+}
+
+// namespaceEventFilter passes the Namespace events that change the namespaces matching the selector:
+// matching namespaces created or deleted, and label changes of a namespace matching before or after.
+func (r *SecretReconciler) namespaceEventFilter() predicate.Funcs {
+	return predicate.Funcs{
+		CreateFunc: func(e event.CreateEvent) bool { return r.namespaceSelected(e.Object) },
+		DeleteFunc: func(e event.DeleteEvent) bool { return r.namespaceSelected(e.Object) },
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			if labels.Equals(e.ObjectOld.GetLabels(), e.ObjectNew.GetLabels()) {
+				return false
+			}
+			return r.namespaceSelected(e.ObjectOld) || r.namespaceSelected(e.ObjectNew)
+		},
+		GenericFunc: func(event.GenericEvent) bool { return false },
+	}
+}
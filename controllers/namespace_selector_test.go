@@ -0,0 +1,113 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	"github.com/openshift/configure-alertmanager-operator/config"
+	"github.com/openshift/configure-alertmanager-operator/pkg/readiness"
+	"go.uber.org/mock/gomock"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+)
+
+func Test_parseConfigMaps_NamespaceSelector(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	tests := []struct {
+		name     string
+		selector string
+		expected []string
+	}{
+		{
+			name:     "Matching namespaces",
+			selector: "openshift.io/cluster-monitoring=true",
+			expected: []string{"^openshift-ingress$", "^openshift-monitoring$"},
+		},
+		{
+			name:     "Set based selector",
+			selector: "openshift.io/cluster-monitoring in (true), !example.com/skip",
+			expected: []string{"^openshift-monitoring$"},
+		},
+		{
+			name:     "No matching namespaces",
+			selector: "example.com/missing=true",
+			expected: defaultNamespaces,
+		},
+		{
+			name:     "Invalid selector",
+			selector: "not a selector!",
+			expected: defaultNamespaces,
+		},
+		{
+			name:     "No selector",
+			expected: defaultNamespaces,
+		},
+	}
+
+	for _, tt := range tests {
+		reconciler := createReconciler(t, readiness.NewMockInterface(ctrl))
+		for _, ns := range []*corev1.Namespace{
+			{ObjectMeta: metav1.ObjectMeta{Name: config.OperatorNamespace, Labels: map[string]string{"openshift.io/cluster-monitoring": "true"}}},
+			{ObjectMeta: metav1.ObjectMeta{Name: "openshift-ingress", Labels: map[string]string{"openshift.io/cluster-monitoring": "true", "example.com/skip": ""}}},
+			{ObjectMeta: metav1.ObjectMeta{Name: "customer-app"}},
+		} {
+			if err := reconciler.Client.Create(context.TODO(), ns); err != nil {
+				t.Fatalf("Could not create namespace: %v", err)
+			}
+		}
+		createConfigMap(reconciler, cmNameOperatorConfig, cmKeyNamespaceSelector, tt.selector)
+
+		cmList := &corev1.ConfigMapList{}
+		if err := reconciler.Client.List(context.TODO(), cmList, &client.ListOptions{}); err != nil {
+			t.Fatalf("Could not list ConfigMaps: %v", err)
+		}
+
+		namespaces, _, _ := reconciler.parseConfigMaps(reqLogger, cmList, config.OperatorNamespace)
+		assertEquals(t, tt.expected, namespaces, tt.name)
+	}
+}
+
+func Test_namespaceToOperatorConfig(t *testing.T) {
+	requests := namespaceToOperatorConfig(&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "openshift-ingress"}})
+	assertEquals(t, 1, len(requests), "Number of requests")
+	assertEquals(t, config.OperatorNamespace, requests[0].Namespace, "Request namespace")
+	assertEquals(t, cmNameOperatorConfig, requests[0].Name, "Request name")
+}
+
+func Test_namespaceEventFilter(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	reconciler := createReconciler(t, readiness.NewMockInterface(ctrl))
+	filter := reconciler.namespaceEventFilter()
+
+	namespace := func(labels map[string]string) *corev1.Namespace {
+		return &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "app", Labels: labels}}
+	}
+	selected := namespace(map[string]string{"sre": "page"})
+	other := namespace(map[string]string{"team": "app"})
+
+	// without a selector no Namespace event reconciles
+	reconciler.useNamespaceSelector(reqLogger, nil)
+	assertFalse(t, filter.Create(event.CreateEvent{Object: selected}), "Create without a selector")
+	assertFalse(t, filter.Delete(event.DeleteEvent{Object: selected}), "Delete without a selector")
+
+	// no controller to watch with in the tests, so only the selector is recorded
+	selector, _ := labels.Parse("sre=page")
+	reconciler.useNamespaceSelector(reqLogger, selector)
+	assertFalse(t, reconciler.namespaceWatchStarted, "Namespace watch started")
+
+	assertTrue(t, filter.Create(event.CreateEvent{Object: selected}), "Create of a matching namespace")
+	assertFalse(t, filter.Create(event.CreateEvent{Object: other}), "Create of another namespace")
+	assertTrue(t, filter.Delete(event.DeleteEvent{Object: selected}), "Delete of a matching namespace")
+	assertFalse(t, filter.Delete(event.DeleteEvent{Object: other}), "Delete of another namespace")
+	assertTrue(t, filter.Update(event.UpdateEvent{ObjectOld: other, ObjectNew: selected}), "Namespace starts matching")
+	assertTrue(t, filter.Update(event.UpdateEvent{ObjectOld: selected, ObjectNew: other}), "Namespace stops matching")
+	assertFalse(t, filter.Update(event.UpdateEvent{ObjectOld: selected, ObjectNew: selected}), "Labels unchanged")
+	assertFalse(t, filter.Update(event.UpdateEvent{ObjectOld: other, ObjectNew: namespace(map[string]string{"team": "other"})}), "Another namespace relabelled")
+	assertFalse(t, filter.Generic(event.GenericEvent{Object: selected}), "Generic")
+}
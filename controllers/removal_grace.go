@@ -0,0 +1,174 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/openshift/configure-alertmanager-operator/pkg/metrics"
+)
+
+const (
+	// operator configmap key for how long an integration source may be missing before its receivers are removed
+	cmKeyRemovalGracePeriod = "removal_grace_period"
+
+	defaultRemovalGracePeriod = 10 * time.Minute
+
+	// RemovalAnnotation, set to "true" on the Secret or ConfigMap of an integration, removes the integration's
+	// receivers straight away, so deleting the source afterwards is not mistaken for it disappearing unexpectedly.
+	RemovalAnnotation = "configure-alertmanager-operator.managed.openshift.io/remove"
+)
+
+// heldSource is the last copy seen of the Secret or ConfigMap of an integration.
+type heldSource struct {
+	object client.Object
+	// removeAt is when the receivers of the integration are removed if its source is still missing;
+	// zero while the source exists.
+	removeAt time.Time
+}
+
+// parseRemovalGracePeriod returns how long an integration source may be missing before its receivers are removed.
+func (r *SecretReconciler) parseRemovalGracePeriod(reqLogger logr.Logger, cmList *corev1.ConfigMapList, cmNamespace string) time.Duration {
+	if !cmInList(reqLogger, cmNameOperatorConfig, cmList) {
+		return defaultRemovalGracePeriod
+	}
+
+	raw := readCMKey(r, reqLogger, cmNameOperatorConfig, cmNamespace, cmKeyRemovalGracePeriod)
+	if raw == "" {
+		return defaultRemovalGracePeriod
+	}
+	grace, err := time.ParseDuration(raw)
+	if err != nil || grace < 0 {
+		reqLogger.Info("WARNING: Invalid removal grace period; using the default", "Value", raw, "Default", defaultRemovalGracePeriod.String(), "ConfigMap", fmt.Sprintf("%s/%s", cmNamespace, cmNameOperatorConfig))
+		return defaultRemovalGracePeriod
+	}
+	return grace
+}
+
+// holdRemovedSources returns the Secrets and ConfigMaps to configure the integrations from. The source of an
+// integration that disappeared less than the grace period ago is replaced by the last copy seen, so a source
+// briefly missing, e.g. while Hive resyncs it, doesn't remove the integration's receivers. A source with the
+// removal annotation is left out, removing its receivers straight away. The lists passed in are not modified.
+func (r *SecretReconciler) holdRemovedSources(reqLogger logr.Logger, secretList *corev1.SecretList, cmList *corev1.ConfigMapList, cmNamespace string, now time.Time) (*corev1.SecretList, *corev1.ConfigMapList) {
+	grace := r.parseRemovalGracePeriod(reqLogger, cmList, cmNamespace)
+	if r.heldSources == nil {
+		r.heldSources = map[string]*heldSource{}
+	}
+
+	sources := map[string]client.Object{}
+	for i := range secretList.Items {
+		sources["Secret/"+secretList.Items[i].Name] = &secretList.Items[i]
+	}
+	for i := range cmList.Items {
+		sources["ConfigMap/"+cmList.Items[i].Name] = &cmList.Items[i]
+	}
+
+	removed := map[string]bool{}
+	var held []client.Object
+	for _, i := range integrations {
+		key := "Secret/" + i.secretName
+		if i.secretName == "" {
+			key = "ConfigMap/" + i.configMapName
+		}
+
+		source, exists := sources[key]
+		switch {
+		case exists && source.GetAnnotations()[RemovalAnnotation] == "true":
+			reqLogger.Info("INFO: Integration source is marked for removal; removing its receivers", "Integration", i.name, "Source", key)
+			removed[key] = true
+			delete(r.heldSources, i.name)
+		case exists:
+			r.heldSources[i.name] = &heldSource{object: source.DeepCopyObject().(client.Object)}
+		case r.heldSources[i.name] != nil:
+			last := r.heldSources[i.name]
+			if last.removeAt.IsZero() {
+				last.removeAt = now.Add(grace)
+			}
+			if !now.Before(last.removeAt) {
+				reqLogger.Info("INFO: Integration source has been missing for the removal grace period; removing its receivers", "Integration", i.name, "Source", key, "GracePeriod", grace.String())
+				delete(r.heldSources, i.name)
+				break
+			}
+			reqLogger.Info("WARNING: Integration source is missing; keeping its receivers until the removal grace period ends", "Integration", i.name, "Source", key, "Until", last.removeAt.UTC().Format(time.RFC3339))
+			held = append(held, last.object)
+		}
+
+		var removeAt time.Time
+		if last := r.heldSources[i.name]; last != nil {
+			removeAt = last.removeAt
+		}
+		metrics.UpdateIntegrationRemovalPending(i.name, removeAt)
+	}
+
+	heldSecrets := &corev1.SecretList{}
+	for _, secret := range secretList.Items {
+		if !removed["Secret/"+secret.Name] {
+			heldSecrets.Items = append(heldSecrets.Items, secret)
+		}
+	}
+	heldConfigMaps := &corev1.ConfigMapList{}
+	for _, cm := range cmList.Items {
+		if !removed["ConfigMap/"+cm.Name] {
+			heldConfigMaps.Items = append(heldConfigMaps.Items, cm)
+		}
+	}
+	for _, object := range held {
+		switch source := object.(type) {
+		case *corev1.Secret:
+			heldSecrets.Items = append(heldSecrets.Items, *source)
+		case *corev1.ConfigMap:
+			heldConfigMaps.Items = append(heldConfigMaps.Items, *source)
+		}
+	}
+	return heldSecrets, heldConfigMaps
+}
+
+// pendingRemovals returns the integrations whose source is missing, but whose receivers are kept until the
+// removal grace period ends.
+func (r *SecretReconciler) pendingRemovals() map[string]bool {
+	pending := map[string]bool{}
+	for name, last := range r.heldSources {
+		if !last.removeAt.IsZero() {
+			pending[name] = true
+		}
+	}
+	return pending
+}
+
+// removalGraceResult requeues the reconcile for when the first pending removal is due, if there is one.
+func (r *SecretReconciler) removalGraceResult(now time.Time) reconcile.Result {
+	var result reconcile.Result
+	for _, last := range r.heldSources {
+		if last.removeAt.IsZero() {
+			continue
+		}
+		remaining := last.removeAt.Sub(now)
+		if remaining < time.Second {
+			remaining = time.Second
+		}
+		if result.RequeueAfter == 0 || remaining < result.RequeueAfter {
+			result = reconcile.Result{RequeueAfter: remaining}
+		}
+	}
+	return result
+}
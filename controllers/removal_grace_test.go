@@ -0,0 +1,137 @@
+package controllers
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/openshift/configure-alertmanager-operator/config"
+	"github.com/openshift/configure-alertmanager-operator/pkg/readiness"
+	"go.uber.org/mock/gomock"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func pdSecret(key string, annotations map[string]string) corev1.Secret {
+	return corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: secretNamePD, Namespace: config.OperatorNamespace, Annotations: annotations},
+		Data:       map[string][]byte{secretKeyPD: []byte(key)},
+	}
+}
+
+func Test_holdRemovedSources(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	reconciler := createReconciler(t, readiness.NewMockInterface(ctrl))
+	cmList := &corev1.ConfigMapList{}
+	now := time.Now()
+
+	// nothing is held for sources never seen
+	secrets, _ := reconciler.holdRemovedSources(reqLogger, &corev1.SecretList{}, cmList, config.OperatorNamespace, now)
+	assertEquals(t, 0, len(secrets.Items), "Held secrets")
+
+	secretList := &corev1.SecretList{Items: []corev1.Secret{pdSecret("pd-key", nil)}}
+	secrets, _ = reconciler.holdRemovedSources(reqLogger, secretList, cmList, config.OperatorNamespace, now)
+	assertEquals(t, "pd-key", secretKeyInList(secrets, secretNamePD, secretKeyPD), "Existing secret")
+	assertEquals(t, reconcile.Result{}, reconciler.removalGraceResult(now), "Result without pending removals")
+
+	// the secret goes missing
+	secrets, _ = reconciler.holdRemovedSources(reqLogger, &corev1.SecretList{}, cmList, config.OperatorNamespace, now)
+	assertEquals(t, "pd-key", secretKeyInList(secrets, secretNamePD, secretKeyPD), "Held secret")
+	assertEquals(t, reconcile.Result{RequeueAfter: defaultRemovalGracePeriod}, reconciler.removalGraceResult(now), "Result with a pending removal")
+	assertEquals(t, map[string]bool{"pagerduty": true}, reconciler.pendingRemovals(), "Pending removals")
+
+	// still within the grace period, counted from when the secret first went missing
+	later := now.Add(defaultRemovalGracePeriod - time.Minute)
+	secrets, _ = reconciler.holdRemovedSources(reqLogger, &corev1.SecretList{}, cmList, config.OperatorNamespace, later)
+	assertEquals(t, "pd-key", secretKeyInList(secrets, secretNamePD, secretKeyPD), "Held secret")
+	assertEquals(t, reconcile.Result{RequeueAfter: time.Minute}, reconciler.removalGraceResult(later), "Result with a pending removal")
+
+	// the grace period is over
+	later = now.Add(defaultRemovalGracePeriod)
+	secrets, _ = reconciler.holdRemovedSources(reqLogger, &corev1.SecretList{}, cmList, config.OperatorNamespace, later)
+	assertFalse(t, secretInList(reqLogger, secretNamePD, secrets), "Secret held after the grace period")
+	assertEquals(t, reconcile.Result{}, reconciler.removalGraceResult(later), "Result after the grace period")
+	assertEquals(t, map[string]bool{}, reconciler.pendingRemovals(), "Pending removals after the grace period")
+
+	// a secret that comes back within the grace period is no longer pending removal
+	reconciler.holdRemovedSources(reqLogger, secretList, cmList, config.OperatorNamespace, now)
+	reconciler.holdRemovedSources(reqLogger, &corev1.SecretList{}, cmList, config.OperatorNamespace, now)
+	reconciler.holdRemovedSources(reqLogger, secretList, cmList, config.OperatorNamespace, now)
+	assertEquals(t, reconcile.Result{}, reconciler.removalGraceResult(now), "Result once the secret is back")
+
+	// a secret marked for removal is removed straight away, and not held once deleted
+	marked := &corev1.SecretList{Items: []corev1.Secret{pdSecret("pd-key", map[string]string{RemovalAnnotation: "true"})}}
+	secrets, _ = reconciler.holdRemovedSources(reqLogger, marked, cmList, config.OperatorNamespace, now)
+	assertFalse(t, secretInList(reqLogger, secretNamePD, secrets), "Secret marked for removal")
+	assertEquals(t, 1, len(marked.Items), "The list passed in was modified")
+	secrets, _ = reconciler.holdRemovedSources(reqLogger, &corev1.SecretList{}, cmList, config.OperatorNamespace, now)
+	assertFalse(t, secretInList(reqLogger, secretNamePD, secrets), "Deleted secret held after it was marked for removal")
+}
+
+func Test_parseRemovalGracePeriod(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected time.Duration
+	}{
+		{value: "", expected: defaultRemovalGracePeriod},
+		{value: "30m", expected: 30 * time.Minute},
+		{value: "0s", expected: 0},
+		{value: "-5m", expected: defaultRemovalGracePeriod},
+		{value: "soon", expected: defaultRemovalGracePeriod},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			reconciler := createReconciler(t, readiness.NewMockInterface(ctrl))
+			createNamespace(reconciler, t)
+			createConfigMap(reconciler, cmNameOperatorConfig, cmKeyRemovalGracePeriod, tt.value)
+			cmList := &corev1.ConfigMapList{}
+			if err := reconciler.Client.List(context.TODO(), cmList); err != nil {
+				t.Fatalf("Could not list ConfigMaps: %v", err)
+			}
+			assertEquals(t, tt.expected, reconciler.parseRemovalGracePeriod(reqLogger, cmList, config.OperatorNamespace), "Grace period")
+		})
+	}
+}
+
+func Test_Reconcile_RemovalGracePeriod(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockReadiness := readiness.NewMockInterface(ctrl)
+	mockReadiness.EXPECT().IsReady().AnyTimes().Return(true, nil)
+	mockReadiness.EXPECT().Result().AnyTimes().Return(reconcile.Result{})
+	reconciler := createReconciler(t, mockReadiness)
+	createNamespace(reconciler, t)
+	createClusterVersion(reconciler)
+	createClusterProxy(reconciler)
+	createSecret(reconciler, secretNamePD, secretKeyPD, "pd-key")
+	req := createReconcileRequest(reconciler, secretNamePD)
+
+	_, err := reconciler.Reconcile(context.TODO(), *req)
+	assertEquals(t, nil, err, "Unexpected err")
+	assertTrue(t, strings.Contains(readAlertManagerSecret(t, reconciler), "pd-key"), "PagerDuty is not configured")
+
+	secret := &corev1.Secret{}
+	if err := reconciler.Client.Get(context.TODO(), types.NamespacedName{Name: secretNamePD, Namespace: config.OperatorNamespace}, secret); err != nil {
+		t.Fatalf("Could not read secret: %v", err)
+	}
+	if err := reconciler.Client.Delete(context.TODO(), secret); err != nil {
+		t.Fatalf("Could not delete secret: %v", err)
+	}
+
+	result, err := reconciler.Reconcile(context.TODO(), *req)
+	assertEquals(t, nil, err, "Unexpected err")
+	assertTrue(t, strings.Contains(readAlertManagerSecret(t, reconciler), "pd-key"), "PagerDuty was removed within the grace period")
+	assertTrue(t, result.RequeueAfter > 0 && result.RequeueAfter <= defaultRemovalGracePeriod, "Reconcile not requeued for the end of the grace period")
+
+	// the removal is due
+	reconciler.heldSources["pagerduty"].removeAt = time.Now()
+	_, err = reconciler.Reconcile(context.TODO(), *req)
+	assertEquals(t, nil, err, "Unexpected err")
+	assertFalse(t, strings.Contains(readAlertManagerSecret(t, reconciler), "pd-key"), "PagerDuty is still configured after the grace period")
+}
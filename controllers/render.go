@@ -0,0 +1,91 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"fmt"
+
+	configv1 "github.com/openshift/api/config/v1"
+	"gopkg.in/yaml.v2"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/openshift/configure-alertmanager-operator/config"
+)
+
+// RenderInput holds local stand-ins for everything the operator reads from the cluster.
+type RenderInput struct {
+	// Secrets are matched by name: pd-secret, goalert-secret and dms-secret.
+	Secrets []corev1.Secret
+	// ConfigMaps are matched by name: managed-namespaces, ocp-namespaces, ocm-agent and configure-alertmanager-operator.
+	ConfigMaps []corev1.ConfigMap
+	// ClusterID is used in the PagerDuty details.
+	ClusterID string
+	// ClusterProxy is the HTTPS proxy of the cluster, if any.
+	ClusterProxy string
+	// ClusterReady enables PagerDuty and GoAlert, as the readiness checks would.
+	ClusterReady bool
+}
+
+// Render creates the alertmanager.yaml the operator would write for the given input, without a cluster.
+// Objects are moved to the operator namespace; the FedRAMP setting is taken from the config package.
+func Render(in RenderInput) ([]byte, error) {
+	scheme := runtime.NewScheme()
+	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
+	utilruntime.Must(configv1.Install(scheme))
+
+	objects := []client.Object{
+		&configv1.ClusterVersion{
+			ObjectMeta: metav1.ObjectMeta{Name: "version"},
+			Spec:       configv1.ClusterVersionSpec{ClusterID: configv1.ClusterID(in.ClusterID)},
+		},
+		&configv1.Proxy{
+			ObjectMeta: metav1.ObjectMeta{Name: "cluster"},
+			Status:     configv1.ProxyStatus{HTTPSProxy: in.ClusterProxy},
+		},
+	}
+	for i := range in.Secrets {
+		secret := in.Secrets[i].DeepCopy()
+		secret.Namespace = config.OperatorNamespace
+		secret.ResourceVersion = ""
+		objects = append(objects, secret)
+	}
+	for i := range in.ConfigMaps {
+		cm := in.ConfigMaps[i].DeepCopy()
+		cm.Namespace = config.OperatorNamespace
+		cm.ResourceVersion = ""
+		objects = append(objects, cm)
+	}
+
+	r := &SecretReconciler{
+		Client: fake.NewClientBuilder().WithScheme(scheme).WithObjects(objects...).Build(),
+		Scheme: scheme,
+	}
+	reqLogger := log.WithValues("Render", true)
+	amconfig, _, _, _ := r.buildAlertManagerConfig(reqLogger, config.OperatorNamespace, in.ClusterReady)
+
+	amconfigbyte, err := yaml.Marshal(amconfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal Alertmanager config: %w", err)
+	}
+	return amconfigbyte, nil
+}
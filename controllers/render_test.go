@@ -0,0 +1,61 @@
+package controllers
+
+import (
+	"testing"
+
+	"gopkg.in/yaml.v2"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	alertmanager "github.com/openshift/configure-alertmanager-operator/pkg/types"
+)
+
+func Test_Render(t *testing.T) {
+	pdKey := "asdaidsgadfi9853"
+	wdURL := "http://theinterwebs/asdf"
+
+	tests := []struct {
+		name         string
+		clusterReady bool
+		expectedKey  string
+	}{
+		{
+			name:         "Cluster ready",
+			clusterReady: true,
+			expectedKey:  pdKey,
+		},
+		{
+			name:         "Cluster not ready",
+			clusterReady: false,
+		},
+	}
+
+	for _, tt := range tests {
+		in := RenderInput{
+			Secrets: []corev1.Secret{
+				// the namespace is ignored
+				{ObjectMeta: metav1.ObjectMeta{Name: secretNamePD, Namespace: "elsewhere"}, Data: map[string][]byte{secretKeyPD: []byte(pdKey)}},
+				{ObjectMeta: metav1.ObjectMeta{Name: secretNameDMS}, Data: map[string][]byte{secretKeyDMS: []byte(wdURL)}},
+			},
+			ConfigMaps: []corev1.ConfigMap{
+				{ObjectMeta: metav1.ObjectMeta{Name: cmNameOperatorConfig}, Data: map[string]string{cmKeyRouteSettings: "repeat_interval: 4h\n"}},
+			},
+			ClusterID:    exampleClusterId,
+			ClusterProxy: exampleProxy,
+			ClusterReady: tt.clusterReady,
+		}
+
+		rendered, err := Render(in)
+		assertEquals(t, nil, err, tt.name)
+
+		actual := &alertmanager.Config{}
+		err = yaml.Unmarshal(rendered, actual)
+		assertEquals(t, nil, err, tt.name)
+
+		settings := defaultRouteSettings()
+		settings.RepeatInterval = "4h"
+		expected := createAlertManagerConfig(reqLogger, tt.expectedKey, "", "", "", wdURL, "", exampleClusterId, exampleProxy, defaultNamespaces, alertmanagerConfigOptions{routeSettings: settings})
+		expectedRaw, _ := yaml.Marshal(expected)
+		assertEquals(t, string(expectedRaw), string(rendered), tt.name)
+	}
+}
@@ -0,0 +1,174 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/prometheus/common/model"
+	"gopkg.in/yaml.v2"
+	corev1 "k8s.io/api/core/v1"
+
+	alertmanager "github.com/openshift/configure-alertmanager-operator/pkg/types"
+)
+
+const (
+	// Receiver classes that can have their route timing overridden
+	receiverClassCritical = "critical"
+	receiverClassError    = "error"
+	receiverClassWarning  = "warning"
+	receiverClassDefault  = "default"
+)
+
+var labelNameRE = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// defaultRouteSettings returns the route timing and grouping used when nothing is overridden.
+func defaultRouteSettings() *alertmanager.RouteSettings {
+	return &alertmanager.RouteSettings{
+		GroupBy:                 []string{"job"},
+		GroupWait:               "30s",
+		GroupInterval:           "5m",
+		RepeatInterval:          "12h",
+		ResolveTimeout:          "5m",
+		WatchdogRepeatInterval:  "5m",
+		HeartbeatRepeatInterval: "5m",
+		OCMAgentRepeatInterval:  "10m",
+		ReceiverClasses:         map[string]alertmanager.RouteTiming{},
+	}
+}
+
+// parseRouteSettings returns the route settings from the operator configMap merged over the defaults.
+// Invalid values are logged and replaced by their default.
+func (r *SecretReconciler) parseRouteSettings(reqLogger logr.Logger, cmList *corev1.ConfigMapList, cmNamespace string) *alertmanager.RouteSettings {
+	if !cmInList(reqLogger, cmNameOperatorConfig, cmList) {
+		return defaultRouteSettings()
+	}
+
+	rawSettings := readCMKey(r, reqLogger, cmNameOperatorConfig, cmNamespace, cmKeyRouteSettings)
+	if rawSettings == "" {
+		return defaultRouteSettings()
+	}
+
+	overrides := &alertmanager.RouteSettings{}
+	if err := yaml.Unmarshal([]byte(rawSettings), overrides); err != nil {
+		reqLogger.Info("WARNING: Unable to unmarshal route settings; using the defaults", "ConfigMap", fmt.Sprintf("%s/%s", cmNamespace, cmNameOperatorConfig), "Error", err.Error())
+		return defaultRouteSettings()
+	}
+	return mergeRouteSettings(reqLogger, overrides)
+}
+
+// mergeRouteSettings validates the overrides and applies the valid ones over the defaults.
+func mergeRouteSettings(reqLogger logr.Logger, overrides *alertmanager.RouteSettings) *alertmanager.RouteSettings {
+	settings := defaultRouteSettings()
+
+	if len(overrides.GroupBy) > 0 {
+		if err := validateGroupBy(overrides.GroupBy); err != nil {
+			reqLogger.Info("WARNING: Ignoring invalid route setting", "Setting", "group_by", "Error", err.Error())
+		} else {
+			settings.GroupBy = overrides.GroupBy
+		}
+	}
+
+	mergeDuration(reqLogger, "group_wait", overrides.GroupWait, true, &settings.GroupWait)
+	mergeDuration(reqLogger, "group_interval", overrides.GroupInterval, false, &settings.GroupInterval)
+	mergeDuration(reqLogger, "repeat_interval", overrides.RepeatInterval, false, &settings.RepeatInterval)
+	mergeDuration(reqLogger, "resolve_timeout", overrides.ResolveTimeout, false, &settings.ResolveTimeout)
+	mergeDuration(reqLogger, "watchdog_repeat_interval", overrides.WatchdogRepeatInterval, false, &settings.WatchdogRepeatInterval)
+	mergeDuration(reqLogger, "heartbeat_repeat_interval", overrides.HeartbeatRepeatInterval, false, &settings.HeartbeatRepeatInterval)
+	mergeDuration(reqLogger, "ocm_agent_repeat_interval", overrides.OCMAgentRepeatInterval, false, &settings.OCMAgentRepeatInterval)
+
+	for class, timing := range overrides.ReceiverClasses {
+		switch class {
+		case receiverClassCritical, receiverClassError, receiverClassWarning, receiverClassDefault:
+		default:
+			reqLogger.Info("WARNING: Ignoring route settings for unknown receiver class", "Class", class)
+			continue
+		}
+		merged := alertmanager.RouteTiming{}
+		mergeDuration(reqLogger, "receiver_classes."+class+".group_wait", timing.GroupWait, true, &merged.GroupWait)
+		mergeDuration(reqLogger, "receiver_classes."+class+".group_interval", timing.GroupInterval, false, &merged.GroupInterval)
+		mergeDuration(reqLogger, "receiver_classes."+class+".repeat_interval", timing.RepeatInterval, false, &merged.RepeatInterval)
+		settings.ReceiverClasses[class] = merged
+	}
+
+	return settings
+}
+
+// mergeDuration sets *target to value if value is a valid Prometheus duration.
+func mergeDuration(reqLogger logr.Logger, setting string, value string, allowZero bool, target *string) {
+	if value == "" {
+		return
+	}
+	d, err := model.ParseDuration(value)
+	if err != nil {
+		reqLogger.Info("WARNING: Ignoring invalid route setting", "Setting", setting, "Value", value, "Error", err.Error())
+		return
+	}
+	if !allowZero && time.Duration(d) == 0 {
+		reqLogger.Info("WARNING: Ignoring invalid route setting", "Setting", setting, "Value", value, "Error", "must be greater than zero")
+		return
+	}
+	*target = value
+}
+
+// validateGroupBy checks the group_by labels, allowing the special "..." value on its own.
+func validateGroupBy(groupBy []string) error {
+	if len(groupBy) == 1 && groupBy[0] == "..." {
+		return nil
+	}
+	for _, label := range groupBy {
+		if !labelNameRE.MatchString(label) {
+			return fmt.Errorf("invalid label name %q", label)
+		}
+	}
+	return nil
+}
+
+// applyReceiverClassTiming sets the timing configured for each receiver class on the routes sending to it.
+// receiverClasses maps a receiver class to the receiver used for that class by the integration.
+func applyReceiverClassTiming(routes []*alertmanager.Route, receiverClasses map[string]string, settings *alertmanager.RouteSettings) {
+	if len(settings.ReceiverClasses) == 0 {
+		return
+	}
+	for _, route := range routes {
+		class := receiverClassOf(route, receiverClasses)
+		timing, ok := settings.ReceiverClasses[class]
+		if !ok {
+			continue
+		}
+		route.GroupWait = timing.GroupWait
+		route.GroupInterval = timing.GroupInterval
+		route.RepeatInterval = timing.RepeatInterval
+	}
+}
+
+// receiverClassOf returns the receiver class of a route. Integrations may share a receiver between
+// classes, in which case the severity the route matches on decides.
+func receiverClassOf(route *alertmanager.Route, receiverClasses map[string]string) string {
+	if severity, ok := route.Match["severity"]; ok && receiverClasses[severity] == route.Receiver {
+		return severity
+	}
+	for _, class := range []string{receiverClassCritical, receiverClassError, receiverClassWarning, receiverClassDefault} {
+		if receiverClasses[class] == route.Receiver {
+			return class
+		}
+	}
+	return ""
+}
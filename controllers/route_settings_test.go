@@ -0,0 +1,140 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	"github.com/openshift/configure-alertmanager-operator/pkg/readiness"
+	alertmanager "github.com/openshift/configure-alertmanager-operator/pkg/types"
+	"go.uber.org/mock/gomock"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func Test_mergeRouteSettings(t *testing.T) {
+	settings := mergeRouteSettings(reqLogger, &alertmanager.RouteSettings{
+		GroupBy:                []string{"alertname", "namespace"},
+		GroupWait:              "0s",
+		GroupInterval:          "0s",
+		RepeatInterval:         "not-a-duration",
+		ResolveTimeout:         "10m",
+		WatchdogRepeatInterval: "1m",
+		ReceiverClasses: map[string]alertmanager.RouteTiming{
+			receiverClassCritical: {RepeatInterval: "1h"},
+			receiverClassWarning:  {RepeatInterval: "24h", GroupWait: "bogus"},
+			"unknown":             {RepeatInterval: "1h"},
+		},
+	})
+
+	defaults := defaultRouteSettings()
+	assertEquals(t, []string{"alertname", "namespace"}, settings.GroupBy, "GroupBy")
+	assertEquals(t, "0s", settings.GroupWait, "GroupWait may be zero")
+	assertEquals(t, defaults.GroupInterval, settings.GroupInterval, "GroupInterval may not be zero")
+	assertEquals(t, defaults.RepeatInterval, settings.RepeatInterval, "Invalid RepeatInterval")
+	assertEquals(t, "10m", settings.ResolveTimeout, "ResolveTimeout")
+	assertEquals(t, "1m", settings.WatchdogRepeatInterval, "WatchdogRepeatInterval")
+	assertEquals(t, defaults.HeartbeatRepeatInterval, settings.HeartbeatRepeatInterval, "HeartbeatRepeatInterval")
+	assertEquals(t, defaults.OCMAgentRepeatInterval, settings.OCMAgentRepeatInterval, "OCMAgentRepeatInterval")
+	assertEquals(t, 2, len(settings.ReceiverClasses), "ReceiverClasses")
+	assertEquals(t, alertmanager.RouteTiming{RepeatInterval: "1h"}, settings.ReceiverClasses[receiverClassCritical], "Critical timing")
+	assertEquals(t, alertmanager.RouteTiming{RepeatInterval: "24h"}, settings.ReceiverClasses[receiverClassWarning], "Warning timing")
+
+	invalidGroupBy := mergeRouteSettings(reqLogger, &alertmanager.RouteSettings{GroupBy: []string{"not a label"}})
+	assertEquals(t, defaults.GroupBy, invalidGroupBy.GroupBy, "Invalid GroupBy")
+}
+
+func Test_createAlertManagerConfig_WithRouteSettings(t *testing.T) {
+	settings := mergeRouteSettings(reqLogger, &alertmanager.RouteSettings{
+		GroupBy:                 []string{"alertname"},
+		GroupWait:               "1m",
+		GroupInterval:           "10m",
+		RepeatInterval:          "6h",
+		ResolveTimeout:          "15m",
+		WatchdogRepeatInterval:  "2m",
+		HeartbeatRepeatInterval: "3m",
+		OCMAgentRepeatInterval:  "20m",
+		ReceiverClasses: map[string]alertmanager.RouteTiming{
+			receiverClassCritical: {RepeatInterval: "1h"},
+			receiverClassError:    {RepeatInterval: "4h"},
+			receiverClassWarning:  {RepeatInterval: "24h"},
+		},
+	})
+
+	config := createAlertManagerConfig(reqLogger, "pdkey", "https://dummy-galow-url", "https://dummy-gahigh-url", "https://dummy-gaheartbeat-url", "http://theinterwebs", "https://dummy-oa-url", exampleClusterId, exampleProxy, exampleManagedNamespaces, alertmanagerConfigOptions{routeSettings: settings})
+
+	assertEquals(t, "15m", config.Global.ResolveTimeout, "Global.ResolveTimeout")
+	assertEquals(t, []string{"alertname"}, config.Route.GroupByStr, "Route.GroupByStr")
+	assertEquals(t, "1m", config.Route.GroupWait, "Route.GroupWait")
+	assertEquals(t, "10m", config.Route.GroupInterval, "Route.GroupInterval")
+	assertEquals(t, "6h", config.Route.RepeatInterval, "Route.RepeatInterval")
+	assertEquals(t, "2m", config.Route.Routes[0].RepeatInterval, "Watchdog RepeatInterval")
+	assertEquals(t, "20m", config.Route.Routes[1].RepeatInterval, "OCM Agent RepeatInterval")
+	assertEquals(t, "3m", config.Route.Routes[4].RepeatInterval, "Heartbeat RepeatInterval")
+
+	expected := map[string]string{
+		receiverMakeItCritical: "1h",
+		receiverMakeItError:    "4h",
+		receiverMakeItWarning:  "24h",
+		receiverPagerduty:      "",
+		receiverNull:           "",
+	}
+	for _, route := range config.Route.Routes[2].Routes {
+		assertEquals(t, expected[route.Receiver], route.RepeatInterval, "PagerDuty route to "+route.Receiver)
+	}
+
+	// GoAlert uses the same receiver for critical and error alerts
+	for _, route := range config.Route.Routes[3].Routes {
+		switch {
+		case route.Receiver == receiverGoAlertHigh && route.Match["severity"] == "error":
+			assertEquals(t, "4h", route.RepeatInterval, "GoAlert error route")
+		case route.Receiver == receiverGoAlertHigh:
+			assertEquals(t, "1h", route.RepeatInterval, "GoAlert critical route")
+		case route.Receiver == receiverGoAlertLow:
+			assertEquals(t, "24h", route.RepeatInterval, "GoAlert warning route")
+		default:
+			assertEquals(t, "", route.RepeatInterval, "GoAlert route to "+route.Receiver)
+		}
+	}
+}
+
+func Test_parseRouteSettings(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	tests := []struct {
+		name                   string
+		data                   *string
+		expectedRepeatInterval string
+	}{
+		{
+			name:                   "Missing configMap",
+			expectedRepeatInterval: "12h",
+		},
+		{
+			name:                   "Invalid configMap",
+			data:                   stringPtr("- this is not a settings document"),
+			expectedRepeatInterval: "12h",
+		},
+		{
+			name:                   "Valid configMap",
+			data:                   stringPtr("repeat_interval: 4h\nreceiver_classes:\n  critical:\n    repeat_interval: 1h\n"),
+			expectedRepeatInterval: "4h",
+		},
+	}
+
+	for _, tt := range tests {
+		reconciler := createReconciler(t, readiness.NewMockInterface(ctrl))
+		createNamespace(reconciler, t)
+		if tt.data != nil {
+			createConfigMap(reconciler, cmNameOperatorConfig, cmKeyRouteSettings, *tt.data)
+		}
+
+		cmList := &corev1.ConfigMapList{}
+		if err := reconciler.Client.List(context.TODO(), cmList, &client.ListOptions{}); err != nil {
+			t.Fatalf("Could not list ConfigMaps: %v", err)
+		}
+
+		settings := reconciler.parseRouteSettings(reqLogger, cmList, createReconcileRequest(reconciler, cmNameOperatorConfig).Namespace)
+		assertEquals(t, tt.expectedRepeatInterval, settings.RepeatInterval, tt.name)
+	}
+}
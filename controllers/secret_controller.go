@@ -19,15 +19,21 @@ package controllers
 import (
 	"context"
 	"fmt"
+	"io"
 	"net/url"
+	"sync"
+	"time"
 
-	"gopkg.in/yaml.v2"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
 	"sigs.k8s.io/controller-runtime/pkg/handler"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
@@ -40,6 +46,7 @@ import (
 	"github.com/openshift/configure-alertmanager-operator/pkg/metrics"
 	"github.com/openshift/configure-alertmanager-operator/pkg/readiness"
 	alertmanager "github.com/openshift/configure-alertmanager-operator/pkg/types"
+	"github.com/openshift/configure-alertmanager-operator/pkg/verify"
 )
 
 var log = logf.Log.WithName("secret_controller")
@@ -132,6 +139,21 @@ const (
 
 	// OCM Agent configmap key for service URL
 	cmKeyOCMAgent = "serviceURL"
+
+	// configmap holding optional settings for the operator itself
+	cmNameOperatorConfig = config.OperatorName
+
+	// operator configmap key for the inhibit rule overrides
+	cmKeyInhibitRules = "inhibit_rules.yaml"
+
+	// operator configmap key for the route timing and grouping settings
+	cmKeyRouteSettings = "route_settings.yaml"
+
+	// reconcile phases, as reported by the cam_reconcile_duration_seconds metric
+	phaseList   = "list"
+	phaseParse  = "parse"
+	phaseRender = "render"
+	phaseWrite  = "write"
 )
 
 var defaultNamespaces = []string{
@@ -145,6 +167,33 @@ type SecretReconciler struct {
 	Client    client.Client
 	Scheme    *runtime.Scheme
 	Readiness readiness.Interface
+	Recorder  record.EventRecorder
+	// APIReader, if set, reads the objects the controller doesn't watch, such as the alertmanager-main
+	// StatefulSet, so the cache doesn't start a cluster-wide informer for them.
+	APIReader client.Reader
+
+	// DryRun reports the changes to alertmanager-main instead of writing them.
+	DryRun bool
+	// DiffWriter, if set, receives the diffs computed in dry-run mode instead of an Event and the dry run configMap.
+	DiffWriter io.Writer
+	// Verifier, if set, is used to check that Alertmanager loaded the written config.
+	Verifier *verify.Verifier
+
+	verifyState configVerifyState
+	// rollbackState is the value of the rollback annotation last seen, to record Events when it changes.
+	rollbackState string
+	// namespaceConfigLastGood is the routing last read from each namespace configMap while it was valid.
+	namespaceConfigLastGood map[string]namespaceConfig
+	// heldSources are the last copies seen of the integration sources, by integration.
+	heldSources map[string]*heldSource
+
+	// controller and cache, set by SetupWithManager, are used to start the Namespace watch.
+	controller            controller.Controller
+	cache                 cache.Cache
+	namespaceWatchStarted bool
+	// namespaceSelector is the namespace selector in effect, read by the Namespace watch.
+	namespaceSelector     labels.Selector
+	namespaceSelectorLock sync.Mutex
 }
 
 //+kubebuilder:rbac:groups=managed.openshift.io,resources=secrets,verbs=get;list;watch;create;update;patch;delete
@@ -177,6 +226,7 @@ func (r *SecretReconciler) Reconcile(ctx context.Context, request ctrl.Request)
 	case cmNameOcmAgent:
 	case cmNameManagedNamespaces:
 	case cmNameOCPNamespaces:
+	case cmNameOperatorConfig:
 	default:
 		reqLogger.Info("Skip reconcile: No changes detected to alertmanager secrets.")
 		return reconcile.Result{}, nil
@@ -189,14 +239,93 @@ func (r *SecretReconciler) Reconcile(ctx context.Context, request ctrl.Request)
 		return r.Readiness.Result(), err
 	}
 
+	alertmanagerconfig, secretList, cmList, pagingEnabled := r.buildAlertManagerConfig(reqLogger, request.Namespace, clusterReady)
+
+	mountsPending := false
+	var writeErr error
+	var verifyResult reconcile.Result
+
+	if r.DryRun {
+		// report what would change, and leave the secret metrics alone since nothing was written
+		r.reportConfigDiff(reqLogger, alertmanagerconfig, cmList)
+	} else {
+		// write the alertmanager Config
+		start := time.Now()
+		var written []byte
+		written, writeErr = r.applyAlertManagerConfig(reqLogger, alertmanagerconfig, cmList, request.NamespacedName.String())
+		metrics.ObserveReconcilePhase(phaseWrite, time.Since(start))
+		if writeErr == nil && r.Verifier != nil {
+			verifyResult = r.verifyAppliedConfig(ctx, reqLogger, written)
+		}
+
+		// Mount the source secrets into Alertmanager for the credentials files mode. This happens after the
+		// write so a secret is only unmounted once the config no longer references it.
+		mountsPending, err = r.syncAlertmanagerSecretMounts(reqLogger, secretList, r.parseCredentialsMode(reqLogger, cmList, request.Namespace))
+		if err != nil {
+			reqLogger.Error(err, "ERROR: Could not update the secrets mounted into Alertmanager")
+		}
+
+		// Update metrics after all reconcile operations are complete.
+		metrics.UpdateSecretsMetrics(secretList)
+		metrics.UpdateIntegrationMetrics(integrationStates(reqLogger, secretList, cmList, r.pendingRemovals(), alertmanagerconfig, writeErr == nil, pagingEnabled))
+	}
+	metrics.UpdateConfigMapMetrics(cmList)
+	metrics.UpdateGeneratedConfigMetrics(countRoutes(alertmanagerconfig.Route), len(alertmanagerconfig.Receivers))
+	if writeErr != nil {
+		// retry with the controller's backoff rather than waiting for the next change
+		return reconcile.Result{}, writeErr
+	}
+	reqLogger.Info("Finished reconcile for secret.")
+
+	// The readiness Result decides whether we should requeue, effectively "polling" the readiness logic.
+	result := r.Readiness.Result()
+	if mountsPending && (result.RequeueAfter == 0 || result.RequeueAfter > credentialMountRequeueDelay) {
+		// keep checking until the cluster monitoring operator has mounted the secrets
+		result = reconcile.Result{Requeue: true, RequeueAfter: credentialMountRequeueDelay}
+	}
+	if verifyResult.RequeueAfter > 0 && (result.RequeueAfter == 0 || result.RequeueAfter > verifyResult.RequeueAfter) {
+		// check again that Alertmanager loaded the config
+		result = verifyResult
+	}
+	if removalResult := r.removalGraceResult(time.Now()); removalResult.RequeueAfter > 0 && (result.RequeueAfter == 0 || result.RequeueAfter > removalResult.RequeueAfter) {
+		// remove the receivers of the integrations whose source is still missing once the grace period ends
+		result = removalResult
+	}
+	return result, nil
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *SecretReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	client := mgr.GetClient()
+
+	r.Readiness = &readiness.Impl{Client: client, Recorder: r.Recorder}
+
+	// Namespaces are only watched once a namespace selector is configured, see useNamespaceSelector
+	c, err := ctrl.NewControllerManagedBy(mgr).
+		For(&corev1.Secret{}).
+		Watches(&source.Kind{Type: &corev1.ConfigMap{}}, &handler.EnqueueRequestForObject{}).
+		Build(r)
+	if err != nil {
+		return err
+	}
+	r.controller = c
+	r.cache = mgr.GetCache()
+	return nil
+}
+
+// buildAlertManagerConfig reads the secrets and configMaps in the namespace, plus the cluster ID and proxy,
+// and creates the desired alertmanager Config. The lists, and whether PagerDuty and GoAlert were allowed
+// to be configured given the cluster readiness, are returned for the metrics.
+func (r *SecretReconciler) buildAlertManagerConfig(reqLogger logr.Logger, namespace string, clusterReady bool) (*alertmanager.Config, *corev1.SecretList, *corev1.ConfigMapList, bool) {
 	// Get a list of all relevant objects in the `openshift-monitoring` namespace.
 	// This is used for determining which secrets and configMaps are present so that the necessary
 	// Alertmanager config changes can happen later.
+	start := time.Now()
 	opts := []client.ListOption{
-		client.InNamespace(request.Namespace),
+		client.InNamespace(namespace),
 	}
 	secretList := &corev1.SecretList{}
-	err = r.Client.List(context.TODO(), secretList, opts...)
+	err := r.Client.List(context.TODO(), secretList, opts...)
 	if err != nil {
 		reqLogger.Error(err, "Unable to list secrets")
 	}
@@ -206,12 +335,32 @@ func (r *SecretReconciler) Reconcile(ctx context.Context, request ctrl.Request)
 	if err != nil {
 		reqLogger.Error(err, "Unable to list configMaps")
 	}
+	// keep configuring the integrations whose source went missing until the removal grace period ends; the
+	// lists returned for the metrics are left as they are
+	heldSecretList, heldCMList := r.holdRemovedSources(reqLogger, secretList, cmList, namespace, time.Now())
+	start = observePhase(phaseList, start)
+
+	// Before the cluster is ready, PagerDuty and GoAlert are configured only if some alerts are allowed to page.
+	var installPaging []alertmanager.InstallPagingRule
+	if !clusterReady {
+		installPaging = r.parseInstallPaging(reqLogger, heldCMList, namespace)
+	}
+	configurePaging := clusterReady || len(installPaging) > 0
 
-	pagerdutyRoutingKey, watchdogURL, goalertURLlow, goalertURLhigh, goalertURLheartbeat := r.parseSecrets(reqLogger, secretList, request.Namespace, clusterReady)
-	osdNamespaces := r.parseConfigMaps(reqLogger, cmList, request.Namespace)
+	pagerdutyRoutingKey, watchdogURL, goalertURLlow, goalertURLhigh, goalertURLheartbeat := r.parseSecrets(reqLogger, heldSecretList, namespace, configurePaging)
+	if !clusterReady && configurePaging {
+		reqLogger.Info("INFO: Cluster is not ready; paging only for the install paging rules", "Rules", len(installPaging))
+		// the heartbeat would page for a cluster that isn't up yet
+		goalertURLheartbeat = ""
+	}
+	osdNamespaces, namespaceExclusions, namespaceOverrides := r.parseConfigMaps(reqLogger, heldCMList, namespace)
 	reqLogger.Info("DEBUG: Adding PagerDuty routes for the following namespaces", "Namespaces", osdNamespaces)
 
-	ocmAgentURL := r.readOCMAgentServiceURLFromConfig(reqLogger, cmList, request.Namespace)
+	goalert := parseGoalertRouting(reqLogger, heldSecretList)
+	ocmAgentURL := r.readOCMAgentServiceURLFromConfig(reqLogger, heldCMList, namespace)
+
+	inhibitRuleOverrides := r.parseInhibitRuleOverrides(reqLogger, heldCMList, namespace)
+	routeSettings := r.parseRouteSettings(reqLogger, heldCMList, namespace)
 
 	clusterProxy, err := r.getClusterProxy()
 	if err != nil {
@@ -223,6 +372,7 @@ func (r *SecretReconciler) Reconcile(ctx context.Context, request ctrl.Request)
 	if err != nil {
 		reqLogger.Error(err, "Error reading cluster id.")
 	}
+	start = observePhase(phaseParse, start)
 
 	alertmanagerconfig := createAlertManagerConfig(reqLogger,
 		pagerdutyRoutingKey,
@@ -233,42 +383,58 @@ func (r *SecretReconciler) Reconcile(ctx context.Context, request ctrl.Request)
 		ocmAgentURL,
 		clusterID,
 		clusterProxy,
-		osdNamespaces)
-
-	// write the alertmanager Config
-	writeAlertManagerConfig(r, reqLogger, alertmanagerconfig)
-
-	// Update metrics after all reconcile operations are complete.
-	metrics.UpdateSecretsMetrics(secretList, alertmanagerconfig)
-	metrics.UpdateConfigMapMetrics(cmList)
-	reqLogger.Info("Finished reconcile for secret.")
+		osdNamespaces,
+		alertmanagerConfigOptions{
+			inhibitRuleOverrides: inhibitRuleOverrides,
+			routeSettings:        routeSettings,
+			installPaging:        installPaging,
+			namespaceExclusions:  namespaceExclusions,
+			namespaceOverrides:   namespaceOverrides,
+			goalert:              goalert,
+		})
+
+	// reference the credentials of the secrets already mounted into Alertmanager by file
+	if r.parseCredentialsMode(reqLogger, heldCMList, namespace) == credentialsModeFiles {
+		useCredentialFiles(alertmanagerconfig, r.mountedCredentialSecrets(reqLogger))
+	}
+	observePhase(phaseRender, start)
 
-	// The readiness Result decides whether we should requeue, effectively "polling" the readiness logic.
-	return r.Readiness.Result(), nil
+	return alertmanagerconfig, secretList, cmList, configurePaging
 }
 
-// SetupWithManager sets up the controller with the Manager.
-func (r *SecretReconciler) SetupWithManager(mgr ctrl.Manager) error {
-	client := mgr.GetClient()
-
-	r.Readiness = &readiness.Impl{Client: client}
+// observePhase records the duration of a reconcile phase that began at start, and returns the
+// start of the next one.
+func observePhase(phase string, start time.Time) time.Time {
+	now := time.Now()
+	metrics.ObserveReconcilePhase(phase, now.Sub(start))
+	return now
+}
 
-	return ctrl.NewControllerManagedBy(mgr).
-		For(&corev1.Secret{}).
-		Watches(&source.Kind{Type: &corev1.ConfigMap{}}, &handler.EnqueueRequestForObject{}).
-		Complete(r)
+// countRoutes returns the number of routes below route, at any depth.
+func countRoutes(route *alertmanager.Route) int {
+	if route == nil {
+		return 0
+	}
+	count := 0
+	for _, child := range route.Routes {
+		count += 1 + countRoutes(child)
+	}
+	return count
 }
 
-func createSubroutes(namespaceList []string, receiver receiverType) *alertmanager.Route {
+func createSubroutes(namespaceList []string, namespaceExclusions []string, namespaceOverrides []namespaceOverride, receiver receiverType, settings *alertmanager.RouteSettings, goalert *goalertRouting) *alertmanager.Route {
 
 	var receiverCommon, receiverCritical, receiverError, receiverWarning, receiverDefault string
 
 	switch receiver {
 	case GoAlert:
-		receiverCommon = receiverGoAlertLow
-		receiverCritical = receiverGoAlertHigh
-		receiverError = receiverGoAlertHigh
-		receiverWarning = receiverGoAlertLow
+		if goalert == nil {
+			goalert = defaultGoalertRouting()
+		}
+		receiverCommon = goalert.receiverClasses[receiverClassDefault]
+		receiverCritical = goalert.receiverClasses[receiverClassCritical]
+		receiverError = goalert.receiverClasses[receiverClassError]
+		receiverWarning = goalert.receiverClasses[receiverClassWarning]
 		receiverDefault = receiverNull
 	case Pagerduty:
 		receiverCommon = receiverPagerduty
@@ -280,6 +446,22 @@ func createSubroutes(namespaceList []string, receiver receiverType) *alertmanage
 		return nil
 	}
 
+	receiverClasses := map[string]string{
+		receiverClassCritical: receiverCritical,
+		receiverClassError:    receiverError,
+		receiverClassWarning:  receiverWarning,
+		receiverClassDefault:  receiverCommon,
+	}
+
+	// Alerts from excluded namespaces, or exported from them, are silenced before any other rule, and
+	// never match the namespace routes
+	excluded := excludedNamespaces(namespaceExclusions)
+	excludedExported := excludedExportedNamespaces(namespaceExclusions)
+	notExcluded := []string{
+		alertmanager.NewMatcher("namespace", alertmanager.MatchNotRegexp, excluded),
+		alertmanager.NewMatcher("exported_namespace", alertmanager.MatchNotRegexp, excludedExported),
+	}
+
 	// order matters.
 	// these are sub-routes.  if any matches it will not continue processing.
 	// 1. route anything we consider critical to receiverCritical
@@ -317,20 +499,10 @@ func createSubroutes(namespaceList []string, receiver receiverType) *alertmanage
 		{Receiver: receiverNull, Match: map[string]string{"alertname": "NodeFilesystemSpaceFillingUp"}},
 		// https://issues.redhat.com/browse/OSD-12379
 		{Receiver: receiverNull, Match: map[string]string{"alertname": "NodeFileDescriptorLimit"}},
-		// https://issues.redhat.com/browse/OSD-2611
-		{Receiver: receiverNull, Match: map[string]string{"namespace": "openshift-customer-monitoring"}},
-		// https://issues.redhat.com/browse/OSD-3569
-		{Receiver: receiverNull, Match: map[string]string{"namespace": "openshift-operators"}},
-		// https://issues.redhat.com/browse/OSD-8337
-		{Receiver: receiverNull, Match: map[string]string{"namespace": "openshift-storage"}},
-		// https://issues.redhat.com/browse/OSD-8702
-		{Receiver: receiverNull, Match: map[string]string{"namespace": "openshift-compliance"}},
-		// https://issues.redhat.com/browse/OSD-8349
-		{Receiver: receiverNull, Match: map[string]string{"exported_namespace": "openshift-storage"}},
-		// https://issues.redhat.com/browse/OSD-6505
-		{Receiver: receiverNull, Match: map[string]string{"exported_namespace": "openshift-operators"}},
-		// https://issues.redhat.com/browse/OSD-7653
-		{Receiver: receiverNull, Match: map[string]string{"namespace": "openshift-operators-redhat"}},
+		// Namespaces that are never paged for, such as openshift-customer-monitoring, see defaultNamespaceExclusions
+		// and defaultExportedNamespaceExclusions
+		{Receiver: receiverNull, Matchers: []string{alertmanager.NewMatcher("namespace", alertmanager.MatchRegexp, excluded)}},
+		{Receiver: receiverNull, Matchers: []string{alertmanager.NewMatcher("exported_namespace", alertmanager.MatchRegexp, excludedExported)}},
 		// https://issues.redhat.com/browse/OSD-3629
 		{Receiver: receiverNull, Match: map[string]string{"alertname": "CustomResourceDetected"}},
 		// https://issues.redhat.com/browse/OSD-3629
@@ -348,52 +520,14 @@ func createSubroutes(namespaceList []string, receiver receiverType) *alertmanage
 		{Receiver: receiverNull, MatchRE: map[string]string{"namespace": alertmanager.PDRegexLP}, Match: map[string]string{"alertname": "TargetDown"}},
 		// https://issues.redhat.com/browse/OSD-13306
 		{Receiver: receiverNull, Match: map[string]string{"alertname": "KubeJobFailed"}},
-		// https://issues.redhat.com/browse/OSD-11273 - silence all elasticsearch alerts so we can handle only the ones that have extended logging support
-		// the list of alerts is pulled via
-		// ```
-		//  yq '.spec.groups[].rules[].alert | select( . != null) ' ../managed-cluster-config/resources/prometheusrules/fluentd_openshift-logging_collector.PrometheusRule.yaml | sort -u | awk '{print "{Receiver: receiverNull, Match: map[string]string{\"alertname\": \"" $1 "\", \"namespace\": \"openshift-logging\"}},"}'
-		// # for elasticsearch
-		// yq '.spec.groups[].rules[].alert | select( . != null) ' ../managed-cluster-config/resources/prometheusrules/elasticsearch_openshift-logging_elasticsearch-prometheus-rules.PrometheusRule.yaml | sort -u | awk '{print "{Receiver: receiverNull, Match: map[string]string{\"alertname\": \"" $1 "\", \"namespace\": \"openshift-logging\"}},"}'
-		// ```
 		// pass all of the alerts that are SRE related to PD/GoAlert
 		{Receiver: receiverCommon, MatchRE: map[string]string{"alertname": "^.*SRE$"}, Match: map[string]string{"namespace": "openshift-logging"}},
-		// fluentd alerts
-		{Receiver: receiverNull, Match: map[string]string{"alertname": "FluentDHighErrorRate", "namespace": "openshift-logging"}},
-		{Receiver: receiverNull, Match: map[string]string{"alertname": "FluentDVeryHighErrorRate", "namespace": "openshift-logging"}},
-		{Receiver: receiverNull, Match: map[string]string{"alertname": "FluentdNodeDown", "namespace": "openshift-logging"}},
+	}
+	// the silenced openshift-logging alerts, see builtinNamespaceOverrides, unless a configured override
+	// replaces them; like the rules they replaced, they match whichever Prometheus the alerts come from
+	subroute = append(subroute, namespaceOverrideRoutes(activeBuiltinOverrides(namespaceOverrides), receiverClasses, notExcluded, nil)...)
+	subroute = append(subroute, []*alertmanager.Route{
 		{Receiver: receiverNull, Match: map[string]string{"alertname": "FluentdNodeDown", "prometheus": "openshift-monitoring/k8s"}},
-		{Receiver: receiverNull, Match: map[string]string{"alertname": "FluentdQueueLengthIncreasing", "namespace": "openshift-logging"}}, //https://issues.redhat.com/browse/OSD-8403, https://issues.redhat.com/browse/OSD-8576
-		// elasticsearch alerts
-		{Receiver: receiverNull, Match: map[string]string{"alertname": "AggregatedLoggingSystemCPUHigh", "namespace": "openshift-logging"}},
-		{Receiver: receiverNull, Match: map[string]string{"alertname": "ElasticsearchClusterNotHealthy", "namespace": "openshift-logging"}},   // this has happened last week
-		{Receiver: receiverNull, Match: map[string]string{"alertname": "ElasticsearchDiskSpaceRunningLow", "namespace": "openshift-logging"}}, // this has happened last week
-		{Receiver: receiverNull, Match: map[string]string{"alertname": "ElasticsearchHighFileDescriptorUsage", "namespace": "openshift-logging"}},
-		{Receiver: receiverNull, Match: map[string]string{"alertname": "ElasticsearchJVMHeapUseHigh", "namespace": "openshift-logging"}},
-		{Receiver: receiverNull, Match: map[string]string{"alertname": "ElasticsearchNodeDiskWatermarkReached", "namespace": "openshift-logging"}}, // this has happened last week
-		{Receiver: receiverNull, Match: map[string]string{"alertname": "ElasticsearchOperatorCSVNotSuccessful", "namespace": "openshift-logging"}}, // this has happened last week
-		{Receiver: receiverNull, Match: map[string]string{"alertname": "ElasticsearchProcessCPUHigh", "namespace": "openshift-logging"}},
-		{Receiver: receiverNull, Match: map[string]string{"alertname": "ElasticsearchWriteRequestsRejectionJumps", "namespace": "openshift-logging"}},
-		// END of https://issues.redhat.com/browse/OSD-11273
-		//
-		// https://issues.redhat.com/browse/OSD-17372 - silence all loki/vector alerts for none of them is in the support scope of extended logging support
-		// For detail explanation, please check https://issues.redhat.com/browse/OSD-17371
-		// vector alerts
-		{Receiver: receiverNull, Match: map[string]string{"alertname": "CollectorNodeDown", "namespace": "openshift-logging"}},
-		{Receiver: receiverNull, Match: map[string]string{"alertname": "CollectorHighErrorRate", "namespace": "openshift-logging"}},
-		{Receiver: receiverNull, Match: map[string]string{"alertname": "CollectorVeryHighErrorRate", "namespace": "openshift-logging"}},
-		// loki alerts
-		{Receiver: receiverNull, Match: map[string]string{"alertname": "LokiRequestErrors", "namespace": "openshift-logging"}},
-		{Receiver: receiverNull, Match: map[string]string{"alertname": "LokiStackWriteRequestErrors", "namespace": "openshift-logging"}},
-		{Receiver: receiverNull, Match: map[string]string{"alertname": "LokiStackReadRequestErrors", "namespace": "openshift-logging"}},
-		{Receiver: receiverNull, Match: map[string]string{"alertname": "LokiRequestPanics", "namespace": "openshift-logging"}},
-		{Receiver: receiverNull, Match: map[string]string{"alertname": "LokiRequestLatency", "namespace": "openshift-logging"}},
-		{Receiver: receiverNull, Match: map[string]string{"alertname": "LokiTenantRateLimit", "namespace": "openshift-logging"}},
-		{Receiver: receiverNull, Match: map[string]string{"alertname": "LokiStorageSlowWrite", "namespace": "openshift-logging"}},
-		{Receiver: receiverNull, Match: map[string]string{"alertname": "LokiStorageSlowRead", "namespace": "openshift-logging"}},
-		{Receiver: receiverNull, Match: map[string]string{"alertname": "LokiWritePathHighLoad", "namespace": "openshift-logging"}},
-		{Receiver: receiverNull, Match: map[string]string{"alertname": "LokiReadPathHighLoad", "namespace": "openshift-logging"}},
-		// END of https://issues.redhat.com/browse/OSD-17372
-		//
 		// Suppress the alerts and use HAProxyReloadFailSRE instead (openshift/managed-cluster-config#600)
 		{Receiver: receiverNull, Match: map[string]string{"alertname": "HAProxyReloadFail", "severity": "critical"}},
 		// https://issues.redhat.com/browse/OHSS-2163
@@ -457,7 +591,7 @@ func createSubroutes(namespaceList []string, receiver receiverType) *alertmanage
 		// Route ClusterOperatorDown for insights to null receiver https://issues.redhat.com/browse/OSD-19800
 		// Also needs to be silenced for FedRAMP until its made available in the environment https://issues.redhat.com/browse/OSD-13685
 		{Receiver: receiverNull, Match: map[string]string{"alertname": "ClusterOperatorDown", "name": "insights"}},
-	}
+	}...)
 
 	if !config.IsFedramp() {
 		// Route ClusterOperatorDown for monitoring to null receiver https://issues.redhat.com/browse/OSD-19769
@@ -466,44 +600,63 @@ func createSubroutes(namespaceList []string, receiver receiverType) *alertmanage
 		)
 	}
 
-	for _, namespace := range namespaceList {
+	groupBy := []string{"alertname", "severity"}
+	// the namespace configMap overrides take precedence over the namespace routes, and match the same alerts
+	subroute = append(subroute, namespaceOverrideRoutes(namespaceOverrides, receiverClasses, notExcluded, map[string]string{"exported_namespace": "", "prometheus": "openshift-monitoring/k8s"})...)
+	if receiver == GoAlert && len(namespaceList) > 0 {
+		// the tier routing rules restricted to namespaces come next, for the alerts of any routed namespace
+		routed := namespaceListRegex(namespaceList)
+		byNamespace := append(append([]string{}, groupBy...), "namespace")
+		for _, route := range goalert.namespaced {
+			matchers := append(append([]string{}, notExcluded...), alertmanager.NewMatcher("namespace", alertmanager.MatchRegexp, route.namespace))
+			subroute = append(subroute, &alertmanager.Route{Receiver: route.receiver, GroupByStr: byNamespace, Matchers: matchers, MatchRE: map[string]string{"namespace": routed}, Match: map[string]string{"exported_namespace": "", "prometheus": "openshift-monitoring/k8s", "severity": route.severity}})
+		}
+	}
+	for _, match := range collapseNamespaces(namespaceList) {
+		namespace := match.regex
+		// Alertmanager groups alerts per route. Routes matching several namespaces group by namespace
+		// as well, so each namespace still gets its own notifications.
+		var byExportedNamespace, byNamespace []string
+		if match.collapsed {
+			byExportedNamespace = append(append([]string{}, groupBy...), "exported_namespace")
+			byNamespace = append(append([]string{}, groupBy...), "namespace")
+		}
 		if receiver == Pagerduty {
 			subroute = append(subroute, []*alertmanager.Route{
 				// https://issues.redhat.com/browse/OSD-3086
 				// https://issues.redhat.com/browse/OSD-5872
-				{Receiver: receiverCommon, MatchRE: map[string]string{"exported_namespace": namespace}, Match: map[string]string{"prometheus": "openshift-monitoring/k8s"}},
+				{Receiver: receiverCommon, GroupByStr: byExportedNamespace, Matchers: notExcluded, MatchRE: map[string]string{"exported_namespace": namespace}, Match: map[string]string{"prometheus": "openshift-monitoring/k8s"}},
 				// general: route anything in core namespaces to PD
-				{Receiver: receiverCommon, MatchRE: map[string]string{"namespace": namespace}, Match: map[string]string{"exported_namespace": "", "prometheus": "openshift-monitoring/k8s"}},
+				{Receiver: receiverCommon, GroupByStr: byNamespace, Matchers: notExcluded[:1], MatchRE: map[string]string{"namespace": namespace}, Match: map[string]string{"exported_namespace": "", "prometheus": "openshift-monitoring/k8s"}},
 			}...)
 		}
 		// GoAlert config
 		if receiver == GoAlert {
 			subroute = append(subroute, []*alertmanager.Route{
-				{Receiver: receiverCritical, MatchRE: map[string]string{"namespace": namespace}, Match: map[string]string{"exported_namespace": "", "prometheus": "openshift-monitoring/k8s", "severity": "critical"}},
-				{Receiver: receiverError, MatchRE: map[string]string{"namespace": namespace}, Match: map[string]string{"exported_namespace": "", "prometheus": "openshift-monitoring/k8s", "severity": "error"}},
-				{Receiver: receiverWarning, MatchRE: map[string]string{"namespace": namespace}, Match: map[string]string{"exported_namespace": "", "prometheus": "openshift-monitoring/k8s", "severity": "warning"}},
+				{Receiver: receiverCritical, GroupByStr: byNamespace, Matchers: notExcluded[:1], MatchRE: map[string]string{"namespace": namespace}, Match: map[string]string{"exported_namespace": "", "prometheus": "openshift-monitoring/k8s", "severity": "critical"}},
+				{Receiver: receiverError, GroupByStr: byNamespace, Matchers: notExcluded[:1], MatchRE: map[string]string{"namespace": namespace}, Match: map[string]string{"exported_namespace": "", "prometheus": "openshift-monitoring/k8s", "severity": "error"}},
+				{Receiver: receiverWarning, GroupByStr: byNamespace, Matchers: notExcluded[:1], MatchRE: map[string]string{"namespace": namespace}, Match: map[string]string{"exported_namespace": "", "prometheus": "openshift-monitoring/k8s", "severity": "warning"}},
 			}...)
 		}
 	}
 
+	applyReceiverClassTiming(subroute, receiverClasses, settings)
+
 	return &alertmanager.Route{
-		Receiver: receiverDefault,
-		GroupByStr: []string{
-			"alertname",
-			"severity",
-		},
-		Continue: true,
-		Routes:   subroute,
+		Receiver:   receiverDefault,
+		GroupByStr: groupBy,
+		Continue:   true,
+		Routes:     subroute,
 	}
 }
 
 // createOCMAgentRoute creates an AlertManager Route for OcmAgent in memory.
-func createOCMAgentRoute() *alertmanager.Route {
+func createOCMAgentRoute(settings *alertmanager.RouteSettings) *alertmanager.Route {
 	return &alertmanager.Route{
 		Receiver:       receiverOCMAgent,
 		Continue:       false,
 		Match:          map[string]string{managedNotificationLabel: "true"},
-		RepeatInterval: "10m",
+		RepeatInterval: settings.OCMAgentRepeatInterval,
 	}
 }
 
@@ -515,7 +668,7 @@ func createOCMAgentReceiver(ocmAgentURL string) []*alertmanager.Receiver {
 
 	ocmAgentConfig := &alertmanager.WebhookConfig{
 		NotifierConfig: alertmanager.NotifierConfig{VSendResolved: true},
-		URL:            ocmAgentURL,
+		URL:            alertmanager.SecretURL(ocmAgentURL),
 	}
 
 	return []*alertmanager.Receiver{
@@ -552,7 +705,7 @@ func createPagerdutyConfig(pagerdutyRoutingKey, clusterID string, clusterProxy s
 
 	return &alertmanager.PagerdutyConfig{
 		NotifierConfig: alertmanager.NotifierConfig{VSendResolved: true},
-		RoutingKey:     pagerdutyRoutingKey,
+		RoutingKey:     alertmanager.Secret(pagerdutyRoutingKey),
 		Severity:       `{{ if .CommonLabels.severity }}{{ .CommonLabels.severity | toLower }}{{ else }}critical{{ end }}`,
 		ClientURL:      clientURL,
 		Description:    `{{ .CommonLabels.alertname }} {{ .CommonLabels.severity | toUpper }} ({{ len .Alerts }})`,
@@ -605,7 +758,7 @@ func createGoalertConfig(goalertURL, clusterProxy string) *alertmanager.WebhookC
 
 	return &alertmanager.WebhookConfig{
 		NotifierConfig: alertmanager.NotifierConfig{VSendResolved: true},
-		URL:            goalertURL,
+		URL:            alertmanager.SecretURL(goalertURL),
 		HttpConfig:     createHttpConfig(clusterProxy),
 	}
 }
@@ -626,20 +779,20 @@ func createGoalertReceiver(goalertURL, goalertReceiverName, clusterProxy string)
 }
 
 // creatHeartbeatRoute creates an AlertManager Route for GoAlert Heartbeat in memory.
-func createHeartbeatRoute() *alertmanager.Route {
+func createHeartbeatRoute(settings *alertmanager.RouteSettings) *alertmanager.Route {
 	return &alertmanager.Route{
 		Receiver:       receiverGoAlertHeartbeat,
-		RepeatInterval: "5m",
+		RepeatInterval: settings.HeartbeatRepeatInterval,
 		Match:          map[string]string{"alertname": "Watchdog"},
 		Continue:       true,
 	}
 }
 
 // createWatchdogRoute creates an AlertManager Route for Watchdog (Dead Man's Snitch) in memory.
-func createWatchdogRoute() *alertmanager.Route {
+func createWatchdogRoute(settings *alertmanager.RouteSettings) *alertmanager.Route {
 	return &alertmanager.Route{
 		Receiver:       receiverWatchdog,
-		RepeatInterval: "5m",
+		RepeatInterval: settings.WatchdogRepeatInterval,
 		Match:          map[string]string{"alertname": "Watchdog"},
 		Continue:       true,
 	}
@@ -652,7 +805,7 @@ func createHeartbeatReceivers(heartbeatURL string, clusterProxy string) []*alert
 
 	heartbeatconfig := &alertmanager.WebhookConfig{
 		NotifierConfig: alertmanager.NotifierConfig{VSendResolved: true},
-		URL:            heartbeatURL,
+		URL:            alertmanager.SecretURL(heartbeatURL),
 		HttpConfig:     createHttpConfig(clusterProxy),
 	}
 
@@ -672,7 +825,7 @@ func createWatchdogReceivers(watchdogURL string, clusterProxy string) []*alertma
 
 	snitchconfig := &alertmanager.WebhookConfig{
 		NotifierConfig: alertmanager.NotifierConfig{VSendResolved: true},
-		URL:            watchdogURL,
+		URL:            alertmanager.SecretURL(watchdogURL),
 		HttpConfig:     createHttpConfig(clusterProxy),
 	}
 
@@ -695,40 +848,73 @@ func createHttpConfig(clusterProxy string) alertmanager.HttpConfig {
 	}
 }
 
+// alertmanagerConfigOptions holds the optional inputs of createAlertManagerConfig. The zero value
+// gives the default config.
+type alertmanagerConfigOptions struct {
+	inhibitRuleOverrides []alertmanager.InhibitRuleOverride
+	routeSettings        *alertmanager.RouteSettings
+	// if not nil, PagerDuty and GoAlert only receive the alerts it allows, as they do before the
+	// cluster is ready
+	installPaging       []alertmanager.InstallPagingRule
+	namespaceExclusions []string
+	namespaceOverrides  []namespaceOverride
+	goalert             *goalertRouting
+}
+
 // createAlertManagerConfig creates an AlertManager Config in memory based on the provided input parameters.
-func createAlertManagerConfig(reqLogger logr.Logger, pagerdutyRoutingKey, goalertURLlow, goalertURLhigh, goalertURLheartbeat, watchdogURL, ocmAgentURL, clusterID string, clusterProxy string, namespaceList []string) *alertmanager.Config {
+func createAlertManagerConfig(reqLogger logr.Logger, pagerdutyRoutingKey, goalertURLlow, goalertURLhigh, goalertURLheartbeat, watchdogURL, ocmAgentURL, clusterID string, clusterProxy string, namespaceList []string, options alertmanagerConfigOptions) *alertmanager.Config {
+	routeSettings := options.routeSettings
+	if routeSettings == nil {
+		routeSettings = defaultRouteSettings()
+	}
+
 	routes := []*alertmanager.Route{}
 	receivers := []*alertmanager.Receiver{}
 
 	if watchdogURL != "" {
 		reqLogger.Info("INFO: Configuring a watchdog route and receiver")
-		routes = append(routes, createWatchdogRoute())
+		routes = append(routes, createWatchdogRoute(routeSettings))
 		receivers = append(receivers, createWatchdogReceivers(watchdogURL, clusterProxy)...)
 	}
 
 	if ocmAgentURL != "" {
-		routes = append(routes, createOCMAgentRoute())
+		routes = append(routes, createOCMAgentRoute(routeSettings))
 		receivers = append(receivers, createOCMAgentReceiver(ocmAgentURL)...)
 	}
 
 	if pagerdutyRoutingKey != "" {
-		reqLogger.Info("INFO: Configuring a PagerDuty route and receiver")
-		routes = append(routes, createSubroutes(namespaceList, Pagerduty))
+		if options.installPaging != nil {
+			reqLogger.Info("INFO: Configuring a PagerDuty route restricted to the install paging rules and receiver")
+			routes = append(routes, createInstallPagingRoute(options.installPaging, options.namespaceExclusions, Pagerduty, routeSettings, nil))
+		} else {
+			reqLogger.Info("INFO: Configuring a PagerDuty route and receiver")
+			routes = append(routes, createSubroutes(namespaceList, options.namespaceExclusions, options.namespaceOverrides, Pagerduty, routeSettings, nil))
+		}
 		receivers = append(receivers, createPagerdutyReceivers(pagerdutyRoutingKey, clusterID, clusterProxy)...)
 	}
 
 	if goalertURLlow != "" && goalertURLhigh != "" {
-		reqLogger.Info("INFO: Configuring a GoAlert route and receiver")
-		routes = append(routes, createSubroutes(namespaceList, GoAlert))
+		if options.installPaging != nil {
+			reqLogger.Info("INFO: Configuring a GoAlert route restricted to the install paging rules and receiver")
+			routes = append(routes, createInstallPagingRoute(options.installPaging, options.namespaceExclusions, GoAlert, routeSettings, options.goalert))
+		} else {
+			reqLogger.Info("INFO: Configuring a GoAlert route and receiver")
+			routes = append(routes, createSubroutes(namespaceList, options.namespaceExclusions, options.namespaceOverrides, GoAlert, routeSettings, options.goalert))
+		}
 		receivers = append(receivers, createGoalertReceiver(goalertURLlow, receiverGoAlertLow, clusterProxy)...)
 		receivers = append(receivers, createGoalertReceiver(goalertURLhigh, receiverGoAlertHigh, clusterProxy)...)
+		if options.goalert != nil {
+			for _, tier := range options.goalert.tiers {
+				receivers = append(receivers, createGoalertReceiver(tier.url, goalertTierReceiver(tier.name), clusterProxy)...)
+			}
+		}
 	} else {
 		reqLogger.Info("INFO: Not configuring GoAlert receivers")
 	}
 
 	if goalertURLheartbeat != "" {
 		reqLogger.Info("INFO: Configuring a GoAlert heartbeat route and receiver")
-		routes = append(routes, createHeartbeatRoute())
+		routes = append(routes, createHeartbeatRoute(routeSettings))
 		receivers = append(receivers, createHeartbeatReceivers(goalertURLheartbeat, clusterProxy)...)
 	} else {
 		reqLogger.Info("INFO: Not configuring GoAlert Heartbeat receivers")
@@ -739,187 +925,80 @@ func createAlertManagerConfig(reqLogger logr.Logger, pagerdutyRoutingKey, goaler
 
 	amconfig := &alertmanager.Config{
 		Global: &alertmanager.GlobalConfig{
-			ResolveTimeout: "5m",
+			ResolveTimeout: routeSettings.ResolveTimeout,
 			PagerdutyURL:   pagerdutyURL,
 		},
 		Route: &alertmanager.Route{
-			Receiver: defaultReceiver,
-			GroupByStr: []string{
-				"job",
-			},
-			GroupWait:      "30s",
-			GroupInterval:  "5m",
-			RepeatInterval: "12h",
+			Receiver:       defaultReceiver,
+			GroupByStr:     routeSettings.GroupBy,
+			GroupWait:      routeSettings.GroupWait,
+			GroupInterval:  routeSettings.GroupInterval,
+			RepeatInterval: routeSettings.RepeatInterval,
 			Routes:         routes,
 		},
 		Receivers: receivers,
 		Templates: []string{},
-		// Work request: https://issues.redhat.com/browse/OSD-4623
-		// Reference: https://github.com/openshift/cluster-monitoring-operator/blob/6a02b14773169330d7a31ede73dce5adb1c66bb4/assets/alertmanager/secret.yaml
-		InhibitRules: []*alertmanager.InhibitRule{
-			{
-				// Critical alert shouldn't also alert for warning/info
-				Equal: []string{
-					"namespace",
-					"alertname",
-				},
-				SourceMatch: map[string]string{
-					"severity": "critical",
-				},
-				TargetMatchRE: map[string]string{
-					"severity": "warning|info",
-				},
-			},
-			{
-				// Warning alerts shouldn't also alert for info
-				Equal: []string{
-					"namespace",
-					"alertname",
-				},
-				SourceMatch: map[string]string{
-					"severity": "warning",
-				},
-				TargetMatchRE: map[string]string{
-					"severity": "info",
-				},
-			},
-			{
-				// If a cluster operator is degraded, don't also fire ClusterOperatorDown
-				// The degraded alert is critical, and usually has more details
-				Equal: []string{
-					"namespace",
-					"name",
-				},
-				SourceMatch: map[string]string{
-					"alertname": "ClusterOperatorDegraded",
-					"severity":  "critical",
-				},
-				TargetMatchRE: map[string]string{
-					"alertname": "ClusterOperatorDown",
-				},
-			},
-			{
-				// If a node is not ready, we already know it's Unreachable
-				Equal: []string{
-					"node",
-					"instance",
-				},
-				SourceMatch: map[string]string{
-					"alertname": "KubeNodeNotReady",
-				},
-				TargetMatchRE: map[string]string{
-					"alertname": "KubeNodeUnreachable",
-				},
-			},
-			{
-				// node being Unreachable may also trigger certain pods being unavailable
-				SourceMatch: map[string]string{
-					"alertname": "KubeNodeUnreachable",
-				},
-				TargetMatchRE: map[string]string{
-					"alertname": "SDNPodNotReady|TargetDown",
-				},
-			},
-			{
-				// If a node is NotReady, then we also know that there will be pods that aren't running
-				Equal: []string{
-					"instance",
-				},
-				SourceMatch: map[string]string{
-					"alertname": "KubeNodeNotReady",
-				},
-				TargetMatchRE: map[string]string{
-					"alertname": "KubeDaemonSetRolloutStuck|KubeDaemonSetMisScheduled|KubeDeploymentReplicasMismatch|KubeStatefulSetReplicasMismatch|KubePodNotReady",
-				},
-			},
-			{
-				// If a deployment doesn't have it's replicas mismatch, then we don't need to fire for the pod not being ready
-				Equal: []string{
-					"namespace",
-				},
-				SourceMatch: map[string]string{
-					"alertname": "KubeDeploymentReplicasMismatch",
-				},
-				TargetMatchRE: map[string]string{
-					"alertname": "KubePodNotReady|KubePodCrashLooping",
-				},
-			},
-			{
-				// NB this label obviously won't match and that's both ok and expected. When a label is missing (or empty) on both source and target, the rule will apply (see: docs ).
-				// see: https://www.prometheus.io/docs/alerting/latest/configuration/#inhibit_rule
-
-				// If there wasn't a label here, the tests exploded spectacularly, so I figured a label that would never match is the next best thing.
-				Equal: []string{
-					"dummylabel",
-				},
-				SourceMatch: map[string]string{
-					"alertname": "ElasticsearchOperatorCSVNotSuccessful",
-				},
-				TargetMatchRE: map[string]string{
-					"alertname": "ElasticsearchClusterNotHealthy",
-				},
-			},
-			// https://issues.redhat.com/browse/OSD-13721
-			{
-				Equal: []string{
-					"severity",
-				},
-				SourceMatch: map[string]string{
-					"alertname": "KubeAPIErrorBudgetBurn",
-				},
-				TargetMatchRE: map[string]string{
-					"alertname": "api-ErrorBudgetBurn",
-				},
-			},
-		},
+		// The inhibit rules are defined in inhibitRuleCatalog
+		InhibitRules: createInhibitRules(reqLogger, options.inhibitRuleOverrides),
 	}
 
 	return amconfig
 }
 
-// Retrieves data from all relevant configMaps. Returns a list of namespaces, represented as regular expressions, to monitor
-func (r *SecretReconciler) parseConfigMaps(reqLogger logr.Logger, cmList *corev1.ConfigMapList, cmNamespace string) (namespaceList []string) {
+// Retrieves data from all relevant configMaps. Returns a list of namespaces, represented as regular expressions, to monitor,
+// the excluded namespaces and the routing overrides of the namespace entries
+func (r *SecretReconciler) parseConfigMaps(reqLogger logr.Logger, cmList *corev1.ConfigMapList, cmNamespace string) (namespaceList []string, exclusions []string, overrides []namespaceOverride) {
 	// Retrieve namespaces from their respective configMaps, if the configMaps exist
-	managedNamespaces := r.parseNamespaceConfigMap(reqLogger, cmNameManagedNamespaces, cmNamespace, cmKeyManagedNamespaces, cmList)
-	ocpNamespaces := r.parseNamespaceConfigMap(reqLogger, cmNameOCPNamespaces, cmNamespace, cmKeyOCPNamespaces, cmList)
+	managedNamespaces, managedExclusions, managedOverrides := r.parseNamespaceConfigMap(reqLogger, cmNameManagedNamespaces, cmNamespace, cmKeyManagedNamespaces, cmList)
+	ocpNamespaces, ocpExclusions, ocpOverrides := r.parseNamespaceConfigMap(reqLogger, cmNameOCPNamespaces, cmNamespace, cmKeyOCPNamespaces, cmList)
+	// exclusions and overrides apply whichever way the namespaces are determined
+	exclusions = append(managedExclusions, ocpExclusions...)
+	overrides = append(managedOverrides, ocpOverrides...)
+
+	// Namespaces selected by labels take precedence over the configMaps
+	selector := r.parseNamespaceSelector(reqLogger, cmList, cmNamespace)
+	r.useNamespaceSelector(reqLogger, selector)
+	if selector != nil {
+		selected := r.selectedNamespaces(reqLogger, selector)
+		if len(selected) > 0 {
+			return selected, exclusions, overrides
+		}
+		reqLogger.Info("WARNING: No namespaces match the namespace selector; using the namespace configMaps", "Selector", selector.String())
+	}
 
 	// Default to alerting on all ^openshift-.* namespaces if either list is empty, potentially indicating a problem parsing configMaps
 	if len(managedNamespaces) == 0 ||
 		len(ocpNamespaces) == 0 {
 		reqLogger.Info("DEBUG: Could not retrieve namespaces from one or more configMaps. Using default namespaces", "Default namespaces", defaultNamespaces)
-		return defaultNamespaces
+		return defaultNamespaces, exclusions, overrides
 	}
 
 	namespaceList = append(namespaceList, managedNamespaces...)
 	namespaceList = append(namespaceList, ocpNamespaces...)
 
-	return namespaceList
+	return namespaceList, exclusions, overrides
 }
 
-// Returns the namespaces from a *-namespaces configMap as a list of regular expressions
-func (r *SecretReconciler) parseNamespaceConfigMap(reqLogger logr.Logger, cmName string, cmNamespace string, cmKey string, cmList *corev1.ConfigMapList) (nsList []string) {
+// Returns the namespaces, and the excluded namespaces, from a *-namespaces configMap as lists of regular expressions,
+// and the routing overrides of its namespace entries
+func (r *SecretReconciler) parseNamespaceConfigMap(reqLogger logr.Logger, cmName string, cmNamespace string, cmKey string, cmList *corev1.ConfigMapList) (nsList []string, excludeList []string, overrides []namespaceOverride) {
 	cmExists := cmInList(reqLogger, cmName, cmList)
 	if !cmExists {
-		reqLogger.Info("INFO: ConfigMap does not exist", "ConfigMap", cmNameManagedNamespaces)
-		return []string{}
+		reqLogger.Info("INFO: ConfigMap does not exist", "ConfigMap", cmName)
+		// a deleted configMap has no problems left to report
+		metrics.UpdateNamespaceConfigProblems(cmName, 0)
+		return []string{}, nil, nil
 	}
 
-	// Unmarshal configMap, fail on error or if no namespaces are present in decoded config
-	var namespaceConfig alertmanager.NamespaceConfig
+	// Decode the configMap strictly; any problem rejects the whole configMap rather than routing part of it
 	rawNamespaces := readCMKey(r, reqLogger, cmName, cmNamespace, cmKey)
-	err := yaml.Unmarshal([]byte(rawNamespaces), &namespaceConfig)
-	if err != nil {
-		reqLogger.Info("DEBUG: Unable to unmarshal from configMap", "ConfigMap", fmt.Sprintf("%s/%s", cmNamespace, cmName), "Error", err)
-		return []string{}
-	} else if len(namespaceConfig.Resources.Namespaces) == 0 {
-		reqLogger.Info("DEBUG: No namespaces found in configMap", "ConfigMap", fmt.Sprintf("%s/%s", cmNamespace, cmName))
-		return []string{}
-	}
-
-	for _, ns := range namespaceConfig.Resources.Namespaces {
-		nsList = append(nsList, "^"+ns.Name+"$")
+	parsed, problems := decodeNamespaceConfig(rawNamespaces)
+	if len(problems) > 0 {
+		parsed = r.invalidNamespaceConfig(reqLogger, cmName, cmNamespace, cmList, problems)
+	} else {
+		r.validNamespaceConfig(cmName, parsed)
 	}
-	return nsList
+	return parsed.namespaces, parsed.exclusions, parsed.overrides
 }
 
 // readOCMAgentServiceURLFromConfig returns the OCM Agent service URL from the OCM Agent configmap
@@ -931,7 +1010,7 @@ func (r *SecretReconciler) readOCMAgentServiceURLFromConfig(reqLogger logr.Logge
 	}
 
 	// Unmarshal configMap, fail on error or if no namespaces are present in decoded config
-	serviceURL := readCMKey(r, reqLogger, cmNameOcmAgent, cmNamespace, cmKeyOCMAgent)
+	serviceURL := cmKeyInList(cmList, cmNameOcmAgent, cmKeyOCMAgent)
 	if _, err := url.ParseRequestURI(serviceURL); err != nil {
 		log.Error(err, "Invalid OCM Agent Service URL")
 		return ""
@@ -953,7 +1032,7 @@ func (r *SecretReconciler) parseSecrets(reqLogger logr.Logger, secretList *corev
 		reqLogger.Info("INFO: Pager Duty secret exists")
 		if clusterReady {
 			reqLogger.Info("INFO: Cluster is ready; configuring Pager Duty")
-			pagerdutyRoutingKey = readSecretKey(r, secretNamePD, namespace, secretKeyPD)
+			pagerdutyRoutingKey = secretKeyInList(secretList, secretNamePD, secretKeyPD)
 		} else {
 			reqLogger.Info("INFO: Cluster is not ready; skipping Pager Duty configuration")
 		}
@@ -963,7 +1042,7 @@ func (r *SecretReconciler) parseSecrets(reqLogger logr.Logger, secretList *corev
 
 	if snitchSecretExists {
 		reqLogger.Info("INFO: Dead Man's Snitch secret exists")
-		watchdogURL = readSecretKey(r, secretNameDMS, namespace, secretKeyDMS)
+		watchdogURL = secretKeyInList(secretList, secretNameDMS, secretKeyDMS)
 	} else {
 		reqLogger.Info("INFO: Dead Man's Snitch secret does not exist")
 	}
@@ -973,9 +1052,9 @@ func (r *SecretReconciler) parseSecrets(reqLogger logr.Logger, secretList *corev
 		reqLogger.Info("INFO: Goalert secret exists")
 		if clusterReady {
 			reqLogger.Info("INFO: Cluster is ready; configuring Goalert")
-			goalertURLlow = readSecretKey(r, secretNameGoalert, namespace, secretKeyGoalertLow)
-			goalertURLhigh = readSecretKey(r, secretNameGoalert, namespace, secretKeyGoalertHigh)
-			goalertURLheartbeat = readSecretKey(r, secretNameGoalert, namespace, secretKeyGoalertHeartbeat)
+			goalertURLlow = secretKeyInList(secretList, secretNameGoalert, secretKeyGoalertLow)
+			goalertURLhigh = secretKeyInList(secretList, secretNameGoalert, secretKeyGoalertHigh)
+			goalertURLheartbeat = secretKeyInList(secretList, secretNameGoalert, secretKeyGoalertHeartbeat)
 		} else {
 			reqLogger.Info("INFO: Cluster is not ready; skipping Goalert configuration")
 		}
@@ -1054,32 +1133,62 @@ func readCMKey(r *SecretReconciler, reqLogger logr.Logger, cmName string, cmName
 	return string(configMap.Data[fieldName])
 }
 
-// readSecretKey fetches the data from a Secret, such as a PagerDuty API key.
-func readSecretKey(r *SecretReconciler, secretName string, secretNamespace string, fieldName string) string {
+// secretKeyInList returns the data of a key of a Secret in the list, such as a PagerDuty API key.
+// The list, rather than the API, is read so the Secret may be one held through the removal grace period.
+func secretKeyInList(list *corev1.SecretList, secretName string, fieldName string) string {
+	for _, secret := range list.Items {
+		if secret.Name == secretName {
+			return string(secret.Data[fieldName])
+		}
+	}
+	return ""
+}
 
-	secret := &corev1.Secret{}
+// cmKeyInList returns the data of a key of a ConfigMap in the list.
+func cmKeyInList(list *corev1.ConfigMapList, cmName string, fieldName string) string {
+	for _, cm := range list.Items {
+		if cm.Name == cmName {
+			return cm.Data[fieldName]
+		}
+	}
+	return ""
+}
 
-	// Define a new objectKey for fetching the secret key.
-	objectKey := client.ObjectKey{
-		Namespace: secretNamespace,
-		Name:      secretName,
+// applyAlertManagerConfig writes the config into alertmanager-main and records it in the history.
+// The config written is the revision of a rollback if there is one, else the desired config, unless
+// Alertmanager failed to load it and it was reverted. The written config is returned.
+func (r *SecretReconciler) applyAlertManagerConfig(reqLogger logr.Logger, amconfig *alertmanager.Config, cmList *corev1.ConfigMapList, trigger string) ([]byte, error) {
+	amconfigbyte, rollback, err := r.selectConfigToWrite(reqLogger, amconfig, cmList)
+	if err != nil {
+		reqLogger.Error(err, "ERROR: failed to marshal Alertmanager config")
+		metrics.RecordConfigWrite(metrics.ConfigWriteError)
+		return nil, err
 	}
 
-	// Fetch the key from the secret object.
-	// TODO: Check error from Get(). Right now secret.Data[fieldname] will panic.
-	_ = r.Client.Get(context.TODO(), objectKey, secret)
-	return string(secret.Data[fieldName])
+	if err := writeAlertManagerSecret(r, reqLogger, amconfigbyte); err != nil {
+		return nil, err
+	}
+	if !rollback {
+		// the history is best effort, it must not stop the config from being applied
+		if err := r.recordHistory(reqLogger, amconfigbyte, trigger); err != nil {
+			reqLogger.Error(err, "ERROR: Could not record the config history", "Secret", secretNameHistory)
+		}
+	}
+	return amconfigbyte, nil
 }
 
-// writeAlertManagerConfig writes the updated alertmanager config to the `alertmanager-main` secret in namespace `openshift-monitoring`.
-func writeAlertManagerConfig(r *SecretReconciler, reqLogger logr.Logger, amconfig *alertmanager.Config) {
-	amconfigbyte, marshalerr := yaml.Marshal(amconfig)
-	if marshalerr != nil {
-		reqLogger.Error(marshalerr, "ERROR: failed to marshal Alertmanager config")
+// selectConfigToWrite returns the config a reconcile writes into alertmanager-main: the revision of a
+// rollback if there is one, in which case rollback is true, else the config from configToWrite.
+func (r *SecretReconciler) selectConfigToWrite(reqLogger logr.Logger, amconfig *alertmanager.Config, cmList *corev1.ConfigMapList) (amconfigbyte []byte, rollback bool, err error) {
+	if amconfigbyte = r.rollbackConfig(reqLogger, cmList); amconfigbyte != nil {
+		return amconfigbyte, true, nil
 	}
-	// This is commented out because it prints secrets, but it might be useful for debugging when running locally.
-	//reqLogger.Info("DEBUG: Marshalled Alertmanager config:", string(amconfigbyte))
+	amconfigbyte, err = r.configToWrite(reqLogger, amconfig)
+	return amconfigbyte, false, err
+}
 
+// writeAlertManagerSecret writes an already marshalled config into the alertmanager-main secret.
+func writeAlertManagerSecret(r *SecretReconciler, reqLogger logr.Logger, amconfigbyte []byte) error {
 	secret := &corev1.Secret{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      secretNameAlertmanager,
@@ -1091,18 +1200,23 @@ func writeAlertManagerConfig(r *SecretReconciler, reqLogger logr.Logger, amconfi
 	}
 
 	// Write the alertmanager config into the alertmanager secret.
+	result := metrics.ConfigWriteUpdated
 	err := r.Client.Update(context.TODO(), secret)
 	if err != nil {
 		if errors.IsNotFound(err) {
 			// couldn't update because it didn't exist.
 			// create it instead.
+			result = metrics.ConfigWriteCreated
 			err = r.Client.Create(context.TODO(), secret)
 		}
 	}
 
 	if err != nil {
 		reqLogger.Error(err, "ERROR: Could not write secret alertmanger-main", "namespace", secret.Namespace)
-		return
+		metrics.RecordConfigWrite(metrics.ConfigWriteError)
+		return err
 	}
-	reqLogger.Info("INFO: Secret alertmanager-main successfully updated")
+	metrics.RecordConfigWrite(result)
+	reqLogger.Info("INFO: Secret alertmanager-main successfully " + result)
+	return nil
 }
@@ -14,6 +14,7 @@ import (
 	monitoringv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
 	"go.uber.org/mock/gomock"
 	yaml "gopkg.in/yaml.v2"
+	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	k8sruntime "k8s.io/apimachinery/pkg/runtime"
@@ -215,27 +216,27 @@ func verifyPagerdutyReceivers(t *testing.T, key string, proxy string, receivers
 		case receiverMakeItWarning:
 			hasMakeItWarning = true
 			assertEquals(t, true, receiver.PagerdutyConfigs[0].NotifierConfig.VSendResolved, "VSendResolved")
-			assertEquals(t, key, receiver.PagerdutyConfigs[0].RoutingKey, "RoutingKey")
+			assertEquals(t, alertmanager.Secret(key), receiver.PagerdutyConfigs[0].RoutingKey, "RoutingKey")
 			assertEquals(t, "warning", receiver.PagerdutyConfigs[0].Severity, "Severity")
 			assertEquals(t, proxy, receiver.PagerdutyConfigs[0].HttpConfig.ProxyURL, "Proxy")
 		case receiverPagerduty:
 			hasPagerduty = true
 			assertEquals(t, true, receiver.PagerdutyConfigs[0].NotifierConfig.VSendResolved, "VSendResolved")
-			assertEquals(t, key, receiver.PagerdutyConfigs[0].RoutingKey, "RoutingKey")
+			assertEquals(t, alertmanager.Secret(key), receiver.PagerdutyConfigs[0].RoutingKey, "RoutingKey")
 			assertTrue(t, receiver.PagerdutyConfigs[0].Severity != "", "Non empty Severity")
 			assertNotEquals(t, "warning", receiver.PagerdutyConfigs[0].Severity, "Severity")
 			assertEquals(t, proxy, receiver.PagerdutyConfigs[0].HttpConfig.ProxyURL, "Proxy")
 		case receiverMakeItError:
 			hasMakeItError = true
 			assertEquals(t, true, receiver.PagerdutyConfigs[0].NotifierConfig.VSendResolved, "VSendResolved")
-			assertEquals(t, key, receiver.PagerdutyConfigs[0].RoutingKey, "RoutingKey")
+			assertEquals(t, alertmanager.Secret(key), receiver.PagerdutyConfigs[0].RoutingKey, "RoutingKey")
 			assertEquals(t, "error", receiver.PagerdutyConfigs[0].Severity, "Severity")
 			assertNotEquals(t, "warning", receiver.PagerdutyConfigs[0].Severity, "Severity")
 			assertEquals(t, proxy, receiver.PagerdutyConfigs[0].HttpConfig.ProxyURL, "Proxy")
 		case receiverMakeItCritical:
 			hasMakeItCritical = true
 			assertEquals(t, true, receiver.PagerdutyConfigs[0].NotifierConfig.VSendResolved, "VSendResolved")
-			assertEquals(t, key, receiver.PagerdutyConfigs[0].RoutingKey, "RoutingKey")
+			assertEquals(t, alertmanager.Secret(key), receiver.PagerdutyConfigs[0].RoutingKey, "RoutingKey")
 			assertEquals(t, "critical", receiver.PagerdutyConfigs[0].Severity, "Severity")
 			assertEquals(t, proxy, receiver.PagerdutyConfigs[0].HttpConfig.ProxyURL, "Proxy")
 		}
@@ -258,7 +259,7 @@ func verifyGoalertLowReceivers(t *testing.T, url string, proxy string, receivers
 		if receiver.Name == receiverGoAlertLow {
 			hasGoalertLow = true
 			assertEquals(t, true, receiver.WebhookConfigs[0].NotifierConfig.VSendResolved, "VSendResolved")
-			assertEquals(t, url, receiver.WebhookConfigs[0].URL, "URL")
+			assertEquals(t, alertmanager.SecretURL(url), receiver.WebhookConfigs[0].URL, "URL")
 			assertEquals(t, proxy, receiver.WebhookConfigs[0].HttpConfig.ProxyURL, "Proxy")
 		}
 	}
@@ -276,7 +277,7 @@ func verifyGoalertHighReceivers(t *testing.T, url string, proxy string, receiver
 		if receiver.Name == receiverGoAlertHigh {
 			hasGoalertHigh = true
 			assertEquals(t, true, receiver.WebhookConfigs[0].NotifierConfig.VSendResolved, "VSendResolved")
-			assertEquals(t, url, receiver.WebhookConfigs[0].URL, "URL")
+			assertEquals(t, alertmanager.SecretURL(url), receiver.WebhookConfigs[0].URL, "URL")
 			assertEquals(t, proxy, receiver.WebhookConfigs[0].HttpConfig.ProxyURL, "Proxy")
 		}
 	}
@@ -302,7 +303,7 @@ func verifyHeartbeatReceiver(t *testing.T, url string, proxy string, receivers [
 		if receiver.Name == receiverGoAlertHeartbeat {
 			hasWatchdog = true
 			assertTrue(t, receiver.WebhookConfigs[0].VSendResolved, "VSendResolved")
-			assertEquals(t, url, receiver.WebhookConfigs[0].URL, "URL")
+			assertEquals(t, alertmanager.SecretURL(url), receiver.WebhookConfigs[0].URL, "URL")
 			assertEquals(t, proxy, receiver.WebhookConfigs[0].HttpConfig.ProxyURL, "Proxy")
 		}
 	}
@@ -328,7 +329,7 @@ func verifyWatchdogReceiver(t *testing.T, url string, proxy string, receivers []
 		if receiver.Name == receiverWatchdog {
 			hasWatchdog = true
 			assertTrue(t, receiver.WebhookConfigs[0].VSendResolved, "VSendResolved")
-			assertEquals(t, url, receiver.WebhookConfigs[0].URL, "URL")
+			assertEquals(t, alertmanager.SecretURL(url), receiver.WebhookConfigs[0].URL, "URL")
 			assertEquals(t, proxy, receiver.WebhookConfigs[0].HttpConfig.ProxyURL, "Proxy")
 		}
 	}
@@ -354,7 +355,7 @@ func verifyOCMAgentReceiver(t *testing.T, url string, receivers []*alertmanager.
 		if receiver.Name == receiverOCMAgent {
 			hasOCMAgent = true
 			assertTrue(t, receiver.WebhookConfigs[0].VSendResolved, "VSendResolved")
-			assertEquals(t, url, receiver.WebhookConfigs[0].URL, "URL")
+			assertEquals(t, alertmanager.SecretURL(url), receiver.WebhookConfigs[0].URL, "URL")
 		}
 	}
 
@@ -363,18 +364,14 @@ func verifyOCMAgentReceiver(t *testing.T, url string, receivers []*alertmanager.
 
 func verifyInhibitRules(t *testing.T, inhibitRules []*alertmanager.InhibitRule) {
 	tests := []struct {
-		SourceMatch   map[string]string
-		TargetMatchRE map[string]string
-		Equal         []string
-		Expected      bool
+		SourceMatchers []string
+		TargetMatchers []string
+		Equal          []string
+		Expected       bool
 	}{
 		{
-			SourceMatch: map[string]string{
-				"alertname": "NotPresent",
-			},
-			TargetMatchRE: map[string]string{
-				"alertname": "DoesNotExist",
-			},
+			SourceMatchers: []string{`alertname="NotPresent"`},
+			TargetMatchers: []string{`alertname=~"DoesNotExist"`},
 			Equal: []string{
 				"namespace",
 				"missing",
@@ -386,110 +383,67 @@ func verifyInhibitRules(t *testing.T, inhibitRules []*alertmanager.InhibitRule)
 				"namespace",
 				"alertname",
 			},
-			SourceMatch: map[string]string{
-				"severity": "critical",
-			},
-			TargetMatchRE: map[string]string{
-				"severity": "warning|info",
-			},
-			Expected: true,
+			SourceMatchers: []string{`severity="critical"`},
+			TargetMatchers: []string{`severity=~"warning|info"`},
+			Expected:       true,
 		},
 		{
 			Equal: []string{
 				"namespace",
 				"alertname",
 			},
-			SourceMatch: map[string]string{
-				"severity": "warning",
-			},
-			TargetMatchRE: map[string]string{
-				"severity": "info",
-			},
-			Expected: true,
+			SourceMatchers: []string{`severity="warning"`},
+			TargetMatchers: []string{`severity="info"`},
+			Expected:       true,
 		},
 		{
 			Equal: []string{
 				"namespace",
 				"name",
 			},
-			SourceMatch: map[string]string{
-				"alertname": "ClusterOperatorDegraded",
-				"severity":  "critical",
-			},
-			TargetMatchRE: map[string]string{
-				"alertname": "ClusterOperatorDown",
-			},
-			Expected: true,
+			SourceMatchers: []string{`alertname="ClusterOperatorDegraded"`, `severity="critical"`},
+			TargetMatchers: []string{`alertname="ClusterOperatorDown"`},
+			Expected:       true,
 		},
 		{
 			Equal: []string{
 				"node",
 				"instance",
 			},
-			SourceMatch: map[string]string{
-				"alertname": "KubeNodeNotReady",
-			},
-			TargetMatchRE: map[string]string{
-				"alertname": "KubeNodeUnreachable",
-			},
-			Expected: true,
+			SourceMatchers: []string{`alertname="KubeNodeNotReady"`},
+			TargetMatchers: []string{`alertname="KubeNodeUnreachable"`},
+			Expected:       true,
 		},
 		{
-			SourceMatch: map[string]string{
-				"alertname": "KubeNodeUnreachable",
-			},
-			TargetMatchRE: map[string]string{
-				"alertname": "SDNPodNotReady|TargetDown",
-			},
-			Expected: true,
+			SourceMatchers: []string{`alertname="KubeNodeUnreachable"`},
+			TargetMatchers: []string{`alertname=~"SDNPodNotReady|TargetDown"`},
+			Expected:       true,
 		},
 		{
 			Equal: []string{
 				"instance",
 			},
-			SourceMatch: map[string]string{
-				"alertname": "KubeNodeNotReady",
-			},
-			TargetMatchRE: map[string]string{
-				"alertname": "KubeDaemonSetRolloutStuck|KubeDaemonSetMisScheduled|KubeDeploymentReplicasMismatch|KubeStatefulSetReplicasMismatch|KubePodNotReady",
-			},
-			Expected: true,
+			SourceMatchers: []string{`alertname="KubeNodeNotReady"`},
+			TargetMatchers: []string{`alertname=~"KubeDaemonSetRolloutStuck|KubeDaemonSetMisScheduled|KubeDeploymentReplicasMismatch|KubeStatefulSetReplicasMismatch|KubePodNotReady"`},
+			Expected:       true,
 		},
 		{
 			Equal: []string{
 				"namespace",
 			},
-			SourceMatch: map[string]string{
-				"alertname": "KubeDeploymentReplicasMismatch",
-			},
-			TargetMatchRE: map[string]string{
-				"alertname": "KubePodNotReady|KubePodCrashLooping",
-			},
-			Expected: true,
+			SourceMatchers: []string{`alertname="KubeDeploymentReplicasMismatch"`},
+			TargetMatchers: []string{`alertname=~"KubePodNotReady|KubePodCrashLooping"`},
+			Expected:       true,
 		},
 		{
-			SourceMatch: map[string]string{
-				"alertname": "ElasticsearchOperatorCSVNotSuccessful",
-			},
-			TargetMatchRE: map[string]string{
-				"alertname": "ElasticsearchClusterNotHealthy",
-			},
-			// NB this label obviously won't match and that's both ok and expected. When a label is missing (or empty) on both source and target, the rule will apply (see: docs ).
-			// see: https://www.prometheus.io/docs/alerting/latest/configuration/#inhibit_rule
-
-			// If there wasn't a label here, the tests exploded spectacularly, so I figured a label that would never match is the next best thing.
-			Equal: []string{
-				"dummylabel",
-			},
-			Expected: true,
+			// With matchers the rule no longer needs a never-matching label in Equal.
+			SourceMatchers: []string{`alertname="ElasticsearchOperatorCSVNotSuccessful"`},
+			TargetMatchers: []string{`alertname="ElasticsearchClusterNotHealthy"`},
+			Expected:       true,
 		},
 		{
-			SourceMatch: map[string]string{
-				"alertname": "KubeAPIErrorBudgetBurn",
-			},
-			TargetMatchRE: map[string]string{
-				"alertname": "api-ErrorBudgetBurn",
-			},
+			SourceMatchers: []string{`alertname="KubeAPIErrorBudgetBurn"`},
+			TargetMatchers: []string{`alertname="api-ErrorBudgetBurn"`},
 			Equal: []string{
 				"severity",
 			},
@@ -505,7 +459,7 @@ func verifyInhibitRules(t *testing.T, inhibitRules []*alertmanager.InhibitRule)
 		present := false
 
 		for i, inhibitRule := range inhibitRules {
-			if reflect.DeepEqual(inhibitRule.SourceMatch, test.SourceMatch) && reflect.DeepEqual(inhibitRule.TargetMatchRE, test.TargetMatchRE) && reflect.DeepEqual(inhibitRule.Equal, test.Equal) {
+			if reflect.DeepEqual(inhibitRule.SourceMatchers, test.SourceMatchers) && reflect.DeepEqual(inhibitRule.TargetMatchers, test.TargetMatchers) && reflect.DeepEqual(inhibitRule.Equal, test.Equal) {
 				present = true
 				presentInhibitionRules = append(presentInhibitionRules, i)
 			}
@@ -527,6 +481,14 @@ func verifyInhibitRules(t *testing.T, inhibitRules []*alertmanager.InhibitRule)
 		rule, _ := json.Marshal(inhibitRules[i])
 		assertTrue(t, inhibitionRuleExpected, fmt.Sprintf("Unexpected InhibitRule: %s", rule))
 	}
+
+	// every rendered matcher must be valid Alertmanager syntax
+	for _, inhibitRule := range inhibitRules {
+		for _, m := range append(append([]string{}, inhibitRule.SourceMatchers...), inhibitRule.TargetMatchers...) {
+			_, _, _, err := alertmanager.ParseMatcher(m)
+			assertEquals(t, nil, err, "Invalid matcher")
+		}
+	}
 }
 
 func Test_cmInList(t *testing.T) {
@@ -839,7 +801,7 @@ func Test_parseConfigMaps(t *testing.T) {
 		}
 
 		request := createReconcileRequest(reconciler, cmNameManagedNamespaces)
-		namespaceList := reconciler.parseConfigMaps(reqLogger, cmList, request.Namespace)
+		namespaceList, _, _ := reconciler.parseConfigMaps(reqLogger, cmList, request.Namespace)
 
 		assertEquals(t, tt.expectedNamespaces, namespaceList, "Expected namespace lists to match")
 	}
@@ -920,14 +882,14 @@ func Test_readOCMAgentServiceURLFromConfig(t *testing.T) {
 
 func Test_createPagerdutyRoute(t *testing.T) {
 	// test the structure of the Route is sane
-	route := createSubroutes(defaultNamespaces, Pagerduty)
+	route := createSubroutes(defaultNamespaces, nil, nil, Pagerduty, defaultRouteSettings(), nil)
 
 	verifyPagerdutyRoute(t, route, defaultNamespaces)
 }
 
 func Test_createGoalertSubroute(t *testing.T) {
 	// test the structure of the Route is sane
-	route := createSubroutes(defaultNamespaces, GoAlert)
+	route := createSubroutes(defaultNamespaces, nil, nil, GoAlert, defaultRouteSettings(), nil)
 
 	verifyGoalertRoute(t, route, defaultNamespaces)
 }
@@ -959,7 +921,7 @@ func Test_createGoalertReceivers_WithURL(t *testing.T) {
 
 func Test_createWatchdogRoute(t *testing.T) {
 	// test the structure of the Route is sane
-	route := createWatchdogRoute()
+	route := createWatchdogRoute(defaultRouteSettings())
 
 	verifyWatchdogRoute(t, route)
 }
@@ -978,7 +940,7 @@ func Test_createWatchdogReceivers_WithKey(t *testing.T) {
 
 func Test_createHeartbeatRoute(t *testing.T) {
 	// test the structure of the Route is sane
-	route := createHeartbeatRoute()
+	route := createHeartbeatRoute(defaultRouteSettings())
 	verifyHeartbeatRoute(t, route)
 }
 
@@ -1002,7 +964,7 @@ func Test_createAlertManagerConfig_WithoutKey_WithoutURL(t *testing.T) {
 	gaLowURL := ""
 	gaHeartURL := ""
 
-	config := createAlertManagerConfig(reqLogger, pdKey, gaLowURL, gaHighURL, gaHeartURL, wdURL, oaURL, exampleClusterId, exampleProxy, exampleManagedNamespaces)
+	config := createAlertManagerConfig(reqLogger, pdKey, gaLowURL, gaHighURL, gaHeartURL, wdURL, oaURL, exampleClusterId, exampleProxy, exampleManagedNamespaces, alertmanagerConfigOptions{})
 
 	// verify static things
 	assertEquals(t, "5m", config.Global.ResolveTimeout, "Global.ResolveTimeout")
@@ -1027,7 +989,7 @@ func Test_createAlertManagerConfig_WithKey_WithoutURL(t *testing.T) {
 	gaLowURL := ""
 	gaHeartURL := ""
 
-	config := createAlertManagerConfig(reqLogger, pdKey, gaLowURL, gaHighURL, gaHeartURL, wdURL, oaURL, exampleClusterId, exampleProxy, exampleManagedNamespaces)
+	config := createAlertManagerConfig(reqLogger, pdKey, gaLowURL, gaHighURL, gaHeartURL, wdURL, oaURL, exampleClusterId, exampleProxy, exampleManagedNamespaces, alertmanagerConfigOptions{})
 
 	// verify static things
 	assertEquals(t, "5m", config.Global.ResolveTimeout, "Global.ResolveTimeout")
@@ -1055,7 +1017,7 @@ func Test_createAlertManagerConfig_WithKey_WithWDURL_WithOAURL(t *testing.T) {
 	gaLowURL := "https://dummy-galow-url"
 	gaHeartURL := "https://dummy-gaheartbeat-url"
 
-	config := createAlertManagerConfig(reqLogger, pdKey, gaLowURL, gaHighURL, gaHeartURL, wdURL, oaURL, exampleClusterId, exampleProxy, exampleManagedNamespaces)
+	config := createAlertManagerConfig(reqLogger, pdKey, gaLowURL, gaHighURL, gaHeartURL, wdURL, oaURL, exampleClusterId, exampleProxy, exampleManagedNamespaces, alertmanagerConfigOptions{})
 
 	// verify static things
 	assertEquals(t, "5m", config.Global.ResolveTimeout, "Global.ResolveTimeout")
@@ -1105,7 +1067,7 @@ func Test_createAlertManagerConfig_WithoutKey_WithoutOA_WithWDURL(t *testing.T)
 		oaURL,
 		exampleClusterId,
 		exampleProxy,
-		exampleManagedNamespaces)
+		exampleManagedNamespaces, alertmanagerConfigOptions{})
 
 	// verify static things
 	assertEquals(t, "5m", config.Global.ResolveTimeout, "Global.ResolveTimeout")
@@ -1182,6 +1144,7 @@ func createReconciler(t *testing.T, ready readiness.Interface) *SecretReconciler
 	utilruntime.Must(configv1.AddToScheme(fakeScheme))
 	utilruntime.Must(corev1.AddToScheme(fakeScheme))
 	utilruntime.Must(monitoringv1.AddToScheme(fakeScheme))
+	utilruntime.Must(appsv1.AddToScheme(fakeScheme))
 
 	// if err := configv1.AddToScheme(scheme); err != nil {
 	// 	t.Fatalf("Unable to add route scheme: (%v)", err)
@@ -1235,7 +1198,7 @@ func Test_createPagerdutySecret_Create(t *testing.T) {
 		oaURL,
 		exampleClusterId,
 		exampleProxy,
-		defaultNamespaces)
+		defaultNamespaces, alertmanagerConfigOptions{})
 
 	verifyInhibitRules(t, configExpected.InhibitRules)
 
@@ -1277,7 +1240,7 @@ func Test_createPagerdutySecret_Update(t *testing.T) {
 	var ret reconcile.Result
 	var err error
 
-	configExpected := createAlertManagerConfig(reqLogger, pdKey, gaLowURL, gaHighURL, gaHeartURL, wdURL, oaURL, exampleClusterId, exampleProxy, defaultNamespaces)
+	configExpected := createAlertManagerConfig(reqLogger, pdKey, gaLowURL, gaHighURL, gaHeartURL, wdURL, oaURL, exampleClusterId, exampleProxy, defaultNamespaces, alertmanagerConfigOptions{})
 
 	verifyInhibitRules(t, configExpected.InhibitRules)
 
@@ -1326,7 +1289,7 @@ func Test_createGoalertSecret_Create(t *testing.T) {
 	gaLowURL := "https://dummy-galow-url"
 	gaHeartURL := "https://dummy-gaheartbeat-url"
 
-	configExpected := createAlertManagerConfig(reqLogger, pdKey, gaLowURL, gaHighURL, gaHeartURL, wdURL, oaURL, exampleClusterId, exampleProxy, defaultNamespaces)
+	configExpected := createAlertManagerConfig(reqLogger, pdKey, gaLowURL, gaHighURL, gaHeartURL, wdURL, oaURL, exampleClusterId, exampleProxy, defaultNamespaces, alertmanagerConfigOptions{})
 
 	verifyInhibitRules(t, configExpected.InhibitRules)
 
@@ -1374,7 +1337,7 @@ func Test_createGoalertSecret_Update(t *testing.T) {
 	var ret reconcile.Result
 	var err error
 
-	configExpected := createAlertManagerConfig(reqLogger, pdKey, gaLowURL, gaHighURL, gaHeartURL, wdURL, oaURL, exampleClusterId, exampleProxy, defaultNamespaces)
+	configExpected := createAlertManagerConfig(reqLogger, pdKey, gaLowURL, gaHighURL, gaHeartURL, wdURL, oaURL, exampleClusterId, exampleProxy, defaultNamespaces, alertmanagerConfigOptions{})
 
 	verifyInhibitRules(t, configExpected.InhibitRules)
 
@@ -1581,7 +1544,8 @@ func Test_SecretReconciler(t *testing.T) {
 
 		// Create the secrets for this specific test.
 		if tt.amExists {
-			writeAlertManagerConfig(reconciler, reqLogger, createAlertManagerConfig(reqLogger, pdKey, gaLowURL, gaHighURL, gaHeartURL, wdURL, oaURL, "", "", defaultNamespaces))
+			_, err := reconciler.applyAlertManagerConfig(reqLogger, createAlertManagerConfig(reqLogger, pdKey, gaLowURL, gaHighURL, gaHeartURL, wdURL, oaURL, "", "", defaultNamespaces, alertmanagerConfigOptions{}), &corev1.ConfigMapList{}, "test")
+			assertEquals(t, nil, err, "Unexpected err writing alertmanager-main")
 		}
 		if tt.dmsExists {
 			wdURL = "https://hjklasdf09876"
@@ -1612,7 +1576,7 @@ func Test_SecretReconciler(t *testing.T) {
 			createConfigMap(reconciler, cmNameOcmAgent, cmKeyOCMAgent, oaURL)
 		}
 
-		configExpected := createAlertManagerConfig(reqLogger, pdKey, gaLowURL, gaHighURL, gaHeartURL, wdURL, oaURL, exampleClusterId, exampleProxy, defaultNamespaces)
+		configExpected := createAlertManagerConfig(reqLogger, pdKey, gaLowURL, gaHighURL, gaHeartURL, wdURL, oaURL, exampleClusterId, exampleProxy, defaultNamespaces, alertmanagerConfigOptions{})
 
 		verifyInhibitRules(t, configExpected.InhibitRules)
 
@@ -1687,7 +1651,8 @@ func Test_SecretReconciler_Readiness(t *testing.T) {
 		createClusterVersion(reconciler)
 		createClusterProxy(reconciler)
 
-		writeAlertManagerConfig(reconciler, reqLogger, createAlertManagerConfig(reqLogger, "", "", "", "", "", "", "", "", defaultNamespaces))
+		_, err := reconciler.applyAlertManagerConfig(reqLogger, createAlertManagerConfig(reqLogger, "", "", "", "", "", "", "", "", defaultNamespaces, alertmanagerConfigOptions{}), &corev1.ConfigMapList{}, "test")
+		assertEquals(t, nil, err, "Unexpected err writing alertmanager-main")
 
 		pdKey := "asdfjkl123"
 		dmsURL := "https://hjklasdf09876"
@@ -1728,7 +1693,7 @@ func Test_SecretReconciler_Readiness(t *testing.T) {
 			oaURL = ""
 		}
 
-		configExpected := createAlertManagerConfig(reqLogger, pdKey, gaLowURL, gaHighURL, gaHeartURL, dmsURL, oaURL, exampleClusterId, exampleProxy, defaultNamespaces)
+		configExpected := createAlertManagerConfig(reqLogger, pdKey, gaLowURL, gaHighURL, gaHeartURL, dmsURL, oaURL, exampleClusterId, exampleProxy, defaultNamespaces, alertmanagerConfigOptions{})
 
 		verifyInhibitRules(t, configExpected.InhibitRules)
 
@@ -1744,3 +1709,16 @@ func Test_SecretReconciler_Readiness(t *testing.T) {
 		assertEquals(t, configExpected.String(), configActual.String(), tt.name)
 	}
 }
+
+func Test_countRoutes(t *testing.T) {
+	assertEquals(t, 0, countRoutes(nil), "nil route")
+
+	config := createAlertManagerConfig(reqLogger, "", "", "", "", "https://hjklasdf09876", "", exampleClusterId, exampleProxy, defaultNamespaces, alertmanagerConfigOptions{})
+	assertEquals(t, 1, countRoutes(config.Route), "Watchdog only")
+
+	nested := &alertmanager.Route{Routes: []*alertmanager.Route{
+		{Routes: []*alertmanager.Route{{}, {}}},
+		{},
+	}}
+	assertEquals(t, 4, countRoutes(nested), "Nested routes")
+}
@@ -0,0 +1,160 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-logr/logr"
+	"gopkg.in/yaml.v2"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/openshift/configure-alertmanager-operator/config"
+	"github.com/openshift/configure-alertmanager-operator/pkg/metrics"
+	alertmanager "github.com/openshift/configure-alertmanager-operator/pkg/types"
+	"github.com/openshift/configure-alertmanager-operator/pkg/verify"
+)
+
+const (
+	// how soon to check again when Alertmanager hasn't loaded the written config yet
+	configVerifyRequeueDelay = 30 * time.Second
+
+	// how soon to check again when Alertmanager couldn't be queried
+	configVerifyErrorRequeueDelay = 5 * time.Minute
+
+	// how long Alertmanager may fail to load the written config before it is reverted
+	configRevertAfter = 5 * time.Minute
+
+	// reason of the Event recorded on alertmanager-main when the config is reverted
+	eventReasonConfigReverted = "ConfigReverted"
+)
+
+// configVerifyState is what the controller remembers between reconciles to detect drift.
+type configVerifyState struct {
+	// lastGood is the last written config that Alertmanager loaded.
+	lastGood []byte
+	// mismatchSince is when Alertmanager was first seen running a different config than the one written.
+	mismatchSince time.Time
+	// failedFingerprint is the fingerprint of a config that was reverted. While the desired config
+	// still has this fingerprint, lastGood is written instead.
+	failedFingerprint string
+}
+
+// configToWrite returns the config to write into alertmanager-main: the desired config, or the last good
+// config if the desired config was already reverted because Alertmanager couldn't load it.
+func (r *SecretReconciler) configToWrite(reqLogger logr.Logger, amconfig *alertmanager.Config) ([]byte, error) {
+	desired, err := yaml.Marshal(amconfig)
+	if err != nil {
+		return nil, err
+	}
+	// The whole config is only useful when debugging; its String() redacts the routing keys and URLs.
+	reqLogger.V(1).Info("DEBUG: Marshalled Alertmanager config", "Config", amconfig.String())
+	if r.Verifier == nil || r.verifyState.failedFingerprint == "" {
+		return desired, nil
+	}
+	fingerprint, err := verify.FingerprintYAML(string(desired))
+	if err != nil {
+		return nil, err
+	}
+	if fingerprint != r.verifyState.failedFingerprint {
+		// the inputs changed since the revert, give the new config a chance
+		reqLogger.Info("INFO: Desired config changed since it was reverted; writing it again")
+		r.verifyState.failedFingerprint = ""
+		return desired, nil
+	}
+	reqLogger.Info("WARNING: Desired config was reverted because Alertmanager could not load it; keeping the last good config")
+	return r.verifyState.lastGood, nil
+}
+
+// verifyAppliedConfig compares the config Alertmanager has loaded with the config written into alertmanager-main,
+// and reverts to the last good config if Alertmanager keeps failing to load it. It returns when to check again.
+func (r *SecretReconciler) verifyAppliedConfig(ctx context.Context, reqLogger logr.Logger, written []byte) reconcile.Result {
+	expected, err := verify.FingerprintYAML(string(written))
+	if err != nil {
+		reqLogger.Error(err, "ERROR: Could not fingerprint the written Alertmanager config")
+		return reconcile.Result{}
+	}
+
+	reloadSuccessful, err := r.Verifier.LastReloadSuccessful(ctx)
+	if err != nil {
+		reqLogger.Error(err, "ERROR: Could not read the Alertmanager reload status")
+		metrics.IncConfigVerifyErrors()
+		return reconcile.Result{RequeueAfter: configVerifyErrorRequeueDelay}
+	}
+	loaded, err := r.Verifier.LoadedConfig(ctx)
+	if err != nil {
+		reqLogger.Error(err, "ERROR: Could not read the config loaded by Alertmanager")
+		metrics.IncConfigVerifyErrors()
+		return reconcile.Result{RequeueAfter: configVerifyErrorRequeueDelay}
+	}
+	actual, err := verify.FingerprintYAML(loaded)
+	if err != nil {
+		reqLogger.Error(err, "ERROR: Could not fingerprint the config loaded by Alertmanager")
+		metrics.IncConfigVerifyErrors()
+		return reconcile.Result{RequeueAfter: configVerifyErrorRequeueDelay}
+	}
+
+	inSync := actual == expected
+	metrics.UpdateConfigVerifyMetrics(inSync, reloadSuccessful)
+	if inSync {
+		reqLogger.Info("DEBUG: Alertmanager loaded the written config", "Fingerprint", expected)
+		r.verifyState.lastGood = written
+		r.verifyState.mismatchSince = time.Time{}
+		return reconcile.Result{}
+	}
+
+	now := time.Now()
+	if r.verifyState.mismatchSince.IsZero() {
+		r.verifyState.mismatchSince = now
+	}
+	mismatchFor := now.Sub(r.verifyState.mismatchSince)
+	reqLogger.Info("WARNING: Alertmanager has not loaded the written config", "Expected", expected, "Loaded", actual,
+		"ReloadSuccessful", reloadSuccessful, "MismatchFor", mismatchFor.String())
+
+	// Alertmanager keeps running its previous config when a reload fails. Only a failed reload is
+	// fixed by reverting; otherwise Alertmanager is just slow to pick up the change.
+	if mismatchFor < configRevertAfter || reloadSuccessful || r.verifyState.lastGood == nil || r.verifyState.failedFingerprint != "" {
+		return reconcile.Result{RequeueAfter: configVerifyRequeueDelay}
+	}
+
+	if err := writeAlertManagerSecret(r, reqLogger, r.verifyState.lastGood); err != nil {
+		return reconcile.Result{RequeueAfter: configVerifyRequeueDelay}
+	}
+	r.verifyState.failedFingerprint = expected
+	r.verifyState.mismatchSince = now
+	metrics.IncConfigReverts()
+	reqLogger.Info("WARNING: Reverted secret alertmanager-main to the last config Alertmanager loaded", "Reverted", expected)
+	r.recordRevertEvent(ctx, reqLogger, mismatchFor)
+	return reconcile.Result{RequeueAfter: configVerifyRequeueDelay}
+}
+
+func (r *SecretReconciler) recordRevertEvent(ctx context.Context, reqLogger logr.Logger, mismatchFor time.Duration) {
+	if r.Recorder == nil {
+		return
+	}
+	secret := &corev1.Secret{}
+	objectKey := types.NamespacedName{Name: secretNameAlertmanager, Namespace: config.OperatorNamespace}
+	if err := r.Client.Get(ctx, objectKey, secret); err != nil {
+		reqLogger.Error(err, "ERROR: Could not read secret alertmanager-main")
+		return
+	}
+	r.Recorder.Eventf(secret, corev1.EventTypeWarning, eventReasonConfigReverted,
+		"Alertmanager failed to reload the written config for %s; reverted to the last config it loaded", mismatchFor.Round(time.Second))
+}
@@ -0,0 +1,140 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/openshift/configure-alertmanager-operator/config"
+	"github.com/openshift/configure-alertmanager-operator/pkg/readiness"
+	"github.com/openshift/configure-alertmanager-operator/pkg/verify"
+	"go.uber.org/mock/gomock"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// fakeAlertmanager stands in for the Alertmanager API. Once a config is loaded it keeps serving it
+// until loadsWritten allows it to load the one in alertmanager-main.
+type fakeAlertmanager struct {
+	reconciler   *SecretReconciler
+	loadsWritten bool
+	loaded       string
+}
+
+func (f *fakeAlertmanager) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if f.loadsWritten {
+		secret := &corev1.Secret{}
+		objectKey := types.NamespacedName{Name: secretNameAlertmanager, Namespace: config.OperatorNamespace}
+		if err := f.reconciler.Client.Get(context.TODO(), objectKey, secret); err == nil {
+			f.loaded = string(secret.Data["alertmanager.yaml"])
+		}
+	}
+	switch r.URL.Path {
+	case "/api/v2/status":
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"config": map[string]string{"original": f.loaded}})
+	case "/metrics":
+		reloadSuccessful := "0"
+		if f.loadsWritten {
+			reloadSuccessful = "1"
+		}
+		_, _ = w.Write([]byte("alertmanager_config_last_reload_successful " + reloadSuccessful + "\n"))
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func readAlertManagerSecret(t *testing.T, reconciler *SecretReconciler) string {
+	secret := &corev1.Secret{}
+	objectKey := types.NamespacedName{Name: secretNameAlertmanager, Namespace: config.OperatorNamespace}
+	if err := reconciler.Client.Get(context.TODO(), objectKey, secret); err != nil {
+		t.Fatalf("Could not read secret alertmanager-main: %v", err)
+	}
+	return string(secret.Data["alertmanager.yaml"])
+}
+
+func Test_Reconcile_VerifyConfig(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockReadiness := readiness.NewMockInterface(ctrl)
+	mockReadiness.EXPECT().IsReady().AnyTimes().Return(true, nil)
+	mockReadiness.EXPECT().Result().AnyTimes().Return(reconcile.Result{})
+	reconciler := createReconciler(t, mockReadiness)
+	recorder := record.NewFakeRecorder(10)
+	reconciler.Recorder = recorder
+	createNamespace(reconciler, t)
+	createClusterVersion(reconciler)
+	createClusterProxy(reconciler)
+
+	alertmanager := &fakeAlertmanager{reconciler: reconciler, loadsWritten: true}
+	server := httptest.NewServer(alertmanager)
+	defer server.Close()
+	reconciler.Verifier = &verify.Verifier{URL: server.URL, Client: server.Client()}
+	req := createReconcileRequest(reconciler, secretNameAlertmanager)
+
+	// Alertmanager loads the first config
+	result, err := reconciler.Reconcile(context.TODO(), *req)
+	assertEquals(t, nil, err, "Unexpected err")
+	assertEquals(t, reconcile.Result{}, result, "In sync result")
+	good := readAlertManagerSecret(t, reconciler)
+	assertEquals(t, []byte(good), reconciler.verifyState.lastGood, "Last good config")
+
+	// Alertmanager fails to load the next one
+	alertmanager.loadsWritten = false
+	createSecret(reconciler, secretNamePD, secretKeyPD, "asdaidsgadfi9853")
+	result, err = reconciler.Reconcile(context.TODO(), *req)
+	assertEquals(t, nil, err, "Unexpected err")
+	assertEquals(t, configVerifyRequeueDelay, result.RequeueAfter, "Mismatch requeue")
+	bad := readAlertManagerSecret(t, reconciler)
+	assertTrue(t, bad != good, "The new config was not written")
+	assertEquals(t, 0, len(recorder.Events), "Reverted before the mismatch persisted")
+
+	// the mismatch persists
+	reconciler.verifyState.mismatchSince = time.Now().Add(-configRevertAfter)
+	_, err = reconciler.Reconcile(context.TODO(), *req)
+	assertEquals(t, nil, err, "Unexpected err")
+	assertEquals(t, good, readAlertManagerSecret(t, reconciler), "The config was not reverted")
+	assertEquals(t, 1, len(recorder.Events), "Number of Events")
+	event := <-recorder.Events
+	assertTrue(t, strings.HasPrefix(event, corev1.EventTypeWarning+" "+eventReasonConfigReverted+" "), "Unexpected Event "+event)
+
+	// the reverted config is not written again while the inputs are the same
+	_, err = reconciler.Reconcile(context.TODO(), *req)
+	assertEquals(t, nil, err, "Unexpected err")
+	assertEquals(t, good, readAlertManagerSecret(t, reconciler), "The reverted config was written again")
+
+	// until they change
+	alertmanager.loadsWritten = true
+	createSecret(reconciler, secretNameDMS, secretKeyDMS, "http://theinterwebs/asdf")
+	result, err = reconciler.Reconcile(context.TODO(), *req)
+	assertEquals(t, nil, err, "Unexpected err")
+	assertEquals(t, reconcile.Result{}, result, "In sync result")
+	assertTrue(t, readAlertManagerSecret(t, reconciler) != good, "The changed config was not written")
+	assertEquals(t, "", reconciler.verifyState.failedFingerprint, "Failed fingerprint")
+}
+
+func Test_Reconcile_VerifyConfigUnreachable(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockReadiness := readiness.NewMockInterface(ctrl)
+	mockReadiness.EXPECT().IsReady().Times(1).Return(true, nil)
+	mockReadiness.EXPECT().Result().Times(1).Return(reconcile.Result{})
+	reconciler := createReconciler(t, mockReadiness)
+	createNamespace(reconciler, t)
+	createClusterVersion(reconciler)
+	createClusterProxy(reconciler)
+
+	server := httptest.NewServer(http.NotFoundHandler())
+	defer server.Close()
+	reconciler.Verifier = &verify.Verifier{URL: server.URL, Client: server.Client()}
+
+	result, err := reconciler.Reconcile(context.TODO(), *createReconcileRequest(reconciler, secretNameAlertmanager))
+	assertEquals(t, nil, err, "Unexpected err")
+	assertEquals(t, configVerifyErrorRequeueDelay, result.RequeueAfter, "Unreachable requeue")
+	readAlertManagerSecret(t, reconciler)
+}
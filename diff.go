@@ -0,0 +1,75 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+
+	operatorconfig "github.com/openshift/configure-alertmanager-operator/config"
+	"github.com/openshift/configure-alertmanager-operator/controllers"
+	"github.com/openshift/configure-alertmanager-operator/pkg/readiness"
+)
+
+// diffCommand runs a single dry-run reconcile against the current kubeconfig context and exits.
+const diffCommand = "diff"
+
+// runDiff prints the redacted diff between the live alertmanager-main secret and the config the
+// operator would write, and returns the process exit code.
+func runDiff(args []string) int {
+	fs := flag.NewFlagSet(diffCommand, flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s %s [flags]\n\n", os.Args[0], diffCommand)
+		fmt.Fprintln(fs.Output(), "Print the changes the operator would make to the alertmanager-main secret. Nothing is written to the cluster.")
+		fs.PrintDefaults()
+	}
+	opts := zap.Options{
+		Development: true,
+	}
+	opts.BindFlags(fs)
+	_ = fs.Parse(args)
+
+	ctrl.SetLogger(zap.New(zap.UseFlagOptions(&opts)))
+
+	if err := operatorconfig.SetIsFedramp(); err != nil {
+		setupLog.Error(err, "failed to get fedramp value")
+		return 1
+	}
+
+	c, err := client.New(ctrl.GetConfigOrDie(), client.Options{Scheme: scheme})
+	if err != nil {
+		setupLog.Error(err, "unable to create client")
+		return 1
+	}
+
+	reconciler := &controllers.SecretReconciler{
+		Client:    c,
+		Scheme:    scheme,
+		Readiness: &readiness.Impl{Client: c},
+	}
+	if err := reconciler.DiffOnce(context.TODO(), os.Stdout); err != nil {
+		setupLog.Error(err, "dry run failed")
+		return 1
+	}
+	return 0
+}
@@ -31,6 +31,7 @@ import (
 	configv1 "github.com/openshift/api/config/v1"
 	operatorconfig "github.com/openshift/configure-alertmanager-operator/config"
 	operatormetrics "github.com/openshift/configure-alertmanager-operator/pkg/metrics"
+	"github.com/openshift/configure-alertmanager-operator/pkg/verify"
 	monitoringv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
 
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
@@ -58,6 +59,7 @@ var log = logf.Log.WithName("cmd")
 func printVersion() {
 	log.Info(fmt.Sprintf("Go Version: %s", runtime.Version()))
 	log.Info(fmt.Sprintf("Go OS/Arch: %s/%s", runtime.GOOS, runtime.GOARCH))
+	log.Info(fmt.Sprintf("Operator Version: %s", operatorconfig.OperatorVersion()))
 }
 
 func init() {
@@ -68,12 +70,29 @@ func init() {
 }
 
 func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case diffCommand:
+			os.Exit(runDiff(os.Args[2:]))
+		case renderCommand:
+			os.Exit(runRender(os.Args[2:]))
+		}
+	}
+
 	var enableLeaderElection bool
+	var dryRun bool
+	var verifyConfig bool
 	var probeAddr string
 	flag.StringVar(&probeAddr, "health-probe-bind-address", ":8081", "The address the probe endpoint binds to.")
 	flag.BoolVar(&enableLeaderElection, "leader-elect", false,
 		"Enable leader election for controller manager. "+
 			"Enabling this will ensure there is only one active controller manager.")
+	flag.BoolVar(&dryRun, "dry-run", false,
+		"Report the changes to the alertmanager-main secret in the logs, an Event and the "+
+			"configure-alertmanager-operator-dry-run ConfigMap instead of writing them.")
+	flag.BoolVar(&verifyConfig, "verify-config", false,
+		"Check through the Alertmanager API that Alertmanager loaded the written config, and revert to the "+
+			"last good config when it keeps failing to reload.")
 	opts := zap.Options{
 		Development: true,
 	}
@@ -110,9 +129,27 @@ func main() {
 		os.Exit(1)
 	}
 
+	if dryRun {
+		setupLog.Info("running in dry-run mode, alertmanager-main will not be updated.")
+	}
+
+	var verifier *verify.Verifier
+	if verifyConfig && !dryRun {
+		verifier, err = verify.NewFromEnv()
+		if err != nil {
+			setupLog.Error(err, "unable to create the Alertmanager config verifier")
+			os.Exit(1)
+		}
+		setupLog.Info("verifying the config loaded by Alertmanager", "url", verifier.URL)
+	}
+
 	if err = (&controllers.SecretReconciler{
-		Client: mgr.GetClient(),
-		Scheme: mgr.GetScheme(),
+		Client:    mgr.GetClient(),
+		Scheme:    mgr.GetScheme(),
+		APIReader: mgr.GetAPIReader(),
+		Recorder:  mgr.GetEventRecorderFor(operatorconfig.OperatorName),
+		DryRun:    dryRun,
+		Verifier:  verifier,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "Secret")
 		os.Exit(1)
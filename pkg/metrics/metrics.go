@@ -16,9 +16,9 @@ package metrics
 
 import (
 	"net/http"
+	"time"
 
 	"github.com/openshift/configure-alertmanager-operator/config"
-	alertmanager "github.com/openshift/configure-alertmanager-operator/pkg/types"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	corev1 "k8s.io/api/core/v1"
@@ -27,37 +27,34 @@ import (
 const (
 	// MetricsEndpoint is the port to export metrics on
 	MetricsEndpoint = ":8080"
+
+	// Results of writing the alertmanager-main secret
+	ConfigWriteCreated = "created"
+	ConfigWriteUpdated = "updated"
+	ConfigWriteError   = "error"
 )
 
 var (
-	metricGASecretExists = prometheus.NewGaugeVec(prometheus.GaugeOpts{
-		Name: "ga_secret_exists",
-		Help: "GoAlert secret exists",
-	}, []string{"name"})
-	metricPDSecretExists = prometheus.NewGaugeVec(prometheus.GaugeOpts{
-		Name: "pd_secret_exists",
-		Help: "Pager Duty secret exists",
-	}, []string{"name"})
-	metricDMSSecretExists = prometheus.NewGaugeVec(prometheus.GaugeOpts{
-		Name: "dms_secret_exists",
-		Help: "Dead Man's Snitch secret exists",
-	}, []string{"name"})
 	metricAMSecretExists = prometheus.NewGaugeVec(prometheus.GaugeOpts{
 		Name: "am_secret_exists",
 		Help: "AlertManager Config secret exists",
 	}, []string{"name"})
-	metricAMSecretContainsGA = prometheus.NewGaugeVec(prometheus.GaugeOpts{
-		Name: "am_secret_contains_ga",
-		Help: "AlertManager Config contains configuration for GoAlert",
-	}, []string{"name"})
-	metricAMSecretContainsPD = prometheus.NewGaugeVec(prometheus.GaugeOpts{
-		Name: "am_secret_contains_pd",
-		Help: "AlertManager Config contains configuration for Pager Duty",
-	}, []string{"name"})
-	metricAMSecretContainsDMS = prometheus.NewGaugeVec(prometheus.GaugeOpts{
-		Name: "am_secret_contains_dms",
-		Help: "AlertManager Config contains configuration for Dead Man's Snitch",
-	}, []string{"name"})
+	metricIntegrationSourceExists = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "cam_integration_source_exists",
+		Help: "The Secret or ConfigMap configuring the integration exists",
+	}, []string{"name", "integration"})
+	metricIntegrationConfigured = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "cam_integration_configured",
+		Help: "The main receiver of the integration is in the applied AlertManager config",
+	}, []string{"name", "integration"})
+	metricIntegrationExpected = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "cam_integration_expected",
+		Help: "The integration should be configured: its source exists and, if it pages, the cluster is ready",
+	}, []string{"name", "integration"})
+	metricReceiverConfigured = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "cam_receiver_configured",
+		Help: "The receiver is in the applied AlertManager config",
+	}, []string{"name", "integration", "receiver"})
 	metricManNSConfigMapExists = prometheus.NewGaugeVec(prometheus.GaugeOpts{
 		Name: "managed_namespaces_configmap_exists",
 		Help: "managed-namespaces configMap exists",
@@ -67,16 +64,116 @@ var (
 		Help: "ocp-namespaces configMap exists",
 	}, []string{"name"})
 
+	metricClusterReady = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "cluster_ready",
+		Help: "The cluster is considered ready, so alerts are routed to SRE",
+	}, []string{"name"})
+	metricClusterReadyReason = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "cluster_ready_reason",
+		Help: "Why the cluster is or isn't considered ready; the current reason is 1",
+	}, []string{"name", "reason"})
+	metricClusterCreationTimestamp = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "cluster_creation_timestamp_seconds",
+		Help: "When the cluster was created, in seconds since the epoch",
+	}, []string{"name"})
+	metricReadinessCheckErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "readiness_check_errors_total",
+		Help: "Number of readiness checks that could not be evaluated",
+	}, []string{"name", "check"})
+	metricReadinessOverride = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "readiness_override",
+		Help: "A manual readiness override is in effect; the override is ready or not-ready",
+	}, []string{"name", "override"})
+	metricReadinessOverrideExpiry = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "readiness_override_expiry_timestamp_seconds",
+		Help: "When the readiness override in effect expires, in seconds since the epoch; 0 if there is none",
+	}, []string{"name"})
+	metricReconcileDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "cam_reconcile_duration_seconds",
+		Help:    "Time spent reconciling the Alertmanager config, by phase: list, parse, render or write",
+		Buckets: []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10},
+	}, []string{"name", "phase"})
+	metricConfigWrites = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "cam_alertmanager_config_writes_total",
+		Help: "Writes of the alertmanager-main secret, by result: created, updated or error",
+	}, []string{"name", "result"})
+	metricGeneratedRoutes = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "cam_generated_routes",
+		Help: "Number of routes, at any depth, in the generated Alertmanager config",
+	}, []string{"name"})
+	metricGeneratedReceivers = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "cam_generated_receivers",
+		Help: "Number of receivers in the generated Alertmanager config",
+	}, []string{"name"})
+	metricLastSuccessfulApply = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "cam_last_successful_apply_timestamp_seconds",
+		Help: "When the alertmanager-main secret was last written successfully, in seconds since the epoch",
+	}, []string{"name"})
+	metricConfigInSync = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "cam_config_in_sync",
+		Help: "Whether the config loaded by Alertmanager matches the config last written by the operator",
+	}, []string{"name"})
+	metricConfigLastReloadSuccessful = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "cam_config_last_reload_successful",
+		Help: "Whether Alertmanager's last config reload succeeded, as last seen by the operator",
+	}, []string{"name"})
+	metricConfigVerifyErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "cam_config_verify_errors_total",
+		Help: "Failed attempts to read the loaded config or reload status from Alertmanager",
+	}, []string{"name"})
+	metricConfigReverts = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "cam_config_reverts_total",
+		Help: "Times the operator reverted alertmanager-main to the last config Alertmanager loaded",
+	}, []string{"name"})
+	metricConfigHistoryRevision = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "cam_config_history_revision",
+		Help: "Latest revision recorded in the config history",
+	}, []string{"name"})
+	metricConfigRollbackRevision = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "cam_config_rollback_revision",
+		Help: "Revision of the config history alertmanager-main is pinned to; 0 if there is no rollback",
+	}, []string{"name"})
+	metricNamespaceConfigProblems = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "cam_namespace_config_problems",
+		Help: "Problems found the last time a namespace configMap was parsed; it is only used if there are none",
+	}, []string{"name", "configmap"})
+	metricIntegrationRemovalPending = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "cam_integration_removal_pending",
+		Help: "Whether the source of the integration is missing and its receivers are kept until the removal grace period ends",
+	}, []string{"name", "integration"})
+	metricIntegrationRemovalDeadline = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "cam_integration_removal_deadline_timestamp_seconds",
+		Help: "When the receivers of the integration are removed if its source is still missing; 0 if no removal is pending",
+	}, []string{"name", "integration"})
+
 	metricsList = []prometheus.Collector{
-		metricGASecretExists,
-		metricPDSecretExists,
-		metricDMSSecretExists,
 		metricAMSecretExists,
-		metricAMSecretContainsGA,
-		metricAMSecretContainsPD,
-		metricAMSecretContainsDMS,
+		metricIntegrationSourceExists,
+		metricIntegrationConfigured,
+		metricIntegrationExpected,
+		metricReceiverConfigured,
 		metricManNSConfigMapExists,
 		metricOcpNSConfigMapExists,
+		metricClusterReady,
+		metricClusterReadyReason,
+		metricClusterCreationTimestamp,
+		metricReadinessCheckErrors,
+		metricReadinessOverride,
+		metricReadinessOverrideExpiry,
+		metricReconcileDuration,
+		metricConfigWrites,
+		metricGeneratedRoutes,
+		metricGeneratedReceivers,
+		metricLastSuccessfulApply,
+		metricConfigInSync,
+		metricConfigLastReloadSuccessful,
+		metricConfigVerifyErrors,
+		metricConfigReverts,
+		metricConfigHistoryRevision,
+		metricConfigRollbackRevision,
+		metricNamespaceConfigProblems,
+		metricIntegrationRemovalPending,
+		metricIntegrationRemovalDeadline,
 	}
 )
 
@@ -105,94 +202,54 @@ func RegisterMetrics() error {
 	return nil
 }
 
-// UpdateSecretsMetrics updates all metrics related to the existence and contents of Secrets
-// used by configure-alertmanager-operator.
-func UpdateSecretsMetrics(list *corev1.SecretList, amconfig *alertmanager.Config) {
+// IntegrationState is the state of an integration, as reported by the integration metrics.
+type IntegrationState struct {
+	Integration string
+	// SourceExists indicates the Secret or ConfigMap configuring the integration exists.
+	SourceExists bool
+	// Expected indicates the operator intends to configure the integration. A paging integration is not
+	// expected before the cluster is ready, even if its source exists.
+	Expected bool
+	// Configured indicates the main receiver of the integration is in the applied config.
+	Configured bool
+	// Receivers maps every receiver of the integration to whether it is in the applied config.
+	Receivers map[string]bool
+}
 
-	// Default to false.
-	gaSecretExists := false
-	pdSecretExists := false
-	dmsSecretExists := false
+// UpdateSecretsMetrics updates the metrics related to the existence of the alertmanager-main Secret.
+func UpdateSecretsMetrics(list *corev1.SecretList) {
 	amSecretExists := false
-	amSecretContainsGA := false
-	amSecretContainsPD := false
-	amSecretContainsDMS := false
-
-	// Update the metric if the secret is found in the SecretList.
 	for _, secret := range list.Items {
-		switch secret.Name {
-		case "goalert-secret":
-			gaSecretExists = true
-		case "pd-secret":
-			pdSecretExists = true
-		case "dms-secret":
-			dmsSecretExists = true
-		case "alertmanager-main":
+		if secret.Name == "alertmanager-main" {
 			amSecretExists = true
 		}
 	}
 
-	// Check for the presence of GoAlert, PD and DMS configs inside the AlertManager config and report metrics.
-	if amSecretExists {
-		if gaSecretExists {
-			for _, receiver := range amconfig.Receivers {
-				if receiver.Name == "goalert" {
-					amSecretContainsGA = true
-				}
-			}
-		}
-		if pdSecretExists {
-			for _, receiver := range amconfig.Receivers {
-				if receiver.Name == "pagerduty" {
-					amSecretContainsPD = true
-				}
-			}
-		}
-		if dmsSecretExists {
-			for _, receiver := range amconfig.Receivers {
-				if receiver.Name == "watchdog" {
-					amSecretContainsDMS = true
-				}
-			}
-		}
-	}
-
-	// Only set metrics once per run.
-	if gaSecretExists {
-		metricGASecretExists.With(prometheus.Labels{"name": config.OperatorName}).Set(float64(1))
-	} else {
-		metricGASecretExists.With(prometheus.Labels{"name": config.OperatorName}).Set(float64(0))
-	}
-	if pdSecretExists {
-		metricPDSecretExists.With(prometheus.Labels{"name": config.OperatorName}).Set(float64(1))
-	} else {
-		metricPDSecretExists.With(prometheus.Labels{"name": config.OperatorName}).Set(float64(0))
-	}
-	if dmsSecretExists {
-		metricDMSSecretExists.With(prometheus.Labels{"name": config.OperatorName}).Set(float64(1))
-	} else {
-		metricDMSSecretExists.With(prometheus.Labels{"name": config.OperatorName}).Set(float64(0))
-	}
 	if amSecretExists {
 		metricAMSecretExists.With(prometheus.Labels{"name": config.OperatorName}).Set(float64(1))
 	} else {
 		metricAMSecretExists.With(prometheus.Labels{"name": config.OperatorName}).Set(float64(0))
 	}
-	if amSecretContainsGA {
-		metricAMSecretContainsGA.With(prometheus.Labels{"name": config.OperatorName}).Set(float64(1))
-	} else {
-		metricAMSecretContainsGA.With(prometheus.Labels{"name": config.OperatorName}).Set(float64(0))
-	}
-	if amSecretContainsPD {
-		metricAMSecretContainsPD.With(prometheus.Labels{"name": config.OperatorName}).Set(float64(1))
-	} else {
-		metricAMSecretContainsPD.With(prometheus.Labels{"name": config.OperatorName}).Set(float64(0))
+}
+
+// UpdateIntegrationMetrics updates the metrics reporting whether each integration is set up and configured.
+func UpdateIntegrationMetrics(states []IntegrationState) {
+	for _, state := range states {
+		labels := prometheus.Labels{"name": config.OperatorName, "integration": state.Integration}
+		metricIntegrationSourceExists.With(labels).Set(boolToFloat(state.SourceExists))
+		metricIntegrationConfigured.With(labels).Set(boolToFloat(state.Configured))
+		metricIntegrationExpected.With(labels).Set(boolToFloat(state.Expected))
+		for receiver, configured := range state.Receivers {
+			metricReceiverConfigured.With(prometheus.Labels{"name": config.OperatorName, "integration": state.Integration, "receiver": receiver}).Set(boolToFloat(configured))
+		}
 	}
-	if amSecretContainsDMS {
-		metricAMSecretContainsDMS.With(prometheus.Labels{"name": config.OperatorName}).Set(float64(1))
-	} else {
-		metricAMSecretContainsDMS.With(prometheus.Labels{"name": config.OperatorName}).Set(float64(0))
+}
+
+func boolToFloat(b bool) float64 {
+	if b {
+		return 1
 	}
+	return 0
 }
 
 // UpdateConfigMapMetrics updates all metrics related to the existence and contents of ConfigMaps
@@ -225,3 +282,99 @@ func UpdateConfigMapMetrics(list *corev1.ConfigMapList) {
 		metricOcpNSConfigMapExists.With(prometheus.Labels{"name": config.OperatorName}).Set(float64(0))
 	}
 }
+
+// UpdateReadinessMetrics updates the metrics reporting whether the cluster is considered ready, and why.
+func UpdateReadinessMetrics(ready bool, reason string) {
+	if ready {
+		metricClusterReady.With(prometheus.Labels{"name": config.OperatorName}).Set(float64(1))
+	} else {
+		metricClusterReady.With(prometheus.Labels{"name": config.OperatorName}).Set(float64(0))
+	}
+	// Only the current reason is reported.
+	metricClusterReadyReason.Reset()
+	metricClusterReadyReason.With(prometheus.Labels{"name": config.OperatorName, "reason": reason}).Set(float64(1))
+}
+
+// UpdateClusterCreationTime reports when the cluster was created.
+func UpdateClusterCreationTime(created time.Time) {
+	metricClusterCreationTimestamp.With(prometheus.Labels{"name": config.OperatorName}).Set(float64(created.Unix()))
+}
+
+// IncReadinessCheckErrors counts a readiness check that could not be evaluated.
+func IncReadinessCheckErrors(check string) {
+	metricReadinessCheckErrors.With(prometheus.Labels{"name": config.OperatorName, "check": check}).Inc()
+}
+
+// UpdateReadinessOverrideMetrics reports the readiness override in effect, if override is not empty.
+func UpdateReadinessOverrideMetrics(override string, expires time.Time) {
+	metricReadinessOverride.Reset()
+	if override == "" {
+		metricReadinessOverrideExpiry.With(prometheus.Labels{"name": config.OperatorName}).Set(float64(0))
+		return
+	}
+	metricReadinessOverride.With(prometheus.Labels{"name": config.OperatorName, "override": override}).Set(float64(1))
+	metricReadinessOverrideExpiry.With(prometheus.Labels{"name": config.OperatorName}).Set(float64(expires.Unix()))
+}
+
+// ObserveReconcilePhase records how long a phase of the reconcile took.
+func ObserveReconcilePhase(phase string, duration time.Duration) {
+	metricReconcileDuration.With(prometheus.Labels{"name": config.OperatorName, "phase": phase}).Observe(duration.Seconds())
+}
+
+// UpdateGeneratedConfigMetrics reports the size of the generated Alertmanager config.
+func UpdateGeneratedConfigMetrics(routes, receivers int) {
+	metricGeneratedRoutes.With(prometheus.Labels{"name": config.OperatorName}).Set(float64(routes))
+	metricGeneratedReceivers.With(prometheus.Labels{"name": config.OperatorName}).Set(float64(receivers))
+}
+
+// RecordConfigWrite counts a write of the alertmanager-main secret, and when it last succeeded.
+func RecordConfigWrite(result string) {
+	metricConfigWrites.With(prometheus.Labels{"name": config.OperatorName, "result": result}).Inc()
+	if result != ConfigWriteError {
+		metricLastSuccessfulApply.With(prometheus.Labels{"name": config.OperatorName}).SetToCurrentTime()
+	}
+}
+
+// UpdateConfigVerifyMetrics reports whether Alertmanager loaded the written config, and whether its last reload succeeded.
+func UpdateConfigVerifyMetrics(inSync, reloadSuccessful bool) {
+	metricConfigInSync.With(prometheus.Labels{"name": config.OperatorName}).Set(boolToFloat(inSync))
+	metricConfigLastReloadSuccessful.With(prometheus.Labels{"name": config.OperatorName}).Set(boolToFloat(reloadSuccessful))
+}
+
+// IncConfigVerifyErrors counts a failure to query Alertmanager for its loaded config.
+func IncConfigVerifyErrors() {
+	metricConfigVerifyErrors.With(prometheus.Labels{"name": config.OperatorName}).Inc()
+}
+
+// IncConfigReverts counts a revert of alertmanager-main to the last good config.
+func IncConfigReverts() {
+	metricConfigReverts.With(prometheus.Labels{"name": config.OperatorName}).Inc()
+}
+
+// UpdateConfigHistoryRevision reports the latest revision recorded in the config history.
+func UpdateConfigHistoryRevision(revision int) {
+	metricConfigHistoryRevision.With(prometheus.Labels{"name": config.OperatorName}).Set(float64(revision))
+}
+
+// UpdateConfigRollbackRevision reports the revision alertmanager-main is pinned to, or 0.
+func UpdateConfigRollbackRevision(revision int) {
+	metricConfigRollbackRevision.With(prometheus.Labels{"name": config.OperatorName}).Set(float64(revision))
+}
+
+// UpdateNamespaceConfigProblems reports the number of problems found in a namespace configMap.
+func UpdateNamespaceConfigProblems(configMap string, problems int) {
+	metricNamespaceConfigProblems.With(prometheus.Labels{"name": config.OperatorName, "configmap": configMap}).Set(float64(problems))
+}
+
+// UpdateIntegrationRemovalPending reports when the receivers of an integration whose source is missing are
+// removed; a zero removeAt means no removal is pending.
+func UpdateIntegrationRemovalPending(integration string, removeAt time.Time) {
+	labels := prometheus.Labels{"name": config.OperatorName, "integration": integration}
+	if removeAt.IsZero() {
+		metricIntegrationRemovalPending.With(labels).Set(0)
+		metricIntegrationRemovalDeadline.With(labels).Set(0)
+		return
+	}
+	metricIntegrationRemovalPending.With(labels).Set(1)
+	metricIntegrationRemovalDeadline.With(labels).Set(float64(removeAt.Unix()))
+}
@@ -0,0 +1,387 @@
+package readiness
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	configv1 "github.com/openshift/api/config/v1"
+	promv1 "github.com/prometheus/client_golang/api/prometheus/v1"
+	"github.com/prometheus/common/model"
+	"gopkg.in/yaml.v2"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/openshift/configure-alertmanager-operator/config"
+	"github.com/openshift/configure-alertmanager-operator/pkg/metrics"
+)
+
+const (
+	// The operator configMap key holding the readiness checks
+	checksConfigMapKey = "readiness_checks.yaml"
+
+	// Check types, as used in the readiness checks configuration
+	CheckTypeJob                         = "Job"
+	CheckTypeClusterVersionAvailable     = "ClusterVersionAvailable"
+	CheckTypeClusterOperatorsNotDegraded = "ClusterOperatorsNotDegraded"
+	CheckTypeConfigMapFlag               = "ConfigMapFlag"
+	CheckTypePrometheusQuery             = "PrometheusQuery"
+
+	// Modes combining the checks
+	ChecksModeAll = "all"
+	ChecksModeAny = "any"
+
+	// How long to wait before re-running a check that didn't pass, unless it says otherwise
+	defaultCheckRequeueDelay = time.Minute
+)
+
+// CheckResult is the outcome of running a Check.
+type CheckResult struct {
+	// Passed indicates the gate is open.
+	Passed bool
+	// Reason explains the result in a few words, for logs.
+	Reason string
+	// RequeueAfter is how soon the check should run again if it didn't pass.
+	// Zero means defaultCheckRequeueDelay.
+	RequeueAfter time.Duration
+	// Code identifies why a passed check passed, for the cluster_ready_reason metric.
+	// Empty means ReasonChecksPassed.
+	Code string
+}
+
+// CheckContext is what checks may use to inspect the cluster.
+type CheckContext struct {
+	Client client.Client
+	// Prometheus returns a client for the in-cluster Prometheus, created on first use.
+	Prometheus func() (promv1.API, error)
+}
+
+// Check is a "ready to page" gate.
+type Check interface {
+	// Name identifies the check in logs.
+	Name() string
+	// Run evaluates the check. An error means it could not be evaluated at all, and fails the
+	// readiness determination for this reconcile.
+	Run(ctx context.Context, cc CheckContext) (CheckResult, error)
+}
+
+// ChecksConfig is the readiness checks configuration from the operator configMap.
+type ChecksConfig struct {
+	// Mode is "all" (default) if every check must pass, or "any" if one is enough.
+	Mode   string        `yaml:"mode,omitempty"`
+	Checks []CheckConfig `yaml:"checks"`
+}
+
+// CheckConfig configures a single check. Which fields apply depends on the Type.
+type CheckConfig struct {
+	Type string `yaml:"type"`
+
+	// Job, ConfigMapFlag
+	Namespace string `yaml:"namespace,omitempty"`
+	Name      string `yaml:"name,omitempty"`
+
+	// ClusterOperatorsNotDegraded; all ClusterOperators if empty
+	Names []string `yaml:"names,omitempty"`
+
+	// ConfigMapFlag; Value defaults to "true"
+	Key   string `yaml:"key,omitempty"`
+	Value string `yaml:"value,omitempty"`
+
+	// PrometheusQuery
+	Query string `yaml:"query,omitempty"`
+}
+
+// defaultChecksConfig waits for the osd-cluster-ready Job.
+func defaultChecksConfig() *ChecksConfig {
+	return &ChecksConfig{
+		Mode:   ChecksModeAll,
+		Checks: []CheckConfig{{Type: CheckTypeJob, Namespace: config.OperatorNamespace, Name: jobName}},
+	}
+}
+
+// NewCheck creates the Check described by cfg.
+func NewCheck(cfg CheckConfig) (Check, error) {
+	switch cfg.Type {
+	case CheckTypeJob:
+		if cfg.Name == "" {
+			return nil, fmt.Errorf("%s check requires a name", cfg.Type)
+		}
+		return &JobCheck{Namespace: defaultString(cfg.Namespace, config.OperatorNamespace), JobName: cfg.Name}, nil
+	case CheckTypeClusterVersionAvailable:
+		return &ClusterVersionAvailableCheck{}, nil
+	case CheckTypeClusterOperatorsNotDegraded:
+		return &ClusterOperatorsNotDegradedCheck{Names: cfg.Names}, nil
+	case CheckTypeConfigMapFlag:
+		if cfg.Name == "" || cfg.Key == "" {
+			return nil, fmt.Errorf("%s check requires a name and a key", cfg.Type)
+		}
+		return &ConfigMapFlagCheck{
+			Namespace:     defaultString(cfg.Namespace, config.OperatorNamespace),
+			ConfigMapName: cfg.Name,
+			Key:           cfg.Key,
+			Value:         defaultString(cfg.Value, "true"),
+		}, nil
+	case CheckTypePrometheusQuery:
+		if cfg.Query == "" {
+			return nil, fmt.Errorf("%s check requires a query", cfg.Type)
+		}
+		return &PrometheusQueryCheck{Query: cfg.Query}, nil
+	default:
+		return nil, fmt.Errorf("unknown check type %q", cfg.Type)
+	}
+}
+
+// parseChecksConfig parses and validates the readiness checks configuration.
+func parseChecksConfig(raw string) (mode string, checks []Check, err error) {
+	cfg := &ChecksConfig{}
+	if err := yaml.UnmarshalStrict([]byte(raw), cfg); err != nil {
+		return "", nil, err
+	}
+	mode = defaultString(cfg.Mode, ChecksModeAll)
+	if mode != ChecksModeAll && mode != ChecksModeAny {
+		return "", nil, fmt.Errorf("unknown mode %q", cfg.Mode)
+	}
+	if len(cfg.Checks) == 0 {
+		return "", nil, fmt.Errorf("no checks configured")
+	}
+	for _, checkCfg := range cfg.Checks {
+		check, err := NewCheck(checkCfg)
+		if err != nil {
+			return "", nil, err
+		}
+		checks = append(checks, check)
+	}
+	return mode, checks, nil
+}
+
+// loadChecks returns the configured readiness checks, or the default ones if the operator configMap
+// doesn't configure any or configures them incorrectly.
+func loadChecks(ctx context.Context, c client.Client) (mode string, checks []Check) {
+	cm := &corev1.ConfigMap{}
+	err := c.Get(ctx, types.NamespacedName{Namespace: config.OperatorNamespace, Name: config.OperatorName}, cm)
+	if err != nil && !errors.IsNotFound(err) {
+		log.Error(err, "Failed to read the readiness checks; using the defaults")
+	}
+	if raw := cm.Data[checksConfigMapKey]; err == nil && raw != "" {
+		mode, checks, err := parseChecksConfig(raw)
+		if err == nil {
+			return mode, checks
+		}
+		log.Error(err, "Invalid readiness checks; using the defaults", "ConfigMap", config.OperatorName)
+	}
+
+	def := defaultChecksConfig()
+	for _, checkCfg := range def.Checks {
+		check, _ := NewCheck(checkCfg)
+		checks = append(checks, check)
+	}
+	return def.Mode, checks
+}
+
+// runChecks runs the checks and combines their results according to mode.
+// When the checks don't pass, the result asks to requeue after the shortest delay requested
+// by a failing check.
+func runChecks(ctx context.Context, cc CheckContext, mode string, checks []Check) (CheckResult, error) {
+	combined := CheckResult{Passed: mode == ChecksModeAll}
+	for _, check := range checks {
+		result, err := check.Run(ctx, cc)
+		if err != nil {
+			metrics.IncReadinessCheckErrors(check.Name())
+			return CheckResult{}, fmt.Errorf("readiness check %s failed: %w", check.Name(), err)
+		}
+		log.Info(fmt.Sprintf("INFO: Readiness check %s passed: %t (%s)", check.Name(), result.Passed, result.Reason))
+		if result.Passed {
+			if mode == ChecksModeAny {
+				return CheckResult{Passed: true, Reason: check.Name() + ": " + result.Reason, Code: result.Code}, nil
+			}
+			combined.Code = result.Code
+			continue
+		}
+
+		combined.Passed = false
+		if combined.Reason == "" {
+			combined.Reason = check.Name() + ": " + result.Reason
+		}
+		delay := result.RequeueAfter
+		if delay == 0 {
+			delay = defaultCheckRequeueDelay
+		}
+		if combined.RequeueAfter == 0 || delay < combined.RequeueAfter {
+			combined.RequeueAfter = delay
+		}
+	}
+	if combined.Passed {
+		combined.Reason = "all readiness checks passed"
+		// A single check, like the default Job, can tell us more.
+		if len(checks) != 1 {
+			combined.Code = ""
+		}
+	} else {
+		combined.Code = ""
+	}
+	return combined, nil
+}
+
+// JobCheck passes once the Job has finished, whether it succeeded or failed.
+type JobCheck struct {
+	Namespace string
+	JobName   string
+}
+
+func (c *JobCheck) Name() string {
+	return fmt.Sprintf("%s(%s/%s)", CheckTypeJob, c.Namespace, c.JobName)
+}
+
+func (c *JobCheck) Run(ctx context.Context, cc CheckContext) (CheckResult, error) {
+	job := &batchv1.Job{}
+	if err := cc.Client.Get(ctx, types.NamespacedName{Namespace: c.Namespace, Name: c.JobName}, job); err != nil {
+		if !errors.IsNotFound(err) {
+			return CheckResult{}, fmt.Errorf("failed to retrieve %s Job: %w", c.JobName, err)
+		}
+		// The delay can be longish because the job will take a while to complete once it does start.
+		return CheckResult{Reason: "Job not found", RequeueAfter: 5 * time.Minute}, nil
+	}
+	if job.Status.Active == 0 {
+		if job.Status.Succeeded > 0 {
+			return CheckResult{Passed: true, Reason: "Job succeeded", Code: ReasonJobSucceeded}, nil
+		}
+		return CheckResult{Passed: true, Reason: "Job failed", Code: ReasonJobFailed}, nil
+	}
+	// We don't want to thrash, but we want to poll the Job fairly frequently so we configure
+	// alerts promptly once it finishes.
+	return CheckResult{Reason: "Job active", RequeueAfter: 10 * time.Second}, nil
+}
+
+// ClusterVersionAvailableCheck passes when the ClusterVersion reports Available=True.
+type ClusterVersionAvailableCheck struct{}
+
+func (c *ClusterVersionAvailableCheck) Name() string {
+	return CheckTypeClusterVersionAvailable
+}
+
+func (c *ClusterVersionAvailableCheck) Run(ctx context.Context, cc CheckContext) (CheckResult, error) {
+	version := &configv1.ClusterVersion{}
+	if err := cc.Client.Get(ctx, types.NamespacedName{Name: "version"}, version); err != nil {
+		return CheckResult{}, fmt.Errorf("failed to retrieve ClusterVersion: %w", err)
+	}
+	for _, condition := range version.Status.Conditions {
+		if condition.Type == configv1.OperatorAvailable && condition.Status == configv1.ConditionTrue {
+			return CheckResult{Passed: true, Reason: "ClusterVersion available"}, nil
+		}
+	}
+	return CheckResult{Reason: "ClusterVersion not available"}, nil
+}
+
+// ClusterOperatorsNotDegradedCheck passes when none of the named ClusterOperators, or none at all
+// if no names are given, reports Degraded=True. Named ClusterOperators must exist.
+type ClusterOperatorsNotDegradedCheck struct {
+	Names []string
+}
+
+func (c *ClusterOperatorsNotDegradedCheck) Name() string {
+	return fmt.Sprintf("%s%v", CheckTypeClusterOperatorsNotDegraded, c.Names)
+}
+
+func (c *ClusterOperatorsNotDegradedCheck) Run(ctx context.Context, cc CheckContext) (CheckResult, error) {
+	operators := []configv1.ClusterOperator{}
+	if len(c.Names) == 0 {
+		list := &configv1.ClusterOperatorList{}
+		if err := cc.Client.List(ctx, list); err != nil {
+			return CheckResult{}, fmt.Errorf("failed to list ClusterOperators: %w", err)
+		}
+		operators = list.Items
+	}
+	for _, name := range c.Names {
+		co := &configv1.ClusterOperator{}
+		if err := cc.Client.Get(ctx, types.NamespacedName{Name: name}, co); err != nil {
+			if errors.IsNotFound(err) {
+				return CheckResult{Reason: fmt.Sprintf("ClusterOperator %s not found", name)}, nil
+			}
+			return CheckResult{}, fmt.Errorf("failed to retrieve ClusterOperator %s: %w", name, err)
+		}
+		operators = append(operators, *co)
+	}
+
+	for _, co := range operators {
+		for _, condition := range co.Status.Conditions {
+			if condition.Type == configv1.OperatorDegraded && condition.Status == configv1.ConditionTrue {
+				return CheckResult{Reason: fmt.Sprintf("ClusterOperator %s degraded", co.Name)}, nil
+			}
+		}
+	}
+	return CheckResult{Passed: true, Reason: "no ClusterOperator degraded"}, nil
+}
+
+// ConfigMapFlagCheck passes when a key of a ConfigMap has the expected value.
+type ConfigMapFlagCheck struct {
+	Namespace     string
+	ConfigMapName string
+	Key           string
+	Value         string
+}
+
+func (c *ConfigMapFlagCheck) Name() string {
+	return fmt.Sprintf("%s(%s/%s:%s)", CheckTypeConfigMapFlag, c.Namespace, c.ConfigMapName, c.Key)
+}
+
+func (c *ConfigMapFlagCheck) Run(ctx context.Context, cc CheckContext) (CheckResult, error) {
+	cm := &corev1.ConfigMap{}
+	if err := cc.Client.Get(ctx, types.NamespacedName{Namespace: c.Namespace, Name: c.ConfigMapName}, cm); err != nil {
+		if errors.IsNotFound(err) {
+			return CheckResult{Reason: "ConfigMap not found"}, nil
+		}
+		return CheckResult{}, fmt.Errorf("failed to retrieve ConfigMap %s: %w", c.ConfigMapName, err)
+	}
+	if cm.Data[c.Key] != c.Value {
+		return CheckResult{Reason: fmt.Sprintf("%s is %q", c.Key, cm.Data[c.Key])}, nil
+	}
+	return CheckResult{Passed: true, Reason: fmt.Sprintf("%s is %q", c.Key, c.Value)}, nil
+}
+
+// PrometheusQueryCheck passes when the query returns at least one sample, like an alerting rule
+// expression. Prometheus being unreachable, which is common early in an install, fails the check
+// rather than the readiness determination.
+type PrometheusQueryCheck struct {
+	Query string
+}
+
+func (c *PrometheusQueryCheck) Name() string {
+	return fmt.Sprintf("%s(%s)", CheckTypePrometheusQuery, c.Query)
+}
+
+func (c *PrometheusQueryCheck) Run(ctx context.Context, cc CheckContext) (CheckResult, error) {
+	promAPI, err := cc.Prometheus()
+	if err != nil {
+		return CheckResult{Reason: fmt.Sprintf("no Prometheus client: %v", err), RequeueAfter: 30 * time.Second}, nil
+	}
+	result, warnings, err := promAPI.Query(ctx, c.Query, time.Now())
+	if err != nil {
+		return CheckResult{Reason: fmt.Sprintf("query failed: %v", err), RequeueAfter: 30 * time.Second}, nil
+	}
+	if len(warnings) > 0 {
+		log.Info(fmt.Sprintf("Warnings: %v\n", warnings))
+	}
+	samples := 0
+	switch value := result.(type) {
+	case model.Vector:
+		samples = len(value)
+	case *model.Scalar:
+		samples = 1
+	case model.Matrix:
+		samples = len(value)
+	}
+	if samples == 0 {
+		return CheckResult{Reason: "query returned no samples"}, nil
+	}
+	return CheckResult{Passed: true, Reason: fmt.Sprintf("query returned %d samples", samples)}, nil
+}
+
+func defaultString(s, def string) string {
+	if s == "" {
+		return def
+	}
+	return s
+}
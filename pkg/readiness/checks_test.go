@@ -0,0 +1,318 @@
+package readiness
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	configv1 "github.com/openshift/api/config/v1"
+	"github.com/prometheus/client_golang/api"
+	promv1 "github.com/prometheus/client_golang/api/prometheus/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sruntime "k8s.io/apimachinery/pkg/runtime"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/openshift/configure-alertmanager-operator/config"
+)
+
+func newFakeClient(objects ...client.Object) client.Client {
+	scheme := k8sruntime.NewScheme()
+	utilruntime.Must(corev1.AddToScheme(scheme))
+	utilruntime.Must(batchv1.AddToScheme(scheme))
+	utilruntime.Must(configv1.AddToScheme(scheme))
+	return fake.NewClientBuilder().WithScheme(scheme).WithObjects(objects...).Build()
+}
+
+func readinessJob(active, succeeded int32) *batchv1.Job {
+	return &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{Namespace: config.OperatorNamespace, Name: jobName},
+		Status:     batchv1.JobStatus{Active: active, Succeeded: succeeded},
+	}
+}
+
+func operatorConfigMap(checks string) *corev1.ConfigMap {
+	return &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Namespace: config.OperatorNamespace, Name: config.OperatorName},
+		Data:       map[string]string{checksConfigMapKey: checks},
+	}
+}
+
+func runCheck(t *testing.T, check Check, cc CheckContext) CheckResult {
+	result, err := check.Run(context.TODO(), cc)
+	if err != nil {
+		t.Fatalf("%s: unexpected error: %v", check.Name(), err)
+	}
+	return result
+}
+
+func TestJobCheck(t *testing.T) {
+	tests := []struct {
+		name         string
+		job          *batchv1.Job
+		passed       bool
+		requeueAfter time.Duration
+	}{
+		{name: "missing", passed: false, requeueAfter: 5 * time.Minute},
+		{name: "active", job: readinessJob(1, 0), passed: false, requeueAfter: 10 * time.Second},
+		{name: "succeeded", job: readinessJob(0, 1), passed: true},
+		{name: "failed", job: readinessJob(0, 0), passed: true},
+	}
+	for _, tt := range tests {
+		c := newFakeClient()
+		if tt.job != nil {
+			c = newFakeClient(tt.job)
+		}
+		result := runCheck(t, &JobCheck{Namespace: config.OperatorNamespace, JobName: jobName}, CheckContext{Client: c})
+		if result.Passed != tt.passed || result.RequeueAfter != tt.requeueAfter {
+			t.Errorf("%s: got %+v", tt.name, result)
+		}
+	}
+}
+
+func TestClusterVersionAvailableCheck(t *testing.T) {
+	for _, status := range []configv1.ConditionStatus{configv1.ConditionTrue, configv1.ConditionFalse} {
+		c := newFakeClient(&configv1.ClusterVersion{
+			ObjectMeta: metav1.ObjectMeta{Name: "version"},
+			Status: configv1.ClusterVersionStatus{Conditions: []configv1.ClusterOperatorStatusCondition{
+				{Type: configv1.OperatorAvailable, Status: status},
+			}},
+		})
+		result := runCheck(t, &ClusterVersionAvailableCheck{}, CheckContext{Client: c})
+		if result.Passed != (status == configv1.ConditionTrue) {
+			t.Errorf("Available=%s: got %+v", status, result)
+		}
+	}
+}
+
+func TestClusterOperatorsNotDegradedCheck(t *testing.T) {
+	co := func(name string, degraded configv1.ConditionStatus) *configv1.ClusterOperator {
+		return &configv1.ClusterOperator{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Status: configv1.ClusterOperatorStatus{Conditions: []configv1.ClusterOperatorStatusCondition{
+				{Type: configv1.OperatorDegraded, Status: degraded},
+			}},
+		}
+	}
+	c := newFakeClient(co("ingress", configv1.ConditionFalse), co("authentication", configv1.ConditionTrue))
+
+	tests := []struct {
+		names  []string
+		passed bool
+	}{
+		{names: []string{"ingress"}, passed: true},
+		{names: []string{"ingress", "authentication"}, passed: false},
+		{names: []string{"missing"}, passed: false},
+		{names: nil, passed: false},
+	}
+	for _, tt := range tests {
+		result := runCheck(t, &ClusterOperatorsNotDegradedCheck{Names: tt.names}, CheckContext{Client: c})
+		if result.Passed != tt.passed {
+			t.Errorf("%v: got %+v", tt.names, result)
+		}
+	}
+}
+
+func TestConfigMapFlagCheck(t *testing.T) {
+	check := &ConfigMapFlagCheck{Namespace: config.OperatorNamespace, ConfigMapName: "flags", Key: "ready", Value: "true"}
+	if runCheck(t, check, CheckContext{Client: newFakeClient()}).Passed {
+		t.Errorf("passed without the ConfigMap")
+	}
+	for value, passed := range map[string]bool{"true": true, "false": false} {
+		c := newFakeClient(&corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Namespace: config.OperatorNamespace, Name: "flags"},
+			Data:       map[string]string{"ready": value},
+		})
+		if runCheck(t, check, CheckContext{Client: c}).Passed != passed {
+			t.Errorf("ready=%s: expected passed=%t", value, passed)
+		}
+	}
+}
+
+func TestPrometheusQueryCheck(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		result := `[]`
+		if r.FormValue("query") == "up" {
+			result = `[{"metric":{"job":"apiserver"},"value":[1700000000,"1"]}]`
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"status":"success","data":{"resultType":"vector","result":%s}}`, result)
+	}))
+	defer server.Close()
+
+	promClient, err := api.NewClient(api.Config{Address: server.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cc := CheckContext{Prometheus: func() (promv1.API, error) { return promv1.NewAPI(promClient), nil }}
+
+	if !runCheck(t, &PrometheusQueryCheck{Query: "up"}, cc).Passed {
+		t.Errorf("query with samples didn't pass")
+	}
+	if runCheck(t, &PrometheusQueryCheck{Query: "absent"}, cc).Passed {
+		t.Errorf("query without samples passed")
+	}
+
+	unreachable := CheckContext{Prometheus: func() (promv1.API, error) { return nil, fmt.Errorf("no token") }}
+	if result := runCheck(t, &PrometheusQueryCheck{Query: "up"}, unreachable); result.Passed || result.RequeueAfter == 0 {
+		t.Errorf("unreachable Prometheus: got %+v", result)
+	}
+}
+
+func TestLoadChecks(t *testing.T) {
+	tests := []struct {
+		name     string
+		objects  []client.Object
+		mode     string
+		expected []string
+	}{
+		{
+			name:     "no configMap",
+			mode:     ChecksModeAll,
+			expected: []string{"Job(openshift-monitoring/osd-cluster-ready)"},
+		},
+		{
+			name:     "invalid configuration",
+			objects:  []client.Object{operatorConfigMap("checks:\n- type: Unknown\n")},
+			mode:     ChecksModeAll,
+			expected: []string{"Job(openshift-monitoring/osd-cluster-ready)"},
+		},
+		{
+			name:     "unknown field",
+			objects:  []client.Object{operatorConfigMap("checks:\n- type: Job\n  nmae: typo\n")},
+			mode:     ChecksModeAll,
+			expected: []string{"Job(openshift-monitoring/osd-cluster-ready)"},
+		},
+		{
+			name: "configured",
+			objects: []client.Object{operatorConfigMap(`mode: any
+checks:
+- type: ClusterVersionAvailable
+- type: ClusterOperatorsNotDegraded
+  names: [ingress]
+- type: ConfigMapFlag
+  name: flags
+  key: ready
+- type: PrometheusQuery
+  query: up
+`)},
+			mode:     ChecksModeAny,
+			expected: []string{"ClusterVersionAvailable", "ClusterOperatorsNotDegraded[ingress]", "ConfigMapFlag(openshift-monitoring/flags:ready)", "PrometheusQuery(up)"},
+		},
+	}
+	for _, tt := range tests {
+		mode, checks := loadChecks(context.TODO(), newFakeClient(tt.objects...))
+		names := []string{}
+		for _, check := range checks {
+			names = append(names, check.Name())
+		}
+		if mode != tt.mode || fmt.Sprint(names) != fmt.Sprint(tt.expected) {
+			t.Errorf("%s: got %s %v", tt.name, mode, names)
+		}
+	}
+}
+
+// staticCheck returns a fixed result.
+type staticCheck CheckResult
+
+func (c staticCheck) Name() string { return c.Reason }
+
+func (c staticCheck) Run(context.Context, CheckContext) (CheckResult, error) {
+	return CheckResult(c), nil
+}
+
+func TestRunChecks(t *testing.T) {
+	pass := staticCheck{Passed: true, Reason: "pass"}
+	slow := staticCheck{Reason: "slow", RequeueAfter: 5 * time.Minute}
+	fast := staticCheck{Reason: "fast", RequeueAfter: 10 * time.Second}
+	noDelay := staticCheck{Reason: "default"}
+
+	tests := []struct {
+		name         string
+		mode         string
+		checks       []Check
+		passed       bool
+		requeueAfter time.Duration
+	}{
+		{name: "all passed", mode: ChecksModeAll, checks: []Check{pass, pass}, passed: true},
+		{name: "all, one failed", mode: ChecksModeAll, checks: []Check{pass, slow}, requeueAfter: 5 * time.Minute},
+		{name: "all, shortest delay", mode: ChecksModeAll, checks: []Check{slow, fast}, requeueAfter: 10 * time.Second},
+		{name: "any passed", mode: ChecksModeAny, checks: []Check{slow, pass}, passed: true},
+		{name: "none passed", mode: ChecksModeAny, checks: []Check{slow, noDelay}, requeueAfter: defaultCheckRequeueDelay},
+	}
+	for _, tt := range tests {
+		result, err := runChecks(context.TODO(), CheckContext{}, tt.mode, tt.checks)
+		if err != nil || result.Passed != tt.passed || result.RequeueAfter != tt.requeueAfter {
+			t.Errorf("%s: got %+v, %v", tt.name, result, err)
+		}
+	}
+}
+
+func TestIsReady_Checks(t *testing.T) {
+	flags := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Namespace: config.OperatorNamespace, Name: "flags"},
+		Data:       map[string]string{"ready": "true"},
+	}
+	impl := &Impl{Client: newFakeClient(flags, operatorConfigMap("checks:\n- type: ConfigMapFlag\n  name: flags\n  key: ready\n"))}
+	ready, err := impl.IsReady()
+	if err != nil || !ready {
+		t.Errorf("expected ready, got %t, %v", ready, err)
+	}
+	if impl.Result() != (reconcile.Result{}) {
+		t.Errorf("unexpected result %+v", impl.Result())
+	}
+
+	// Not ready: the cluster age can't be determined outside a cluster, so we requeue quickly.
+	impl = &Impl{Client: newFakeClient(operatorConfigMap("checks:\n- type: ConfigMapFlag\n  name: flags\n  key: ready\n"))}
+	ready, err = impl.IsReady()
+	if err != nil || ready {
+		t.Errorf("expected not ready, got %t, %v", ready, err)
+	}
+	if !impl.Result().Requeue {
+		t.Errorf("expected a requeue, got %+v", impl.Result())
+	}
+}
+
+func TestIsReady_Reasons(t *testing.T) {
+	young := staticAgeSource{created: time.Now()}
+	old := staticAgeSource{created: time.Now().Add(-24 * time.Hour)}
+	tests := []struct {
+		name       string
+		objects    []client.Object
+		age        ClusterAgeSource
+		wantReady  bool
+		wantReason string
+	}{
+		{name: "job succeeded", objects: []client.Object{readinessJob(0, 1)}, age: young, wantReady: true, wantReason: ReasonJobSucceeded},
+		{name: "job failed", objects: []client.Object{readinessJob(0, 0)}, age: young, wantReady: true, wantReason: ReasonJobFailed},
+		{name: "job active", objects: []client.Object{readinessJob(1, 0)}, age: young, wantReason: ReasonChecksPending},
+		{name: "cluster too old", objects: []client.Object{readinessJob(1, 0)}, age: old, wantReady: true, wantReason: ReasonClusterTooOld},
+		{name: "cluster age unknown", objects: []client.Object{readinessJob(1, 0)}, age: staticAgeSource{err: fmt.Errorf("down")}, wantReason: ReasonClusterAgeUnknown},
+		{
+			name:       "several checks passed",
+			objects:    []client.Object{readinessJob(0, 1), operatorConfigMap("checks:\n- type: Job\n  name: osd-cluster-ready\n- type: ClusterOperatorsNotDegraded\n")},
+			age:        young,
+			wantReady:  true,
+			wantReason: ReasonChecksPassed,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			impl := &Impl{Client: newFakeClient(tt.objects...), AgeSources: []ClusterAgeSource{tt.age}}
+			ready, err := impl.IsReady()
+			if err != nil || ready != tt.wantReady {
+				t.Errorf("expected ready %t, got %t, %v", tt.wantReady, ready, err)
+			}
+			if impl.reason != tt.wantReason {
+				t.Errorf("expected reason %s, got %s", tt.wantReason, impl.reason)
+			}
+		})
+	}
+}
@@ -0,0 +1,136 @@
+package readiness
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	configv1 "github.com/openshift/api/config/v1"
+	promv1 "github.com/prometheus/client_golang/api/prometheus/v1"
+	"github.com/prometheus/common/model"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// The first requeue after failing to determine the cluster age; doubled on each further failure.
+	clusterAgeBackoffInitial = time.Second
+	// The longest we'll wait between attempts to determine the cluster age.
+	clusterAgeBackoffMax = 5 * time.Minute
+
+	// How failures to determine the cluster age are labelled in readiness_check_errors_total
+	clusterAgeCheckName = "ClusterAge"
+)
+
+// ClusterAgeSource determines when the cluster was created.
+type ClusterAgeSource interface {
+	Name() string
+	CreationTime(ctx context.Context) (time.Time, error)
+}
+
+// ClusterVersionHistorySource reads the cluster creation time from the ClusterVersion's update
+// history. The oldest entry is the initial installation: we use its completion time, or its start
+// time if the installation hasn't completed yet.
+type ClusterVersionHistorySource struct {
+	Client client.Client
+}
+
+func (s *ClusterVersionHistorySource) Name() string {
+	return "ClusterVersion history"
+}
+
+func (s *ClusterVersionHistorySource) CreationTime(ctx context.Context) (time.Time, error) {
+	version := &configv1.ClusterVersion{}
+	if err := s.Client.Get(ctx, types.NamespacedName{Name: "version"}, version); err != nil {
+		return time.Time{}, fmt.Errorf("failed to retrieve ClusterVersion: %w", err)
+	}
+	history := version.Status.History
+	if len(history) == 0 {
+		return time.Time{}, fmt.Errorf("ClusterVersion has no update history")
+	}
+	// History is ordered newest first.
+	initial := history[len(history)-1]
+	if initial.CompletionTime != nil && !initial.CompletionTime.IsZero() {
+		return initial.CompletionTime.Time, nil
+	}
+	if initial.StartedTime.IsZero() {
+		return time.Time{}, fmt.Errorf("initial ClusterVersion history entry for %s has no start time", initial.Version)
+	}
+	return initial.StartedTime.Time, nil
+}
+
+// PrometheusSource reads the cluster creation time from the `cluster_version{type="initial"}` metric.
+type PrometheusSource struct {
+	// Prometheus returns the API client to query.
+	Prometheus func() (promv1.API, error)
+}
+
+func (s *PrometheusSource) Name() string {
+	return "Prometheus"
+}
+
+func (s *PrometheusSource) CreationTime(ctx context.Context) (time.Time, error) {
+	promAPI, err := s.Prometheus()
+	if err != nil {
+		return time.Time{}, fmt.Errorf("couldn't get prometheus API: %w", err)
+	}
+	when := time.Now()
+	// For testing, do something like this, subtracting the number of hours
+	// since you disabled CVO:
+	// when := time.Now().Add(-32*time.Hour)
+	result, warnings, err := promAPI.Query(ctx, "cluster_version{type=\"initial\"}", when)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("error querying Prometheus: %w", err)
+	}
+	if len(warnings) > 0 {
+		log.Info(fmt.Sprintf("Warnings: %v\n", warnings))
+	}
+
+	log.Info(fmt.Sprintf("DEBUG: Result of type %s:\n%s\n", result.Type().String(), result.String()))
+	resultVec, ok := result.(model.Vector)
+	if !ok {
+		return time.Time{}, fmt.Errorf("unexpected prometheus result type %s", result.Type().String())
+	}
+	earliest := time.Time{}
+	for i := 0; i < resultVec.Len(); i++ {
+		thisTime := time.Unix(int64(resultVec[i].Value), 0)
+		if earliest.IsZero() || thisTime.Before(earliest) {
+			earliest = thisTime
+		}
+	}
+	if earliest.IsZero() {
+		return time.Time{}, fmt.Errorf("failed to determine cluster birth time from prometheus %s result %v", result.Type().String(), result.String())
+	}
+	return earliest, nil
+}
+
+// clusterCreationTime asks each source in turn, returning the first answer.
+func clusterCreationTime(ctx context.Context, sources []ClusterAgeSource) (time.Time, error) {
+	if len(sources) == 0 {
+		return time.Time{}, fmt.Errorf("no cluster age sources configured")
+	}
+	var errs []error
+	for _, source := range sources {
+		created, err := source.CreationTime(ctx)
+		if err == nil {
+			log.Info("DEBUG: Determined cluster creation time", "source", source.Name(), "created", created.UTC())
+			return created, nil
+		}
+		log.Info("DEBUG: Cluster age source failed", "source", source.Name(), "error", err.Error())
+		errs = append(errs, fmt.Errorf("%s: %w", source.Name(), err))
+	}
+	return time.Time{}, fmt.Errorf("all cluster age sources failed: %v", errs)
+}
+
+// clusterAgeBackoff returns how long to wait before the next attempt to determine the cluster age,
+// after the given number of consecutive failures.
+func clusterAgeBackoff(failures int) time.Duration {
+	delay := clusterAgeBackoffInitial
+	for i := 1; i < failures; i++ {
+		delay *= 2
+		if delay >= clusterAgeBackoffMax {
+			return clusterAgeBackoffMax
+		}
+	}
+	return delay
+}
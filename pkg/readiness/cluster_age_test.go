@@ -0,0 +1,174 @@
+package readiness
+
+import (
+	"context"
+	"encoding/pem"
+	"fmt"
+	"testing"
+	"time"
+
+	configv1 "github.com/openshift/api/config/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func clusterVersion(history ...configv1.UpdateHistory) *configv1.ClusterVersion {
+	return &configv1.ClusterVersion{
+		ObjectMeta: metav1.ObjectMeta{Name: "version"},
+		Status:     configv1.ClusterVersionStatus{History: history},
+	}
+}
+
+// staticAgeSource is a ClusterAgeSource with a canned answer.
+type staticAgeSource struct {
+	created time.Time
+	err     error
+	calls   *int
+}
+
+func (s staticAgeSource) Name() string { return "static" }
+
+func (s staticAgeSource) CreationTime(context.Context) (time.Time, error) {
+	if s.calls != nil {
+		*s.calls++
+	}
+	return s.created, s.err
+}
+
+func TestClusterVersionHistorySource(t *testing.T) {
+	installed := metav1.NewTime(time.Unix(1600000000, 0))
+	completed := metav1.NewTime(time.Unix(1600003600, 0))
+	upgraded := metav1.NewTime(time.Unix(1700000000, 0))
+
+	tests := []struct {
+		name    string
+		version *configv1.ClusterVersion
+		want    time.Time
+		wantErr bool
+	}{
+		{name: "missing ClusterVersion", wantErr: true},
+		{name: "no history", version: clusterVersion(), wantErr: true},
+		{
+			name:    "installing",
+			version: clusterVersion(configv1.UpdateHistory{State: configv1.PartialUpdate, StartedTime: installed}),
+			want:    installed.Time,
+		},
+		{
+			name: "upgraded",
+			version: clusterVersion(
+				configv1.UpdateHistory{State: configv1.CompletedUpdate, StartedTime: upgraded, CompletionTime: &upgraded},
+				configv1.UpdateHistory{State: configv1.CompletedUpdate, StartedTime: installed, CompletionTime: &completed},
+			),
+			want: completed.Time,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fakeClient := newFakeClient()
+			if tt.version != nil {
+				fakeClient = newFakeClient(tt.version)
+			}
+			source := &ClusterVersionHistorySource{Client: fakeClient}
+			got, err := source.CreationTime(context.TODO())
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if !got.Equal(tt.want) {
+				t.Errorf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestClusterCreationTime_Fallback(t *testing.T) {
+	created := time.Unix(1600000000, 0)
+	calls := 0
+	got, err := clusterCreationTime(context.TODO(), []ClusterAgeSource{
+		staticAgeSource{err: fmt.Errorf("down")},
+		staticAgeSource{created: created},
+		staticAgeSource{calls: &calls},
+	})
+	if err != nil || !got.Equal(created) {
+		t.Errorf("expected %v, got %v, %v", created, got, err)
+	}
+	if calls != 0 {
+		t.Errorf("sources after the first answer should not be asked")
+	}
+
+	if _, err := clusterCreationTime(context.TODO(), []ClusterAgeSource{staticAgeSource{err: fmt.Errorf("down")}}); err == nil {
+		t.Errorf("expected an error when every source fails")
+	}
+}
+
+func TestSetClusterCreationTime_PrefersClusterVersion(t *testing.T) {
+	// Prometheus is unreachable, as it may be during install.
+	t.Setenv(prometheusURLKey, "https://127.0.0.1:1")
+	t.Setenv(prometheusCAFileKey, writeFile(t, "service-ca.crt", ""))
+	installed := metav1.NewTime(time.Unix(1600000000, 0))
+	impl := &Impl{Client: newFakeClient(clusterVersion(configv1.UpdateHistory{State: configv1.CompletedUpdate, StartedTime: installed, CompletionTime: &installed}))}
+	if err := impl.setClusterCreationTime(); err != nil {
+		t.Fatal(err)
+	}
+	if !impl.clusterCreationTime.Equal(installed.Time) {
+		t.Errorf("unexpected cluster creation time %v", impl.clusterCreationTime)
+	}
+}
+
+func TestSetClusterCreationTime_PrometheusFallback(t *testing.T) {
+	token := "token"
+	server := newPrometheusStandIn(t, &token)
+	t.Setenv(prometheusURLKey, server.URL)
+	t.Setenv(prometheusCAFileKey, writeFile(t, "service-ca.crt", string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}))))
+	t.Setenv(prometheusTokenFileKey, writeFile(t, "token", token))
+
+	// No ClusterVersion, so the history source fails.
+	impl := &Impl{Client: newFakeClient()}
+	if err := impl.setClusterCreationTime(); err != nil {
+		t.Fatal(err)
+	}
+	if !impl.clusterCreationTime.Equal(time.Unix(1600000000, 0)) {
+		t.Errorf("unexpected cluster creation time %v", impl.clusterCreationTime)
+	}
+}
+
+func TestClusterAgeBackoff(t *testing.T) {
+	for failures, want := range map[int]time.Duration{
+		1:  time.Second,
+		2:  2 * time.Second,
+		5:  16 * time.Second,
+		9:  256 * time.Second,
+		10: clusterAgeBackoffMax,
+		50: clusterAgeBackoffMax,
+	} {
+		if got := clusterAgeBackoff(failures); got != want {
+			t.Errorf("clusterAgeBackoff(%d) = %v, want %v", failures, got, want)
+		}
+	}
+}
+
+func TestIsReady_ClusterAgeBackoff(t *testing.T) {
+	calls := 0
+	impl := &Impl{
+		Client:     newFakeClient(operatorConfigMap("checks:\n- type: ConfigMapFlag\n  name: flags\n  key: ready\n")),
+		AgeSources: []ClusterAgeSource{staticAgeSource{err: fmt.Errorf("down"), calls: &calls}},
+	}
+	for _, want := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second} {
+		if ready, err := impl.IsReady(); err != nil || ready {
+			t.Fatalf("expected not ready, got %t, %v", ready, err)
+		}
+		if got := impl.Result().RequeueAfter; got != want {
+			t.Errorf("expected requeue after %v, got %v", want, got)
+		}
+	}
+
+	// Once the age is known the backoff resets and we wait on the checks instead.
+	impl.AgeSources = []ClusterAgeSource{staticAgeSource{created: time.Now()}}
+	if ready, err := impl.IsReady(); err != nil || ready {
+		t.Fatalf("expected not ready, got %t, %v", ready, err)
+	}
+	if impl.ageFailures != 0 {
+		t.Errorf("expected the backoff to reset, got %d failures", impl.ageFailures)
+	}
+	if calls != 3 {
+		t.Errorf("expected 3 attempts, got %d", calls)
+	}
+}
@@ -4,25 +4,18 @@ package readiness
 
 import (
 	"context"
-	"crypto/tls"
 	"fmt"
-	"net"
-	"net/http"
-	"net/url"
 	"os"
 	"strconv"
 	"time"
 
-	"github.com/openshift/configure-alertmanager-operator/config"
-	"github.com/prometheus/client_golang/api"
 	promv1 "github.com/prometheus/client_golang/api/prometheus/v1"
-	"github.com/prometheus/common/model"
-	batchv1 "k8s.io/api/batch/v1"
-	"k8s.io/apimachinery/pkg/api/errors"
-	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/openshift/configure-alertmanager-operator/pkg/metrics"
 )
 
 var log = logf.Log.WithName("readiness")
@@ -36,9 +29,20 @@ type Impl struct {
 	// ready indicates whether the cluster is considered ready. Once this is true,
 	// Check() is a no-op.
 	ready bool
+	// AgeSources are asked, in order, for the cluster creation time. If unset, the ClusterVersion
+	// history is used, falling back to Prometheus.
+	AgeSources []ClusterAgeSource
 	// clusterCreationTime caches the birth time of the cluster so we only have to
-	// query prometheus once.
+	// look it up once.
 	clusterCreationTime time.Time
+	// ageFailures counts consecutive failures to determine the cluster creation time, for backoff.
+	ageFailures int
+	// reason is why the cluster was last considered ready or not, as reported by the metrics.
+	reason string
+	// Recorder, if set, records Events about readiness overrides.
+	Recorder record.EventRecorder
+	// overrideKey identifies the last readiness override seen, so Events are only recorded on changes.
+	overrideKey string
 	// promAPI is a handle to the prometheus API client
 	promAPI promv1.API
 }
@@ -60,62 +64,98 @@ const (
 	// By default, ignore clusters older than two hours
 	maxClusterAgeDefault = 2 * 60
 
+	// The Job the default readiness check waits for
 	jobName = "osd-cluster-ready"
 )
 
-// IsReady deals with the osd-cluster-ready Job.
+// Reasons reported by the cluster_ready_reason metric
+const (
+	ReasonJobSucceeded      = "JobSucceeded"
+	ReasonJobFailed         = "JobFailed"
+	ReasonChecksPassed      = "ChecksPassed"
+	ReasonClusterTooOld     = "ClusterTooOld"
+	ReasonChecksPending     = "ChecksPending"
+	ReasonClusterAgeUnknown = "ClusterAgeUnknown"
+	ReasonCheckError        = "CheckError"
+	ReasonOverrideReady     = "OverrideReady"
+	ReasonOverrideNotReady  = "OverrideNotReady"
+)
+
+// IsReady runs the readiness checks configured in the operator configMap, by default waiting for
+// the osd-cluster-ready Job, unless an unexpired readiness override annotation is set on that configMap.
 // Sets:
 //   - impl.Ready:
 //     true if:
 //   - a previous check has already succeeded (a cluster can't become un-ready once it's ready);
-//   - an osd-cluster-ready Job has completed; or
+//   - the readiness checks passed; or
 //   - the cluster is older than maxClusterAgeMinutes
 //     false otherwise.
 //   - impl.Result: If the caller's reconcile is otherwise successful, it
 //     should return the given Result.
 //   - impl.clusterCreationTime: If it is necessary to check the age of the cluster, this is set so
-//     we only have to query prometheus once.
+//     we only have to look it up once.
 func (impl *Impl) IsReady() (bool, error) {
+	ctx := context.TODO()
+
+	// A manual override trumps everything, including a cached positive readiness.
+	ov, err := impl.activeOverride(ctx, time.Now())
+	if err != nil {
+		return false, err
+	}
+	if ov != nil {
+		ready := ov.value == OverrideReady
+		reason := ReasonOverrideNotReady
+		if ready {
+			reason = ReasonOverrideReady
+		}
+		log.Info(fmt.Sprintf("INFO: Readiness overridden to %s until %v.", ov.value, ov.expires.UTC()))
+		// Don't cache the override; come back when it expires.
+		impl.reason = reason
+		metrics.UpdateReadinessMetrics(ready, reason)
+		impl.result = reconcile.Result{Requeue: true, RequeueAfter: time.Until(ov.expires)}
+		return ready, nil
+	}
+
 	if impl.ready {
 		log.Info("DEBUG: Using cached positive cluster readiness.")
+		impl.result = reconcile.Result{}
+		metrics.UpdateReadinessMetrics(impl.ready, impl.reason)
 		return impl.ready, nil
 	}
 
 	// Default Result
 	impl.result = reconcile.Result{}
 
-	// Readiness job part 1: Grab it, and short out if it has finished (success or failure).
-	job := &batchv1.Job{}
-	found := true
-	if err := impl.Client.Get(context.TODO(), types.NamespacedName{Namespace: config.OperatorNamespace, Name: jobName}, job); err != nil {
-		if !errors.IsNotFound(err) {
-			// If we couldn't query k8s, it is fatal for this iteration of the reconcile
-			return false, fmt.Errorf("failed to retrieve %s Job: %w", jobName, err)
-		}
-		found = false
-	}
-	// If the job completed, we're done, and we don't need to bother with the age check.
-	if found && job.Status.Active == 0 {
-		var msg string
-		if job.Status.Succeeded > 0 {
-			msg = fmt.Sprintf("INFO: Found a succeeded %s Job.", jobName)
-		} else {
-			msg = fmt.Sprintf("INFO: Found failed %s Job. Declaring ready.", jobName)
-		}
-		log.Info(msg)
-		impl.ready = true
+	// Readiness checks part 1: run them, and short out if they passed.
+	mode, checks := loadChecks(ctx, impl.Client)
+	checkResult, err := runChecks(ctx, CheckContext{Client: impl.Client, Prometheus: impl.prometheus}, mode, checks)
+	if err != nil {
+		// If we couldn't query k8s, it is fatal for this iteration of the reconcile
+		impl.setReady(false, ReasonCheckError)
+		return false, err
+	}
+	// If the checks passed, we're done, and we don't need to bother with the age check.
+	if checkResult.Passed {
+		log.Info(fmt.Sprintf("INFO: Readiness checks passed (%s).", checkResult.Reason))
+		impl.setReady(true, defaultString(checkResult.Code, ReasonChecksPassed))
 		return impl.ready, nil
 	}
 
 	// Cluster age: short out if the cluster is older than the configured value
 	if err := impl.setClusterCreationTime(); err != nil {
-		log.Error(err, "Failed to determine cluster creation time")
-		// If we failed to query prometheus, the cluster isn't ready.
+		// If we couldn't determine the cluster age, the cluster isn't ready.
 		// We want the main Reconcile loop to proceed, so don't return an error; but
-		// we want to requeue rapidly so we can keep checking for cluster birth.
-		impl.result = reconcile.Result{Requeue: true, RequeueAfter: time.Second}
+		// we want to requeue so we can keep checking for cluster birth, backing off
+		// in case the sources are down for a while.
+		impl.ageFailures++
+		delay := clusterAgeBackoff(impl.ageFailures)
+		log.Error(err, "Failed to determine cluster creation time", "attempts", impl.ageFailures, "requeueAfter", delay)
+		metrics.IncReadinessCheckErrors(clusterAgeCheckName)
+		impl.result = reconcile.Result{Requeue: true, RequeueAfter: delay}
+		impl.setReady(false, ReasonClusterAgeUnknown)
 		return false, nil
 	}
+	impl.ageFailures = 0
 	maxClusterAge, err := getEnvInt(maxClusterAgeKey, maxClusterAgeDefault)
 	if err != nil {
 		// This is likely to result in a hot loop :(
@@ -123,68 +163,49 @@ func (impl *Impl) IsReady() (bool, error) {
 	}
 	if impl.clusterTooOld(maxClusterAge) {
 		log.Info(fmt.Sprintf("INFO: Cluster is older than %d minutes. Ignoring health check.", maxClusterAge))
-		impl.ready = true
+		impl.setReady(true, ReasonClusterTooOld)
 		return impl.ready, nil
 	}
 
-	// Readiness job part 2: Either the job is still running or doesn't yet exist. We do
-	// these after the age check because they declare "not ready" and requeue, neither of
-	// which we want to do if the cluster is too old.
-
-	if found {
-		// The Job is still running (we checked Active == 0 above).
-		// Requeue with a short delay. We don't want to thrash, but we want to poll the
-		// Job fairly frequently so we configure alerts promptly once it finishes.
-		delay := 10 * time.Second
-		log.Info(fmt.Sprintf("INFO: Found an Active %s Job. Will requeue after %v.", jobName, delay))
-		impl.result = reconcile.Result{Requeue: true, RequeueAfter: delay}
-		return false, nil
-	}
-
-	// The Job doesn't exist -- requeue with a delay and keep looking for it. The delay
-	// here can be longish because the readiness job will take a while to complete once
-	// it does start.
-	delay := 5 * time.Minute
-	log.Info(fmt.Sprintf("INFO: No %s Job found; requeueing after %v to wait for it.", jobName, delay))
-	impl.result = reconcile.Result{Requeue: true, RequeueAfter: delay}
+	// Readiness checks part 2: they didn't pass. We do this after the age check because it
+	// declares "not ready" and requeues, neither of which we want to do if the cluster is too old.
+	log.Info(fmt.Sprintf("INFO: Readiness checks did not pass (%s). Will requeue after %v.", checkResult.Reason, checkResult.RequeueAfter))
+	impl.result = reconcile.Result{Requeue: true, RequeueAfter: checkResult.RequeueAfter}
+	impl.setReady(false, ReasonChecksPending)
 	return false, nil
 }
 
+// setReady records the readiness determination and reports it in the metrics.
+func (impl *Impl) setReady(ready bool, reason string) {
+	impl.ready = ready
+	impl.reason = reason
+	metrics.UpdateReadinessMetrics(ready, reason)
+}
+
 func (impl *Impl) Result() reconcile.Result {
 	return impl.result
 }
 
+// prometheus returns the prometheus API client for the readiness checks, creating it on first use.
+func (impl *Impl) prometheus() (promv1.API, error) {
+	if impl.promAPI == nil {
+		if err := impl.setPromAPI(); err != nil {
+			return nil, err
+		}
+	}
+	return impl.promAPI, nil
+}
+
 func (impl *Impl) setPromAPI() error {
-	rawToken, err := os.ReadFile("/var/run/secrets/kubernetes.io/serviceaccount/token")
+	cfg, err := prometheusConfigFromEnv()
 	if err != nil {
-		return fmt.Errorf("couldn't read token file: %w", err)
-	}
-
-	client, err := api.NewClient(api.Config{
-		Address: "https://prometheus-k8s.openshift-monitoring.svc:9091",
-		RoundTripper: &http.Transport{
-			Proxy: func(request *http.Request) (*url.URL, error) {
-				request.Header.Add("Authorization", "Bearer "+string(rawToken))
-				return http.ProxyFromEnvironment(request)
-			},
-			DialContext: (&net.Dialer{
-				Timeout:   30 * time.Second,
-				KeepAlive: 30 * time.Second,
-			}).DialContext,
-			TLSClientConfig: &tls.Config{
-				MinVersion:         tls.VersionTLS12,
-			        // disable "G402 (CWE-295): TLS InsecureSkipVerify set true."
-			        // #nosec G402
-				InsecureSkipVerify: true,
-			},
-			TLSHandshakeTimeout: 10 * time.Second,
-		},
-	})
+		return err
+	}
+	promAPI, err := newPrometheusAPI(cfg)
 	if err != nil {
-		return fmt.Errorf("couldn't configure prometheus client: %w", err)
+		return err
 	}
-
-	impl.promAPI = promv1.NewAPI(client)
+	impl.promAPI = promAPI
 	return nil
 }
 
@@ -194,38 +215,28 @@ func (impl *Impl) setClusterCreationTime() error {
 		return nil
 	}
 
-	if err := impl.setPromAPI(); err != nil {
-		return fmt.Errorf("couldn't get prometheus API: %w", err)
-	}
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
-	when := time.Now()
-	// For testing, do something like this, subtracting the number of hours
-	// since you disabled CVO:
-	// when := time.Now().Add(-32*time.Hour)
-	result, warnings, err := impl.promAPI.Query(ctx, "cluster_version{type=\"initial\"}", when)
+	// The Prometheus request timeout is part of its client configuration.
+	created, err := clusterCreationTime(context.TODO(), impl.clusterAgeSources())
 	if err != nil {
-		return fmt.Errorf("error querying Prometheus: %w", err)
-	}
-	if len(warnings) > 0 {
-		log.Info(fmt.Sprintf("Warnings: %v\n", warnings))
+		return err
 	}
+	impl.clusterCreationTime = created
+	metrics.UpdateClusterCreationTime(created)
+	log.Info(fmt.Sprintf("INFO: Cluster created %v", created.UTC()))
+	return nil
+}
 
-	log.Info(fmt.Sprintf("DEBUG: Result of type %s:\n%s\n", result.Type().String(), result.String()))
-	resultVec := result.(model.Vector)
-	earliest := time.Time{}
-	for i := 0; i < resultVec.Len(); i++ {
-		thisTime := time.Unix(int64(resultVec[i].Value), 0)
-		if earliest.IsZero() || thisTime.Before(earliest) {
-			earliest = thisTime
-		}
+// clusterAgeSources returns the configured AgeSources or, by default, the ClusterVersion history
+// followed by Prometheus.
+func (impl *Impl) clusterAgeSources() []ClusterAgeSource {
+	if impl.AgeSources != nil {
+		return impl.AgeSources
 	}
-	if earliest.IsZero() {
-		return fmt.Errorf("failed to determine cluster birth time from prometheus %s result %v", result.Type().String(), result.String())
+	sources := []ClusterAgeSource{}
+	if impl.Client != nil {
+		sources = append(sources, &ClusterVersionHistorySource{Client: impl.Client})
 	}
-	impl.clusterCreationTime = earliest
-	log.Info(fmt.Sprintf("INFO: Cluster created %v", earliest.UTC()))
-	return nil
+	return append(sources, &PrometheusSource{Prometheus: impl.prometheus})
 }
 
 func (impl *Impl) clusterTooOld(maxAgeMinutes int) bool {
@@ -0,0 +1,120 @@
+package readiness
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/openshift/configure-alertmanager-operator/config"
+	"github.com/openshift/configure-alertmanager-operator/pkg/metrics"
+)
+
+const (
+	// OverrideAnnotation on the operator configMap forces the readiness determination; it must be
+	// accompanied by OverrideExpiresAnnotation.
+	OverrideAnnotation = "configure-alertmanager-operator.managed.openshift.io/readiness-override"
+	// OverrideExpiresAnnotation is the RFC3339 time at which the override stops applying.
+	OverrideExpiresAnnotation = "configure-alertmanager-operator.managed.openshift.io/readiness-override-expires"
+
+	// Values of OverrideAnnotation
+	OverrideReady    = "ready"
+	OverrideNotReady = "not-ready"
+
+	// Event reasons recorded on the operator configMap
+	eventReasonOverrideApplied = "ReadinessOverrideApplied"
+	eventReasonOverrideEnded   = "ReadinessOverrideEnded"
+	eventReasonOverrideInvalid = "ReadinessOverrideInvalid"
+)
+
+// override is a manual readiness determination set by SRE.
+type override struct {
+	value   string
+	expires time.Time
+}
+
+// key identifies the override, so we only record Events when it changes.
+func (o *override) key() string {
+	if o == nil {
+		return ""
+	}
+	return o.value + "@" + o.expires.UTC().Format(time.RFC3339)
+}
+
+// parseOverride reads the override annotations. A nil override and nil error mean there is none.
+func parseOverride(annotations map[string]string) (*override, error) {
+	value, ok := annotations[OverrideAnnotation]
+	if !ok || value == "" {
+		return nil, nil
+	}
+	if value != OverrideReady && value != OverrideNotReady {
+		return nil, fmt.Errorf("%s must be %q or %q, not %q", OverrideAnnotation, OverrideReady, OverrideNotReady, value)
+	}
+	rawExpires, ok := annotations[OverrideExpiresAnnotation]
+	if !ok || rawExpires == "" {
+		return nil, fmt.Errorf("%s requires %s", OverrideAnnotation, OverrideExpiresAnnotation)
+	}
+	expires, err := time.Parse(time.RFC3339, rawExpires)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", OverrideExpiresAnnotation, err)
+	}
+	return &override{value: value, expires: expires}, nil
+}
+
+// activeOverride returns the unexpired override on the operator configMap, if any, recording an
+// Event and updating the metrics whenever it changes.
+func (impl *Impl) activeOverride(ctx context.Context, now time.Time) (*override, error) {
+	cm := &corev1.ConfigMap{}
+	err := impl.Client.Get(ctx, types.NamespacedName{Namespace: config.OperatorNamespace, Name: config.OperatorName}, cm)
+	if err != nil {
+		if !errors.IsNotFound(err) {
+			return nil, fmt.Errorf("failed to retrieve the operator configMap: %w", err)
+		}
+		cm = nil
+	}
+
+	var active *override
+	if cm != nil {
+		ov, err := parseOverride(cm.Annotations)
+		if err != nil {
+			if invalidKey := "invalid:" + err.Error(); invalidKey != impl.overrideKey {
+				log.Info("WARNING: Ignoring invalid readiness override", "Error", err.Error())
+				impl.recordEvent(cm, corev1.EventTypeWarning, eventReasonOverrideInvalid, fmt.Sprintf("Ignoring readiness override: %v", err))
+				impl.overrideKey = invalidKey
+				metrics.UpdateReadinessOverrideMetrics("", time.Time{})
+			}
+			return nil, nil
+		}
+		if ov != nil && ov.expires.After(now) {
+			active = ov
+		}
+	}
+
+	if key := active.key(); key != impl.overrideKey {
+		if active != nil {
+			log.Info("INFO: Applying readiness override", "Override", active.value, "Expires", active.expires.UTC())
+			impl.recordEvent(cm, corev1.EventTypeNormal, eventReasonOverrideApplied, fmt.Sprintf("Cluster forced %s until %s", active.value, active.expires.UTC().Format(time.RFC3339)))
+			metrics.UpdateReadinessOverrideMetrics(active.value, active.expires)
+		} else {
+			if impl.overrideKey != "" {
+				log.Info("INFO: Readiness override ended")
+				impl.recordEvent(cm, corev1.EventTypeNormal, eventReasonOverrideEnded, "Readiness override expired or was removed")
+			}
+			metrics.UpdateReadinessOverrideMetrics("", time.Time{})
+		}
+		impl.overrideKey = key
+	}
+	return active, nil
+}
+
+// recordEvent records an Event on the operator configMap, if there is a recorder and a configMap.
+func (impl *Impl) recordEvent(cm client.Object, eventType, reason, message string) {
+	if impl.Recorder == nil || cm == nil {
+		return
+	}
+	impl.Recorder.Event(cm, eventType, reason, message)
+}
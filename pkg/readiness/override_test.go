@@ -0,0 +1,134 @@
+package readiness
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func overrideConfigMap(value string, expires time.Time) *corev1.ConfigMap {
+	cm := operatorConfigMap("")
+	cm.Annotations = map[string]string{
+		OverrideAnnotation:        value,
+		OverrideExpiresAnnotation: expires.UTC().Format(time.RFC3339),
+	}
+	return cm
+}
+
+func TestParseOverride(t *testing.T) {
+	expires := "2030-01-01T00:00:00Z"
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		want        string
+		wantErr     bool
+	}{
+		{name: "none"},
+		{name: "ready", annotations: map[string]string{OverrideAnnotation: OverrideReady, OverrideExpiresAnnotation: expires}, want: OverrideReady},
+		{name: "not ready", annotations: map[string]string{OverrideAnnotation: OverrideNotReady, OverrideExpiresAnnotation: expires}, want: OverrideNotReady},
+		{name: "unknown value", annotations: map[string]string{OverrideAnnotation: "yes", OverrideExpiresAnnotation: expires}, wantErr: true},
+		{name: "no expiry", annotations: map[string]string{OverrideAnnotation: OverrideReady}, wantErr: true},
+		{name: "invalid expiry", annotations: map[string]string{OverrideAnnotation: OverrideReady, OverrideExpiresAnnotation: "tomorrow"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ov, err := parseOverride(tt.annotations)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("unexpected error %v", err)
+			}
+			got := ""
+			if ov != nil {
+				got = ov.value
+			}
+			if got != tt.want {
+				t.Errorf("expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestIsReady_Override(t *testing.T) {
+	young := []ClusterAgeSource{staticAgeSource{created: time.Now()}}
+	expires := time.Now().Add(time.Hour)
+
+	tests := []struct {
+		name       string
+		objects    []client.Object
+		cached     bool
+		wantReady  bool
+		wantReason string
+		wantEvent  string
+	}{
+		{
+			name:       "forced ready",
+			objects:    []client.Object{readinessJob(1, 0), overrideConfigMap(OverrideReady, expires)},
+			wantReady:  true,
+			wantReason: ReasonOverrideReady,
+			wantEvent:  eventReasonOverrideApplied,
+		},
+		{
+			name:       "forced not ready after being ready",
+			objects:    []client.Object{readinessJob(0, 1), overrideConfigMap(OverrideNotReady, expires)},
+			cached:     true,
+			wantReason: ReasonOverrideNotReady,
+			wantEvent:  eventReasonOverrideApplied,
+		},
+		{
+			name:       "expired",
+			objects:    []client.Object{readinessJob(1, 0), overrideConfigMap(OverrideReady, time.Now().Add(-time.Minute))},
+			wantReason: ReasonChecksPending,
+		},
+		{
+			name:       "invalid",
+			objects:    []client.Object{readinessJob(1, 0), overrideConfigMap("maybe", expires)},
+			wantReason: ReasonChecksPending,
+			wantEvent:  eventReasonOverrideInvalid,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := record.NewFakeRecorder(10)
+			impl := &Impl{Client: newFakeClient(tt.objects...), AgeSources: young, Recorder: recorder, ready: tt.cached}
+			ready, err := impl.IsReady()
+			if err != nil || ready != tt.wantReady {
+				t.Errorf("expected ready %t, got %t, %v", tt.wantReady, ready, err)
+			}
+			if impl.reason != tt.wantReason {
+				t.Errorf("expected reason %s, got %s", tt.wantReason, impl.reason)
+			}
+			if tt.wantEvent == "" {
+				if len(recorder.Events) != 0 {
+					t.Errorf("unexpected Event %s", <-recorder.Events)
+				}
+				return
+			}
+			if len(recorder.Events) != 1 {
+				t.Fatalf("expected one Event, got %d", len(recorder.Events))
+			}
+			if event := <-recorder.Events; !strings.Contains(event, tt.wantEvent) {
+				t.Errorf("expected a %s Event, got %s", tt.wantEvent, event)
+			}
+			if tt.wantReason == ReasonOverrideReady || tt.wantReason == ReasonOverrideNotReady {
+				if after := impl.Result().RequeueAfter; after <= 0 || after > time.Hour {
+					t.Errorf("expected a requeue at expiry, got %v", after)
+				}
+				// The override isn't cached.
+				if impl.ready != tt.cached {
+					t.Errorf("override changed the cached readiness")
+				}
+			}
+
+			// Nothing changed, so no new Event.
+			if _, err := impl.IsReady(); err != nil {
+				t.Fatal(err)
+			}
+			if len(recorder.Events) != 0 {
+				t.Errorf("unexpected Event %s", <-recorder.Events)
+			}
+		})
+	}
+}
@@ -0,0 +1,151 @@
+package readiness
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/api"
+	promv1 "github.com/prometheus/client_golang/api/prometheus/v1"
+)
+
+const (
+	// Environment variables configuring the connection to Prometheus
+	prometheusURLKey                = "PROMETHEUS_URL"
+	prometheusCAFileKey             = "PROMETHEUS_CA_FILE"
+	prometheusTokenFileKey          = "PROMETHEUS_TOKEN_FILE"
+	prometheusTimeoutKey            = "PROMETHEUS_TIMEOUT"
+	prometheusInsecureSkipVerifyKey = "PROMETHEUS_INSECURE_SKIP_VERIFY"
+
+	prometheusURLDefault       = "https://prometheus-k8s.openshift-monitoring.svc:9091"
+	prometheusCAFileDefault    = "/var/run/secrets/kubernetes.io/serviceaccount/service-ca.crt"
+	prometheusTokenFileDefault = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+	prometheusTimeoutDefault   = 10 * time.Second
+)
+
+// prometheusConfig describes how to reach the in-cluster Prometheus.
+type prometheusConfig struct {
+	URL string
+	// CAFile is the CA bundle the Prometheus serving certificate is verified against.
+	// In OpenShift the service CA is mounted next to the service account token.
+	CAFile string
+	// TokenFile holds the bearer token. It is re-read for every request, so rotated tokens are picked up.
+	TokenFile string
+	// Timeout bounds every request to Prometheus.
+	Timeout            time.Duration
+	InsecureSkipVerify bool
+}
+
+// prometheusConfigFromEnv reads the Prometheus connection settings from the environment.
+func prometheusConfigFromEnv() (prometheusConfig, error) {
+	cfg := prometheusConfig{
+		URL:       getEnvString(prometheusURLKey, prometheusURLDefault),
+		CAFile:    getEnvString(prometheusCAFileKey, prometheusCAFileDefault),
+		TokenFile: getEnvString(prometheusTokenFileKey, prometheusTokenFileDefault),
+		Timeout:   prometheusTimeoutDefault,
+	}
+	if raw := os.Getenv(prometheusTimeoutKey); raw != "" {
+		timeout, err := time.ParseDuration(raw)
+		if err != nil || timeout <= 0 {
+			return cfg, fmt.Errorf("invalid value for env var: %s=%s (expected a positive duration)", prometheusTimeoutKey, raw)
+		}
+		cfg.Timeout = timeout
+	}
+	if raw := os.Getenv(prometheusInsecureSkipVerifyKey); raw != "" {
+		insecure, err := strconv.ParseBool(raw)
+		if err != nil {
+			return cfg, fmt.Errorf("invalid value for env var: %s=%s (expected bool): %v", prometheusInsecureSkipVerifyKey, raw, err)
+		}
+		cfg.InsecureSkipVerify = insecure
+	}
+	return cfg, nil
+}
+
+// newPrometheusAPI creates a Prometheus API client for the configuration.
+func newPrometheusAPI(cfg prometheusConfig) (promv1.API, error) {
+	tlsConfig := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		// Only set when explicitly requested, e.g. for local development.
+		// #nosec G402
+		InsecureSkipVerify: cfg.InsecureSkipVerify,
+	}
+	if !cfg.InsecureSkipVerify && cfg.CAFile != "" {
+		pem, err := os.ReadFile(cfg.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("couldn't read CA file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in CA file %s", cfg.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	// Fail early rather than on every request if the token can't be read.
+	if _, err := readToken(cfg.TokenFile); err != nil {
+		return nil, err
+	}
+
+	client, err := api.NewClient(api.Config{
+		Address: cfg.URL,
+		Client: &http.Client{
+			Timeout: cfg.Timeout,
+			Transport: &bearerTokenRoundTripper{
+				tokenFile: cfg.TokenFile,
+				next: &http.Transport{
+					Proxy: http.ProxyFromEnvironment,
+					DialContext: (&net.Dialer{
+						Timeout:   30 * time.Second,
+						KeepAlive: 30 * time.Second,
+					}).DialContext,
+					TLSClientConfig:     tlsConfig,
+					TLSHandshakeTimeout: 10 * time.Second,
+				},
+			},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("couldn't configure prometheus client: %w", err)
+	}
+	return promv1.NewAPI(client), nil
+}
+
+// bearerTokenRoundTripper authenticates requests with the token in tokenFile.
+type bearerTokenRoundTripper struct {
+	tokenFile string
+	next      http.RoundTripper
+}
+
+func (rt *bearerTokenRoundTripper) RoundTrip(request *http.Request) (*http.Response, error) {
+	token, err := readToken(rt.tokenFile)
+	if err != nil {
+		return nil, err
+	}
+	// RoundTrippers must not modify the original request.
+	request = request.Clone(request.Context())
+	request.Header.Set("Authorization", "Bearer "+token)
+	return rt.next.RoundTrip(request)
+}
+
+func readToken(tokenFile string) (string, error) {
+	rawToken, err := os.ReadFile(tokenFile) // #nosec G304 -- the path comes from the operator's configuration
+	if err != nil {
+		return "", fmt.Errorf("couldn't read token file: %w", err)
+	}
+	return strings.TrimSpace(string(rawToken)), nil
+}
+
+// getEnvString returns the value of the environment variable with the specified `key`,
+// or `def` if it is unspecified/empty.
+func getEnvString(key string, def string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return def
+}
@@ -0,0 +1,128 @@
+package readiness
+
+import (
+	"context"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// newPrometheusStandIn serves instant queries over TLS, answering only requests bearing the current token.
+func newPrometheusStandIn(t *testing.T, token *string) *httptest.Server {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer "+*token {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"status":"success","data":{"resultType":"vector","result":[{"metric":{"type":"initial"},"value":[1700000000,"1600000000"]}]}}`)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func writeFile(t *testing.T, name, content string) string {
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestPrometheusAPI(t *testing.T) {
+	token := "first-token"
+	server := newPrometheusStandIn(t, &token)
+	caFile := writeFile(t, "service-ca.crt", string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})))
+	tokenFile := writeFile(t, "token", token+"\n")
+
+	cfg := prometheusConfig{URL: server.URL, CAFile: caFile, TokenFile: tokenFile, Timeout: 5 * time.Second}
+	promAPI, err := newPrometheusAPI(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := promAPI.Query(context.TODO(), "cluster_version", time.Now()); err != nil {
+		t.Errorf("query failed: %v", err)
+	}
+
+	// rotated tokens are picked up without recreating the client
+	token = "rotated-token"
+	if err := os.WriteFile(tokenFile, []byte(token), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := promAPI.Query(context.TODO(), "cluster_version", time.Now()); err != nil {
+		t.Errorf("query with rotated token failed: %v", err)
+	}
+
+	// the serving certificate is verified, here against the system roots
+	untrusted, err := newPrometheusAPI(prometheusConfig{URL: server.URL, TokenFile: tokenFile, Timeout: 5 * time.Second})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := untrusted.Query(context.TODO(), "cluster_version", time.Now()); err == nil {
+		t.Errorf("query against an untrusted certificate succeeded")
+	}
+
+	// unless verification is explicitly disabled
+	insecure, err := newPrometheusAPI(prometheusConfig{URL: server.URL, TokenFile: tokenFile, Timeout: 5 * time.Second, InsecureSkipVerify: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := insecure.Query(context.TODO(), "cluster_version", time.Now()); err != nil {
+		t.Errorf("insecure query failed: %v", err)
+	}
+
+	if _, err := newPrometheusAPI(prometheusConfig{URL: server.URL, CAFile: filepath.Join(t.TempDir(), "missing"), TokenFile: tokenFile}); err == nil {
+		t.Errorf("missing CA file accepted")
+	}
+	if _, err := newPrometheusAPI(prometheusConfig{URL: server.URL, CAFile: caFile, TokenFile: filepath.Join(t.TempDir(), "missing")}); err == nil {
+		t.Errorf("missing token file accepted")
+	}
+}
+
+func TestSetClusterCreationTime(t *testing.T) {
+	token := "token"
+	server := newPrometheusStandIn(t, &token)
+	t.Setenv(prometheusURLKey, server.URL)
+	t.Setenv(prometheusCAFileKey, writeFile(t, "service-ca.crt", string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}))))
+	t.Setenv(prometheusTokenFileKey, writeFile(t, "token", token))
+
+	impl := &Impl{}
+	if err := impl.setClusterCreationTime(); err != nil {
+		t.Fatal(err)
+	}
+	if !impl.clusterCreationTime.Equal(time.Unix(1600000000, 0)) {
+		t.Errorf("unexpected cluster creation time %v", impl.clusterCreationTime)
+	}
+}
+
+func TestPrometheusConfigFromEnv(t *testing.T) {
+	cfg, err := prometheusConfigFromEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := prometheusConfig{URL: prometheusURLDefault, CAFile: prometheusCAFileDefault, TokenFile: prometheusTokenFileDefault, Timeout: prometheusTimeoutDefault}
+	if cfg != expected {
+		t.Errorf("unexpected defaults %+v", cfg)
+	}
+
+	t.Setenv(prometheusTimeoutKey, "30s")
+	t.Setenv(prometheusInsecureSkipVerifyKey, "true")
+	cfg, err = prometheusConfigFromEnv()
+	if err != nil || cfg.Timeout != 30*time.Second || !cfg.InsecureSkipVerify {
+		t.Errorf("unexpected config %+v, %v", cfg, err)
+	}
+
+	for key, value := range map[string]string{prometheusTimeoutKey: "soon", prometheusInsecureSkipVerifyKey: "maybe"} {
+		t.Run(key, func(t *testing.T) {
+			t.Setenv(key, value)
+			if _, err := prometheusConfigFromEnv(); err == nil {
+				t.Errorf("%s=%s accepted", key, value)
+			}
+		})
+	}
+}
@@ -31,15 +31,18 @@ type Config struct {
 }
 
 type InhibitRule struct {
-	TargetMatch   map[string]string `yaml:"target_match,omitempty" json:"target_match,omitempty"`
-	TargetMatchRE map[string]string `yaml:"target_match_re,omitempty" json:"target_match_re,omitempty"`
-	SourceMatch   map[string]string `yaml:"source_match,omitempty" json:"source_match,omitempty"`
-	SourceMatchRE map[string]string `yaml:"source_match_re,omitempty" json:"source_match_re,omitempty"`
-	Equal         []string          `yaml:"equal,omitempty" json:"equal,omitempty"`
+	TargetMatch    map[string]string `yaml:"target_match,omitempty" json:"target_match,omitempty"`
+	TargetMatchRE  map[string]string `yaml:"target_match_re,omitempty" json:"target_match_re,omitempty"`
+	TargetMatchers []string          `yaml:"target_matchers,omitempty" json:"target_matchers,omitempty"`
+	SourceMatch    map[string]string `yaml:"source_match,omitempty" json:"source_match,omitempty"`
+	SourceMatchRE  map[string]string `yaml:"source_match_re,omitempty" json:"source_match_re,omitempty"`
+	SourceMatchers []string          `yaml:"source_matchers,omitempty" json:"source_matchers,omitempty"`
+	Equal          []string          `yaml:"equal,omitempty" json:"equal,omitempty"`
 }
 
+// String renders the config with its secrets redacted.
 func (c Config) String() string {
-	b, err := yaml.Marshal(c)
+	b, err := yaml.Marshal(c.Redacted())
 	if err != nil {
 		return fmt.Sprintf("<error creating config string: %s>", err)
 	}
@@ -102,6 +105,7 @@ type Route struct {
 
 	Match    map[string]string `yaml:"match,omitempty" json:"match,omitempty"`
 	MatchRE  map[string]string `yaml:"match_re,omitempty" json:"match_re,omitempty"`
+	Matchers []string          `yaml:"matchers,omitempty" json:"matchers,omitempty"`
 	Continue bool              `yaml:"continue,omitempty" json:"continue,omitempty"`
 	Routes   []*Route          `yaml:"routes,omitempty" json:"routes,omitempty"`
 
@@ -135,7 +139,9 @@ type WebhookConfig struct {
 	NotifierConfig `yaml:",inline" json:",inline"`
 
 	// URL to send POST request to.
-	URL string `yaml:"url" json:"url"`
+	URL SecretURL `yaml:"url,omitempty" json:"url,omitempty"`
+	// URLFile is read for the URL instead, if set.
+	URLFile string `yaml:"url_file,omitempty" json:"url_file,omitempty"`
 
 	HttpConfig HttpConfig `yaml:"http_config,omitempty" json:"http_config,omitempty"`
 }
@@ -145,17 +151,18 @@ type WebhookConfig struct {
 type PagerdutyConfig struct {
 	NotifierConfig `yaml:",inline" json:",inline"`
 
-	RoutingKey  string            `yaml:"routing_key,omitempty" json:"routing_key,omitempty"`
-	URL         string            `yaml:"url,omitempty" json:"url,omitempty"`
-	Client      string            `yaml:"client,omitempty" json:"client,omitempty"`
-	ClientURL   string            `yaml:"client_url,omitempty" json:"client_url,omitempty"`
-	Description string            `yaml:"description,omitempty" json:"description,omitempty"`
-	Details     map[string]string `yaml:"details,omitempty" json:"details,omitempty"`
-	Severity    string            `yaml:"severity,omitempty" json:"severity,omitempty"`
-	Class       string            `yaml:"class,omitempty" json:"class,omitempty"`
-	Component   string            `yaml:"component,omitempty" json:"component,omitempty"`
-	Group       string            `yaml:"group,omitempty" json:"group,omitempty"`
-	HttpConfig  HttpConfig        `yaml:"http_config,omitempty" json:"http_config,omitempty"`
+	RoutingKey     Secret            `yaml:"routing_key,omitempty" json:"routing_key,omitempty"`
+	RoutingKeyFile string            `yaml:"routing_key_file,omitempty" json:"routing_key_file,omitempty"`
+	URL            string            `yaml:"url,omitempty" json:"url,omitempty"`
+	Client         string            `yaml:"client,omitempty" json:"client,omitempty"`
+	ClientURL      string            `yaml:"client_url,omitempty" json:"client_url,omitempty"`
+	Description    string            `yaml:"description,omitempty" json:"description,omitempty"`
+	Details        map[string]string `yaml:"details,omitempty" json:"details,omitempty"`
+	Severity       string            `yaml:"severity,omitempty" json:"severity,omitempty"`
+	Class          string            `yaml:"class,omitempty" json:"class,omitempty"`
+	Component      string            `yaml:"component,omitempty" json:"component,omitempty"`
+	Group          string            `yaml:"group,omitempty" json:"group,omitempty"`
+	HttpConfig     HttpConfig        `yaml:"http_config,omitempty" json:"http_config,omitempty"`
 }
 
 type NamespaceConfig struct {
@@ -164,8 +171,79 @@ type NamespaceConfig struct {
 
 type NamespaceList struct {
 	Namespaces []Namespace `yaml:"Namespace,omitempty" json:"Namespace,omitempty"`
+	// Exclude lists namespaces that are not routed as managed namespaces, even if they match Namespaces.
+	Exclude []Namespace `yaml:"Exclude,omitempty" json:"Exclude,omitempty"`
 }
 
+// Namespace matches namespaces by exact name, regular expression or glob; exactly one must be set.
+// ReceiverClass optionally routes the alerts of the matching namespaces, or only those named in
+// Alertnames, to the receiver of that class (critical, error, warning, default or null) rather than
+// by their severity.
 type Namespace struct {
-	Name string `yaml:"name,omitempty" json:"name,omitempty"`
+	Name          string   `yaml:"name,omitempty" json:"name,omitempty"`
+	Regex         string   `yaml:"regex,omitempty" json:"regex,omitempty"`
+	Glob          string   `yaml:"glob,omitempty" json:"glob,omitempty"`
+	ReceiverClass string   `yaml:"receiver_class,omitempty" json:"receiver_class,omitempty"`
+	Alertnames    []string `yaml:"alertnames,omitempty" json:"alertnames,omitempty"`
+}
+
+// InhibitRuleOverrides is the document used to adjust the operator's inhibit rule catalog.
+// Entries whose ID matches a catalog rule may only toggle it; entries with a new ID add a rule.
+type InhibitRuleOverrides struct {
+	Rules []InhibitRuleOverride `yaml:"rules,omitempty" json:"rules,omitempty"`
+}
+
+type InhibitRuleOverride struct {
+	ID          string `yaml:"id" json:"id"`
+	Enabled     *bool  `yaml:"enabled,omitempty" json:"enabled,omitempty"`
+	Description string `yaml:"description,omitempty" json:"description,omitempty"`
+	Ticket      string `yaml:"ticket,omitempty" json:"ticket,omitempty"`
+
+	SourceMatchers []string `yaml:"source_matchers,omitempty" json:"source_matchers,omitempty"`
+	TargetMatchers []string `yaml:"target_matchers,omitempty" json:"target_matchers,omitempty"`
+	Equal          []string `yaml:"equal,omitempty" json:"equal,omitempty"`
+}
+
+// RouteSettings is the document used to tune the timing and grouping of the generated routes.
+// Empty fields keep the operator defaults.
+type RouteSettings struct {
+	GroupBy        []string `yaml:"group_by,omitempty" json:"group_by,omitempty"`
+	GroupWait      string   `yaml:"group_wait,omitempty" json:"group_wait,omitempty"`
+	GroupInterval  string   `yaml:"group_interval,omitempty" json:"group_interval,omitempty"`
+	RepeatInterval string   `yaml:"repeat_interval,omitempty" json:"repeat_interval,omitempty"`
+	ResolveTimeout string   `yaml:"resolve_timeout,omitempty" json:"resolve_timeout,omitempty"`
+
+	WatchdogRepeatInterval  string `yaml:"watchdog_repeat_interval,omitempty" json:"watchdog_repeat_interval,omitempty"`
+	HeartbeatRepeatInterval string `yaml:"heartbeat_repeat_interval,omitempty" json:"heartbeat_repeat_interval,omitempty"`
+	OCMAgentRepeatInterval  string `yaml:"ocm_agent_repeat_interval,omitempty" json:"ocm_agent_repeat_interval,omitempty"`
+
+	// ReceiverClasses overrides the timing of the routes sending to a class of receiver:
+	// "critical", "error", "warning" or "default".
+	ReceiverClasses map[string]RouteTiming `yaml:"receiver_classes,omitempty" json:"receiver_classes,omitempty"`
+}
+
+// RouteTiming holds the timing options that can be set on a Route.
+type RouteTiming struct {
+	GroupWait      string `yaml:"group_wait,omitempty" json:"group_wait,omitempty"`
+	GroupInterval  string `yaml:"group_interval,omitempty" json:"group_interval,omitempty"`
+	RepeatInterval string `yaml:"repeat_interval,omitempty" json:"repeat_interval,omitempty"`
+}
+
+// InstallPaging is the document listing the alerts that page before the cluster is ready.
+type InstallPaging struct {
+	Rules []InstallPagingRule `yaml:"rules,omitempty" json:"rules,omitempty"`
+}
+
+// InstallPagingRule selects alerts that page during install. All matchers must match.
+type InstallPagingRule struct {
+	Description string   `yaml:"description,omitempty" json:"description,omitempty"`
+	Matchers    []string `yaml:"matchers" json:"matchers"`
+}
+
+// GoAlertRoutingRule routes the alerts of a severity to a GoAlert tier, optionally only those from
+// namespaces matching a regular expression.
+type GoAlertRoutingRule struct {
+	Severity  string `yaml:"severity" json:"severity"`
+	Namespace string `yaml:"namespace,omitempty" json:"namespace,omitempty"`
+	Tier      string `yaml:"tier" json:"tier"`
 }
@@ -0,0 +1,45 @@
+package alertmanagerconfig
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+)
+
+// Matcher operators supported by the Alertmanager matchers syntax.
+// https://prometheus.io/docs/alerting/latest/configuration/#matcher
+const (
+	MatchEqual     = "="
+	MatchNotEqual  = "!="
+	MatchRegexp    = "=~"
+	MatchNotRegexp = "!~"
+)
+
+// matcherRE splits a matcher into label name, operator and (optionally quoted) value.
+var matcherRE = regexp.MustCompile(`^\s*([a-zA-Z_][a-zA-Z0-9_]*)\s*(=~|!~|!=|=)\s*("(?:[^"\\]|\\.)*"|[^"\s]*)\s*$`)
+
+// NewMatcher renders a matcher in the Alertmanager matchers syntax, e.g. `severity=~"warning|info"`.
+func NewMatcher(name, op, value string) string {
+	return name + op + strconv.Quote(value)
+}
+
+// ParseMatcher splits a matcher in the Alertmanager matchers syntax into its label name,
+// operator and unquoted value. Regular expression values are checked for validity.
+func ParseMatcher(s string) (name, op, value string, err error) {
+	m := matcherRE.FindStringSubmatch(s)
+	if m == nil {
+		return "", "", "", fmt.Errorf("invalid matcher %q", s)
+	}
+	name, op, value = m[1], m[2], m[3]
+	if len(value) > 0 && value[0] == '"' {
+		if value, err = strconv.Unquote(value); err != nil {
+			return "", "", "", fmt.Errorf("invalid matcher %q: %w", s, err)
+		}
+	}
+	if op == MatchRegexp || op == MatchNotRegexp {
+		if _, err := regexp.Compile("^(?:" + value + ")$"); err != nil {
+			return "", "", "", fmt.Errorf("invalid regular expression in matcher %q: %w", s, err)
+		}
+	}
+	return name, op, value, nil
+}
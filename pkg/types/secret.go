@@ -0,0 +1,108 @@
+package alertmanagerconfig
+
+import (
+	"encoding/json"
+	"net/url"
+	"strings"
+)
+
+// secretToken replaces secrets in logs, Events and diffs.
+const secretToken = "<secret>"
+
+// Secret is a credential in the Alertmanager config, such as a PagerDuty routing key.
+// Like the upstream type of the same name it prints as "<secret>" and marshals to JSON (used
+// by the structured logger) as "<secret>". Unlike upstream, it marshals to YAML as its real value
+// so the config written to alertmanager-main works; use Config.Redacted to marshal it safely.
+type Secret string
+
+// String implements fmt.Stringer.
+func (s Secret) String() string {
+	if s == "" {
+		return ""
+	}
+	return secretToken
+}
+
+// GoString implements fmt.GoStringer, so %#v doesn't leak the value either.
+func (s Secret) GoString() string {
+	return s.String()
+}
+
+// MarshalJSON implements json.Marshaler.
+func (s Secret) MarshalJSON() ([]byte, error) {
+	return json.Marshal(s.String())
+}
+
+// MarshalYAML implements yaml.Marshaler.
+func (s Secret) MarshalYAML() (interface{}, error) {
+	return string(s), nil
+}
+
+// SecretURL is a URL that carries credentials, such as a GoAlert or Dead Man's Snitch URL.
+// It is handled like Secret.
+type SecretURL string
+
+// String implements fmt.Stringer.
+func (s SecretURL) String() string {
+	return Secret(s).String()
+}
+
+// GoString implements fmt.GoStringer.
+func (s SecretURL) GoString() string {
+	return s.String()
+}
+
+// MarshalJSON implements json.Marshaler.
+func (s SecretURL) MarshalJSON() ([]byte, error) {
+	return Secret(s).MarshalJSON()
+}
+
+// MarshalYAML implements yaml.Marshaler.
+func (s SecretURL) MarshalYAML() (interface{}, error) {
+	return string(s), nil
+}
+
+// redacted returns the URL with its credentials hidden. URLs of in-cluster services carry no
+// credentials and are kept as is, so they still show up in diffs.
+func (s SecretURL) redacted() SecretURL {
+	u, err := url.Parse(string(s))
+	if err == nil && u.User == nil && (strings.HasSuffix(u.Hostname(), ".svc") || strings.HasSuffix(u.Hostname(), ".svc.cluster.local")) {
+		return s
+	}
+	return SecretURL(s.String())
+}
+
+// redactProxyURL hides the password of a proxy URL.
+func redactProxyURL(proxyURL string) string {
+	u, err := url.Parse(proxyURL)
+	if err != nil {
+		return secretToken
+	}
+	return u.Redacted()
+}
+
+// Redacted returns a copy of the config with all secrets replaced by "<secret>", suitable
+// for logs, Events and diffs. The receiver is not modified.
+func (c Config) Redacted() *Config {
+	redacted := c
+	redacted.Receivers = make([]*Receiver, 0, len(c.Receivers))
+	for _, receiver := range c.Receivers {
+		rcv := *receiver
+		rcv.PagerdutyConfigs = nil
+		for _, pdc := range receiver.PagerdutyConfigs {
+			redactedPDC := *pdc
+			redactedPDC.RoutingKey = Secret(pdc.RoutingKey.String())
+			redactedPDC.HttpConfig.ProxyURL = redactProxyURL(pdc.HttpConfig.ProxyURL)
+			rcv.PagerdutyConfigs = append(rcv.PagerdutyConfigs, &redactedPDC)
+		}
+		rcv.WebhookConfigs = nil
+		for _, whc := range receiver.WebhookConfigs {
+			redactedWHC := *whc
+			redactedWHC.URL = whc.URL.redacted()
+			redactedWHC.HttpConfig.ProxyURL = redactProxyURL(whc.HttpConfig.ProxyURL)
+			rcv.WebhookConfigs = append(rcv.WebhookConfigs, &redactedWHC)
+		}
+		redacted.Receivers = append(redacted.Receivers, &rcv)
+	}
+	return &redacted
+}
@@ -0,0 +1,165 @@
+package verify
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/prometheus/common/model"
+	yaml "gopkg.in/yaml.v2"
+
+	alertmanager "github.com/openshift/configure-alertmanager-operator/pkg/types"
+)
+
+// Fingerprint summarizes the parts of an Alertmanager config that survive Alertmanager
+// re-marshalling it: receiver names and integration counts, the route tree and the inhibit
+// rules. Secrets and notifier defaults are left out, since Alertmanager masks or fills them in.
+func Fingerprint(cfg *alertmanager.Config) (string, error) {
+	canonical := struct {
+		Receivers    []receiverSummary `json:"receivers"`
+		Route        *routeSummary     `json:"route"`
+		InhibitRules []inhibitSummary  `json:"inhibit_rules"`
+	}{}
+
+	for _, receiver := range cfg.Receivers {
+		canonical.Receivers = append(canonical.Receivers, receiverSummary{
+			Name:      receiver.Name,
+			Pagerduty: len(receiver.PagerdutyConfigs),
+			Webhook:   len(receiver.WebhookConfigs),
+		})
+	}
+	sort.Slice(canonical.Receivers, func(i, j int) bool {
+		return canonical.Receivers[i].Name < canonical.Receivers[j].Name
+	})
+
+	if cfg.Route != nil {
+		route, err := summarizeRoute(cfg.Route)
+		if err != nil {
+			return "", err
+		}
+		canonical.Route = route
+	}
+
+	for _, rule := range cfg.InhibitRules {
+		sourceMatchers, err := normalizeMatchers(rule.SourceMatchers)
+		if err != nil {
+			return "", err
+		}
+		targetMatchers, err := normalizeMatchers(rule.TargetMatchers)
+		if err != nil {
+			return "", err
+		}
+		canonical.InhibitRules = append(canonical.InhibitRules, inhibitSummary{
+			SourceMatch:    rule.SourceMatch,
+			SourceMatchRE:  rule.SourceMatchRE,
+			SourceMatchers: sourceMatchers,
+			TargetMatch:    rule.TargetMatch,
+			TargetMatchRE:  rule.TargetMatchRE,
+			TargetMatchers: targetMatchers,
+			Equal:          rule.Equal,
+		})
+	}
+
+	// encoding/json sorts map keys, so the encoding is stable
+	b, err := json.Marshal(canonical)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// FingerprintYAML parses an Alertmanager config document and returns its Fingerprint.
+func FingerprintYAML(raw string) (string, error) {
+	cfg := &alertmanager.Config{}
+	if err := yaml.Unmarshal([]byte(raw), cfg); err != nil {
+		return "", fmt.Errorf("couldn't parse Alertmanager config: %w", err)
+	}
+	return Fingerprint(cfg)
+}
+
+type receiverSummary struct {
+	Name      string `json:"name"`
+	Pagerduty int    `json:"pagerduty"`
+	Webhook   int    `json:"webhook"`
+}
+
+type routeSummary struct {
+	Receiver       string            `json:"receiver"`
+	GroupBy        []string          `json:"group_by"`
+	Match          map[string]string `json:"match"`
+	MatchRE        map[string]string `json:"match_re"`
+	Matchers       []string          `json:"matchers"`
+	Continue       bool              `json:"continue"`
+	GroupWait      string            `json:"group_wait"`
+	GroupInterval  string            `json:"group_interval"`
+	RepeatInterval string            `json:"repeat_interval"`
+	Routes         []*routeSummary   `json:"routes"`
+}
+
+type inhibitSummary struct {
+	SourceMatch    map[string]string `json:"source_match"`
+	SourceMatchRE  map[string]string `json:"source_match_re"`
+	SourceMatchers []string          `json:"source_matchers"`
+	TargetMatch    map[string]string `json:"target_match"`
+	TargetMatchRE  map[string]string `json:"target_match_re"`
+	TargetMatchers []string          `json:"target_matchers"`
+	Equal          []string          `json:"equal"`
+}
+
+func summarizeRoute(route *alertmanager.Route) (*routeSummary, error) {
+	matchers, err := normalizeMatchers(route.Matchers)
+	if err != nil {
+		return nil, err
+	}
+	summary := &routeSummary{
+		Receiver: route.Receiver,
+		GroupBy:  route.GroupByStr,
+		Match:    route.Match,
+		MatchRE:  route.MatchRE,
+		Matchers: matchers,
+		Continue: route.Continue,
+	}
+	for _, d := range []struct {
+		raw    string
+		target *string
+	}{
+		{route.GroupWait, &summary.GroupWait},
+		{route.GroupInterval, &summary.GroupInterval},
+		{route.RepeatInterval, &summary.RepeatInterval},
+	} {
+		if d.raw == "" {
+			continue
+		}
+		// Alertmanager prints durations in their shortest form, e.g. 60m becomes 1h
+		parsed, err := model.ParseDuration(d.raw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid duration %q: %w", d.raw, err)
+		}
+		*d.target = parsed.String()
+	}
+	for _, child := range route.Routes {
+		childSummary, err := summarizeRoute(child)
+		if err != nil {
+			return nil, err
+		}
+		summary.Routes = append(summary.Routes, childSummary)
+	}
+	return summary, nil
+}
+
+// normalizeMatchers rewrites matchers in a single quoting style, since Alertmanager
+// re-quotes them when it prints its config.
+func normalizeMatchers(matchers []string) ([]string, error) {
+	var normalized []string
+	for _, matcher := range matchers {
+		name, op, value, err := alertmanager.ParseMatcher(matcher)
+		if err != nil {
+			return nil, err
+		}
+		normalized = append(normalized, fmt.Sprintf("%s%s%q", name, op, value))
+	}
+	return normalized, nil
+}
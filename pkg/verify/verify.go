@@ -0,0 +1,171 @@
+package verify
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/common/expfmt"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+var log = logf.Log.WithName("verify")
+
+const (
+	// Environment variables configuring the connection to Alertmanager
+	alertmanagerURLKey                = "ALERTMANAGER_URL"
+	alertmanagerCAFileKey             = "ALERTMANAGER_CA_FILE"
+	alertmanagerTokenFileKey          = "ALERTMANAGER_TOKEN_FILE"
+	alertmanagerTimeoutKey            = "ALERTMANAGER_TIMEOUT"
+	alertmanagerInsecureSkipVerifyKey = "ALERTMANAGER_INSECURE_SKIP_VERIFY"
+
+	alertmanagerURLDefault       = "https://alertmanager-main.openshift-monitoring.svc:9094"
+	alertmanagerCAFileDefault    = "/var/run/secrets/kubernetes.io/serviceaccount/service-ca.crt"
+	alertmanagerTokenFileDefault = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+	alertmanagerTimeoutDefault   = 10 * time.Second
+
+	// The Alertmanager metric telling whether the last configuration reload succeeded
+	metricLastReloadSuccessful = "alertmanager_config_last_reload_successful"
+)
+
+// Verifier queries Alertmanager for the configuration it has loaded.
+type Verifier struct {
+	// URL is the Alertmanager address.
+	URL string
+	// TokenFile, if set, holds a bearer token. It is re-read for every request.
+	TokenFile string
+	// Client is used for all requests.
+	Client *http.Client
+}
+
+// NewFromEnv creates a Verifier for the in-cluster Alertmanager. The address, CA bundle, token
+// and timeout can be overridden with environment variables.
+func NewFromEnv() (*Verifier, error) {
+	timeout := alertmanagerTimeoutDefault
+	if raw := os.Getenv(alertmanagerTimeoutKey); raw != "" {
+		parsed, err := time.ParseDuration(raw)
+		if err != nil || parsed <= 0 {
+			return nil, fmt.Errorf("invalid value for env var: %s=%s (expected a positive duration)", alertmanagerTimeoutKey, raw)
+		}
+		timeout = parsed
+	}
+	insecure := false
+	if raw := os.Getenv(alertmanagerInsecureSkipVerifyKey); raw != "" {
+		parsed, err := strconv.ParseBool(raw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value for env var: %s=%s (expected bool): %v", alertmanagerInsecureSkipVerifyKey, raw, err)
+		}
+		insecure = parsed
+	}
+
+	tlsConfig := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		// Only set when explicitly requested, e.g. for local development.
+		// #nosec G402
+		InsecureSkipVerify: insecure,
+	}
+	if caFile := getEnvString(alertmanagerCAFileKey, alertmanagerCAFileDefault); !insecure && caFile != "" {
+		pem, err := os.ReadFile(caFile) // #nosec G304 -- the path comes from the operator's configuration
+		if err != nil {
+			return nil, fmt.Errorf("couldn't read CA file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in CA file %s", caFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+	return &Verifier{
+		URL:       getEnvString(alertmanagerURLKey, alertmanagerURLDefault),
+		TokenFile: getEnvString(alertmanagerTokenFileKey, alertmanagerTokenFileDefault),
+		Client:    &http.Client{Timeout: timeout, Transport: transport},
+	}, nil
+}
+
+// LoadedConfig returns the configuration Alertmanager reports in /api/v2/status. Alertmanager
+// re-marshals the configuration it loaded, so secrets are masked and defaults are filled in.
+func (v *Verifier) LoadedConfig(ctx context.Context) (string, error) {
+	body, err := v.get(ctx, "/api/v2/status")
+	if err != nil {
+		return "", err
+	}
+	var status struct {
+		Config struct {
+			Original string `json:"original"`
+		} `json:"config"`
+	}
+	if err := json.Unmarshal(body, &status); err != nil {
+		return "", fmt.Errorf("couldn't decode Alertmanager status: %w", err)
+	}
+	if status.Config.Original == "" {
+		return "", fmt.Errorf("Alertmanager status has no config")
+	}
+	return status.Config.Original, nil
+}
+
+// LastReloadSuccessful reports whether Alertmanager's last configuration reload succeeded,
+// according to its own metrics.
+func (v *Verifier) LastReloadSuccessful(ctx context.Context) (bool, error) {
+	body, err := v.get(ctx, "/metrics")
+	if err != nil {
+		return false, err
+	}
+	var parser expfmt.TextParser
+	families, err := parser.TextToMetricFamilies(strings.NewReader(string(body)))
+	if err != nil {
+		return false, fmt.Errorf("couldn't parse Alertmanager metrics: %w", err)
+	}
+	family, ok := families[metricLastReloadSuccessful]
+	if !ok || len(family.GetMetric()) == 0 {
+		return false, fmt.Errorf("Alertmanager metrics have no %s", metricLastReloadSuccessful)
+	}
+	return family.GetMetric()[0].GetGauge().GetValue() == 1, nil
+}
+
+func (v *Verifier) get(ctx context.Context, path string) ([]byte, error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(v.URL, "/")+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	if v.TokenFile != "" {
+		rawToken, err := os.ReadFile(v.TokenFile)
+		if err != nil {
+			return nil, fmt.Errorf("couldn't read token file: %w", err)
+		}
+		request.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(rawToken)))
+	}
+	response, err := v.Client.Do(request)
+	if err != nil {
+		return nil, fmt.Errorf("error querying Alertmanager: %w", err)
+	}
+	defer response.Body.Close()
+	body, err := io.ReadAll(response.Body)
+	if err != nil {
+		return nil, fmt.Errorf("error reading Alertmanager response: %w", err)
+	}
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Alertmanager %s returned %s", path, response.Status)
+	}
+	log.V(1).Info("Queried Alertmanager", "path", path, "bytes", len(body))
+	return body, nil
+}
+
+// getEnvString returns the value of the environment variable with the specified `key`,
+// or `def` if it is unspecified/empty.
+func getEnvString(key string, def string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return def
+}
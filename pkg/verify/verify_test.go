@@ -0,0 +1,148 @@
+package verify
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func newAlertmanager(t *testing.T, config string, reloadSuccessful string) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer test-token" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		switch r.URL.Path {
+		case "/api/v2/status":
+			status := map[string]interface{}{"config": map[string]string{"original": config}}
+			if err := json.NewEncoder(w).Encode(status); err != nil {
+				t.Errorf("Could not encode status: %v", err)
+			}
+		case "/metrics":
+			_, _ = w.Write([]byte("# HELP alertmanager_config_last_reload_successful Whether the last configuration reload attempt was successful.\n" +
+				"# TYPE alertmanager_config_last_reload_successful gauge\n" +
+				"alertmanager_config_last_reload_successful " + reloadSuccessful + "\n"))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func newVerifier(t *testing.T, url string) *Verifier {
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(tokenFile, []byte("test-token\n"), 0o600); err != nil {
+		t.Fatalf("Could not write token file: %v", err)
+	}
+	return &Verifier{URL: url + "/", TokenFile: tokenFile, Client: http.DefaultClient}
+}
+
+func TestVerifier(t *testing.T) {
+	server := newAlertmanager(t, "route:\n  receiver: \"null\"\n", "0")
+	verifier := newVerifier(t, server.URL)
+
+	loaded, err := verifier.LoadedConfig(context.TODO())
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if loaded != "route:\n  receiver: \"null\"\n" {
+		t.Errorf("Unexpected config %q", loaded)
+	}
+
+	reloadSuccessful, err := verifier.LastReloadSuccessful(context.TODO())
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if reloadSuccessful {
+		t.Errorf("Expected the reload to have failed")
+	}
+
+	verifier.TokenFile = ""
+	if _, err := verifier.LoadedConfig(context.TODO()); err == nil {
+		t.Errorf("Expected an error without a token")
+	}
+}
+
+func TestFingerprint(t *testing.T) {
+	written := `
+global:
+  resolve_timeout: 5m
+route:
+  receiver: "null"
+  group_by: [alertname]
+  repeat_interval: 60m
+  routes:
+  - receiver: pagerduty
+    matchers:
+    - severity="critical"
+    - namespace=~^openshift-.*
+receivers:
+- name: pagerduty
+  pagerduty_configs:
+  - routing_key: the-key
+- name: "null"
+inhibit_rules:
+- source_match:
+    severity: critical
+  target_match_re:
+    severity: warning|info
+  equal: [alertname]
+`
+	// the same config as printed by Alertmanager
+	loaded := `
+global:
+  resolve_timeout: 5m
+  http_config:
+    follow_redirects: true
+route:
+  receiver: "null"
+  group_by:
+  - alertname
+  repeat_interval: 1h
+  routes:
+  - receiver: pagerduty
+    matchers:
+    - severity="critical"
+    - namespace=~"^openshift-.*"
+inhibit_rules:
+- source_match:
+    severity: critical
+  target_match_re:
+    severity: warning|info
+  equal:
+  - alertname
+receivers:
+- name: "null"
+- name: pagerduty
+  pagerduty_configs:
+  - send_resolved: true
+    routing_key: <secret>
+    url: https://events.pagerduty.com/v2/enqueue
+templates: []
+`
+	writtenFingerprint, err := FingerprintYAML(written)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	loadedFingerprint, err := FingerprintYAML(loaded)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if writtenFingerprint != loadedFingerprint {
+		t.Errorf("Expected the written and loaded configs to have the same fingerprint")
+	}
+
+	changedFingerprint, err := FingerprintYAML(strings.Replace(written, `severity="critical"`, `severity="warning"`, 1))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if changedFingerprint == writtenFingerprint {
+		t.Errorf("Expected a changed route tree to change the fingerprint")
+	}
+}
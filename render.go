@@ -0,0 +1,125 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	corev1 "k8s.io/api/core/v1"
+	k8sruntime "k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/serializer"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+
+	operatorconfig "github.com/openshift/configure-alertmanager-operator/config"
+	"github.com/openshift/configure-alertmanager-operator/controllers"
+)
+
+// renderCommand prints the alertmanager.yaml the operator would generate from local files.
+const renderCommand = "render"
+
+// runRender implements the render subcommand and returns the process exit code.
+func runRender(args []string) int {
+	fs := flag.NewFlagSet(renderCommand, flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s %s [flags]\n\n", os.Args[0], renderCommand)
+		fmt.Fprintln(fs.Output(), "Print the alertmanager.yaml the operator would generate, using Secret and ConfigMap manifests from local files instead of a cluster.")
+		fs.PrintDefaults()
+	}
+
+	// Each object is read from a manifest file; its name and namespace are ignored.
+	secretFiles := map[string]*string{
+		"pd-secret":      fs.String("pd-secret", "", "Secret manifest standing in for openshift-monitoring/pd-secret"),
+		"goalert-secret": fs.String("goalert-secret", "", "Secret manifest standing in for openshift-monitoring/goalert-secret"),
+		"dms-secret":     fs.String("dms-secret", "", "Secret manifest standing in for openshift-monitoring/dms-secret"),
+	}
+	cmFiles := map[string]*string{
+		"managed-namespaces":        fs.String("managed-namespaces", "", "ConfigMap manifest standing in for openshift-monitoring/managed-namespaces"),
+		"ocp-namespaces":            fs.String("ocp-namespaces", "", "ConfigMap manifest standing in for openshift-monitoring/ocp-namespaces"),
+		"ocm-agent":                 fs.String("ocm-agent", "", "ConfigMap manifest standing in for openshift-monitoring/ocm-agent"),
+		operatorconfig.OperatorName: fs.String("operator-config", "", "ConfigMap manifest standing in for openshift-monitoring/"+operatorconfig.OperatorName),
+	}
+	var in controllers.RenderInput
+	var fedramp bool
+	fs.StringVar(&in.ClusterID, "cluster-id", "", "Cluster ID")
+	fs.StringVar(&in.ClusterProxy, "proxy", "", "HTTPS proxy URL of the cluster")
+	fs.BoolVar(&in.ClusterReady, "cluster-ready", true, "Render as if the cluster passed its readiness checks")
+	fs.BoolVar(&fedramp, "fedramp", false, "Render for a FedRAMP environment")
+	opts := zap.Options{
+		Development: true,
+	}
+	opts.BindFlags(fs)
+	_ = fs.Parse(args)
+
+	ctrl.SetLogger(zap.New(zap.UseFlagOptions(&opts)))
+	operatorconfig.SetFedramp(fedramp)
+
+	decoder := serializer.NewCodecFactory(scheme).UniversalDeserializer()
+	for name, path := range secretFiles {
+		if *path == "" {
+			continue
+		}
+		secret := &corev1.Secret{}
+		if err := decodeManifest(decoder, *path, secret); err != nil {
+			setupLog.Error(err, "unable to read Secret", "file", *path)
+			return 1
+		}
+		// stringData is normally merged by the API server
+		for key, value := range secret.StringData {
+			if secret.Data == nil {
+				secret.Data = map[string][]byte{}
+			}
+			secret.Data[key] = []byte(value)
+		}
+		secret.Name = name
+		in.Secrets = append(in.Secrets, *secret)
+	}
+	for name, path := range cmFiles {
+		if *path == "" {
+			continue
+		}
+		cm := &corev1.ConfigMap{}
+		if err := decodeManifest(decoder, *path, cm); err != nil {
+			setupLog.Error(err, "unable to read ConfigMap", "file", *path)
+			return 1
+		}
+		cm.Name = name
+		in.ConfigMaps = append(in.ConfigMaps, *cm)
+	}
+
+	amconfig, err := controllers.Render(in)
+	if err != nil {
+		setupLog.Error(err, "unable to render the Alertmanager config")
+		return 1
+	}
+	if _, err := os.Stdout.Write(amconfig); err != nil {
+		return 1
+	}
+	return 0
+}
+
+// decodeManifest decodes the YAML or JSON manifest in path into into.
+func decodeManifest(decoder k8sruntime.Decoder, path string, into k8sruntime.Object) error {
+	data, err := os.ReadFile(path) // #nosec G304 -- the path is supplied by the user running the command
+	if err != nil {
+		return err
+	}
+	_, _, err = decoder.Decode(data, nil, into)
+	return err
+}